@@ -0,0 +1,371 @@
+// Package cache keeps verified git objects and packs on disk keyed by their hash, so that repeated dumps and dumps
+// of forks and mirrors do not download them again. The least recently used entries are evicted when the cache
+// grows over its size limit.
+package cache
+
+import (
+	"container/list"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/githunt/internal/git"
+)
+
+var packName = regexp.MustCompile(`^pack-([0-9a-f]{40}|[0-9a-f]{64})\.(pack|idx)$`)
+
+// staleTemp is the age after which a temporary file is considered abandoned by its writer.
+const staleTemp = time.Hour
+
+type entry struct {
+	key  string
+	size int64
+}
+
+// Cache is a content-addressed store of loose objects and packs. It is safe for concurrent use, entries are
+// written atomically so that several processes can share a directory.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// Open loads the cache stored in dir, entries are ordered by their modification time which is refreshed on
+// every hit. A maxSize of 0 or less disables the limit.
+func Open(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory %s. Error: %w", dir, err)
+	}
+
+	c := Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	type file struct {
+		key  string
+		size int64
+		mod  time.Time
+	}
+
+	var files []file
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		key, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		key = filepath.ToSlash(key)
+
+		// files of concurrent writers can be renamed or removed during the walk
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// leftovers of interrupted writes, recent ones may belong to a process that is still writing them
+		if strings.HasPrefix(d.Name(), ".tmp-") {
+			if time.Since(info.ModTime()) < staleTemp {
+				return nil
+			}
+			if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+
+		if validKey(key) {
+			files = append(files, file{key: key, size: info.Size(), mod: info.ModTime()})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading cache %s. Error: %w", dir, err)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mod.After(files[j].mod)
+	})
+
+	for _, f := range files {
+		c.entries[f.key] = c.lru.PushBack(&entry{key: f.key, size: f.size})
+		c.size += f.size
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return &c, c.evict()
+}
+
+// Size returns the total size in bytes of the cached entries.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Object returns a cached object, objects whose content does not match their hash or that inflate to more than
+// maxSize bytes are dropped. A maxSize of 0 or less selects git.DefaultMaxObjectSize.
+func (c *Cache) Object(hash string, maxSize int64) (*git.Object, bool) {
+	if !git.IsHash(hash) {
+		return nil, false
+	}
+
+	key := objectKey(hash)
+
+	b, ok := c.get(key)
+	if !ok {
+		return nil, false
+	}
+
+	o, err := git.DecodeLoose(b, git.HashFormat(hash), maxSize)
+	if err != nil || o.Hash() != hash {
+		c.remove(key)
+		return nil, false
+	}
+
+	return o, true
+}
+
+// AddObject stores an object under its hash.
+func (c *Cache) AddObject(o *git.Object) error {
+	b, err := git.EncodeLoose(o)
+	if err != nil {
+		return err
+	}
+
+	return c.put(objectKey(o.Hash()), b)
+}
+
+// Pack returns a cached pack or pack index file such as pack-<hash>.pack, files whose trailing checksum does not
+// match their content or their name are dropped. The length of the pack hash selects the SHA-1 or SHA-256 checksum.
+func (c *Cache) Pack(name string) ([]byte, bool) {
+	if !packName.MatchString(name) {
+		return nil, false
+	}
+
+	key := "packs/" + name
+
+	b, ok := c.get(key)
+	if !ok {
+		return nil, false
+	}
+
+	if !validPack(name, b) {
+		c.remove(key)
+		return nil, false
+	}
+
+	return b, true
+}
+
+// AddPack stores a pack or pack index file under the checksum of its pack, files that fail verification or whose
+// name is not the checksum are not stored.
+func (c *Cache) AddPack(name string, b []byte) error {
+	if !packName.MatchString(name) {
+		return fmt.Errorf("caching %s. Error: invalid pack name", name)
+	}
+
+	if !validPack(name, b) {
+		return fmt.Errorf("caching %s. Error: checksum mismatch", name)
+	}
+
+	return c.put("packs/"+name, b)
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	p := c.path(key)
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		c.remove(key)
+		return nil, false
+	}
+
+	// the modification time orders the entries when the cache is opened again
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+
+	return b, true
+}
+
+func (c *Cache) put(key string, b []byte) error {
+	size := int64(len(b))
+	if c.maxSize > 0 && size > c.maxSize {
+		return nil
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	p := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("caching %s. Error: %w", key, err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("caching %s. Error: %w", key, err)
+	}
+
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("caching %s. Error: %w", key, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// another writer may have stored the same content meanwhile
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		return nil
+	}
+
+	c.entries[key] = c.lru.PushFront(&entry{key: key, size: size})
+	c.size += size
+
+	return c.evict()
+}
+
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.drop(e)
+	}
+}
+
+// evict removes the least recently used entries until the cache fits its size limit, c.mu must be held.
+func (c *Cache) evict() error {
+	var errs []error
+
+	for c.maxSize > 0 && c.size > c.maxSize {
+		e := c.lru.Back()
+		if e == nil {
+			break
+		}
+		if err := c.drop(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// drop deletes an entry, c.mu must be held.
+func (c *Cache) drop(e *list.Element) error {
+	ent := e.Value.(*entry) //nolint:forcetypeassert
+
+	c.lru.Remove(e)
+	delete(c.entries, ent.key)
+	c.size -= ent.size
+
+	if err := os.Remove(c.path(ent.key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("evicting %s. Error: %w", ent.key, err)
+	}
+
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, filepath.FromSlash(key))
+}
+
+// packFormat returns the object format of a pack file by the length of its name.
+func packFormat(name string) git.ObjectFormat {
+	hash, _, _ := strings.Cut(strings.TrimPrefix(name, "pack-"), ".")
+
+	return git.HashFormat(hash)
+}
+
+// validPack reports whether a pack file matches its checksum and its name. A pack ends with its checksum, an index
+// ends with the checksum of its pack followed by its own.
+func validPack(name string, b []byte) bool {
+	format := packFormat(name)
+	if !format.Checksum(b) {
+		return false
+	}
+
+	hash, ext, _ := strings.Cut(strings.TrimPrefix(name, "pack-"), ".")
+
+	end := len(b)
+	if ext == "idx" {
+		end -= format.Size()
+	}
+	if end < format.Size() {
+		return false
+	}
+
+	return hex.EncodeToString(b[end-format.Size():end]) == hash
+}
+
+func objectKey(hash string) string {
+	return git.LoosePath(hash)
+}
+
+// validKey reports whether a file found in the cache directory is an entry.
+func validKey(key string) bool {
+	if name, ok := strings.CutPrefix(key, "packs/"); ok {
+		return packName.MatchString(name)
+	}
+
+	dir, name, ok := strings.Cut(strings.TrimPrefix(key, "objects/"), "/")
+
+	return ok && strings.HasPrefix(key, "objects/") && git.IsHash(dir+name)
+}
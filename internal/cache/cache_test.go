@@ -0,0 +1,266 @@
+package cache_test
+
+import (
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georlav/githunt/internal/cache"
+	"github.com/georlav/githunt/internal/git"
+)
+
+func blob(data string) *git.Object {
+	return &git.Object{Type: git.ObjectBlob, Data: []byte(data)}
+}
+
+// looseSize returns the size of the cache entry of an object.
+func looseSize(t *testing.T, o *git.Object) int64 {
+	t.Helper()
+
+	b, err := git.EncodeLoose(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return int64(len(b))
+}
+
+func TestCache_Object(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := cache.Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := blob("hello\n")
+	if _, ok := c.Object(o.Hash(), 0); ok {
+		t.Fatal("Expected a miss on an empty cache")
+	}
+
+	if err := c.AddObject(o); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddObject(o); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := c.Object(o.Hash(), 0)
+	if !ok || string(got.Data) != "hello\n" || c.Len() != 1 || c.Size() != looseSize(t, o) {
+		t.Fatalf("Unexpected cache state %v %d %d", ok, c.Len(), c.Size())
+	}
+
+	// entries survive a restart
+	c, err = cache.Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Object(o.Hash(), 0); !ok {
+		t.Fatal("Expected the object to be loaded from disk")
+	}
+
+	// corrupted entries are dropped instead of returned
+	other := blob("other\n")
+	b, err := git.EncodeLoose(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(git.LoosePath(o.Hash()))), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Object(o.Hash(), 0); ok || c.Len() != 0 {
+		t.Fatal("Expected the corrupted object to be dropped")
+	}
+
+	// objects of SHA-256 repositories are stored under their longer id
+	o256 := &git.Object{Type: git.ObjectBlob, Data: []byte("hello\n"), Format: git.SHA256}
+	if err := c.AddObject(o256); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok = c.Object(o256.Hash(), 0)
+	if !ok || got.Format != git.SHA256 || string(got.Data) != "hello\n" {
+		t.Fatal("Expected the SHA-256 object")
+	}
+}
+
+func TestCache_Pack(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := cache.Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("PACK\x00\x00\x00\x02\x00\x00\x00\x00")
+	sum := sha1.Sum(content) //nolint:gosec
+	pack := append(content, sum[:]...)
+	name := "pack-" + hex.EncodeToString(sum[:]) + ".pack"
+
+	if err := c.AddPack(name, content); err == nil {
+		t.Fatal("Expected error for a pack without a valid checksum")
+	}
+	if err := c.AddPack("../../etc/passwd", pack); err == nil {
+		t.Fatal("Expected error for an invalid pack name")
+	}
+	// entries are addressed by content, a valid pack can not be stored under the name of another
+	other := "pack-" + strings.Repeat("a", 40) + ".pack"
+	if err := c.AddPack(other, pack); err == nil {
+		t.Fatal("Expected error for a pack stored under another checksum")
+	}
+
+	if err := c.AddPack(name, pack); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, ok := c.Pack(name); !ok || string(b) != string(pack) {
+		t.Fatal("Expected the cached pack")
+	}
+
+	// an index ends with the checksum of its pack and its own
+	idx := append([]byte("\xfftOc\x00\x00\x00\x02"), sum[:]...)
+	idxSum := sha1.Sum(idx) //nolint:gosec
+	idx = append(idx, idxSum[:]...)
+
+	if err := c.AddPack(strings.TrimSuffix(other, ".pack")+".idx", idx); err == nil {
+		t.Fatal("Expected error for an index of another pack")
+	}
+	if err := c.AddPack(strings.TrimSuffix(name, ".pack")+".idx", idx); err != nil {
+		t.Fatal(err)
+	}
+
+	// files renamed on disk are dropped when opened again
+	if err := os.WriteFile(filepath.Join(dir, "packs", other), pack, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = cache.Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Pack(other); ok {
+		t.Fatal("Expected a pack under another checksum to be dropped")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "packs", other)); err == nil {
+		t.Fatal("Expected the renamed pack to be deleted")
+	}
+
+	// packs of SHA-256 repositories have longer names and checksums
+	sum256 := sha256.Sum256(content)
+	pack256 := append(append([]byte{}, content...), sum256[:]...)
+	name256 := "pack-" + hex.EncodeToString(sum256[:]) + ".pack"
+
+	if err := c.AddPack(name256, pack); err == nil {
+		t.Fatal("Expected error for a SHA-256 pack with a SHA-1 checksum")
+	}
+	if err := c.AddPack(name256, pack256); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, ok := c.Pack(name256); !ok || string(b) != string(pack256) {
+		t.Fatal("Expected the cached SHA-256 pack")
+	}
+}
+
+func TestOpen_Temp(t *testing.T) {
+	dir := t.TempDir()
+
+	stale, recent := filepath.Join(dir, ".tmp-stale"), filepath.Join(dir, ".tmp-recent")
+	for _, p := range []string{stale, recent} {
+		if err := os.WriteFile(p, []byte("partial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cache.Open(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// another process may still be writing the recent file
+	if _, err := os.Stat(stale); err == nil {
+		t.Fatal("Expected the abandoned temporary file to be deleted")
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Fatalf("Expected the recent temporary file to be kept. Error: %s", err)
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	dir := t.TempDir()
+	objects := []*git.Object{blob("one\n"), blob("two\n"), blob("three\n")}
+
+	// room for two objects
+	limit := looseSize(t, objects[0]) + looseSize(t, objects[1]) + 4
+
+	c, err := cache.Open(dir, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range objects[:2] {
+		if err := c.AddObject(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// reading the first object makes the second the least recently used
+	if _, ok := c.Object(objects[0].Hash(), 0); !ok {
+		t.Fatal("Expected a hit")
+	}
+
+	if err := c.AddObject(objects[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Object(objects[1].Hash(), 0); ok {
+		t.Fatal("Expected the least recently used object to be evicted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(git.LoosePath(objects[1].Hash())))); err == nil {
+		t.Fatal("Expected the evicted object to be deleted")
+	}
+	for _, o := range []*git.Object{objects[0], objects[2]} {
+		if _, ok := c.Object(o.Hash(), 0); !ok {
+			t.Fatalf("Expected %s to be cached", o.Hash())
+		}
+	}
+
+	if c.Size() > limit {
+		t.Fatalf("Expected the cache to fit %d bytes got %d", limit, c.Size())
+	}
+
+	// entries larger than the cache are not stored, random data does not compress
+	large := make([]byte, limit*4)
+	if _, err := rand.Read(large); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddObject(blob(string(large))); err != nil || c.Len() != 2 {
+		t.Fatalf("Expected the large object to be skipped %v %d", err, c.Len())
+	}
+
+	// a smaller limit on open evicts the oldest entries by modification time
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(git.LoosePath(objects[2].Hash()))), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = cache.Open(dir, looseSize(t, objects[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Object(objects[0].Hash(), 0); !ok || c.Len() != 1 {
+		t.Fatalf("Expected only the most recent object got %d entries", c.Len())
+	}
+}
@@ -0,0 +1,143 @@
+package checker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/georlav/githunt/internal/client"
+)
+
+// Finding describes an exposure reported by a checker.
+type Finding struct {
+	Checker  string
+	Severity string
+	URL      *url.URL
+	Metadata map[string]string
+	// Evidence holds the exchanges that led to the finding when the client captures them.
+	Evidence []*client.Exchange
+}
+
+// Checker probes a target for a specific exposure.
+type Checker interface {
+	// Name returns a unique identifier for the checker.
+	Name() string
+	// Paths returns the paths that the checker requests relative to the target.
+	Paths() []string
+	// Check probes the target and returns any findings.
+	Check(ctx context.Context, target *url.URL) ([]Finding, error)
+}
+
+// Registry holds a set of checkers that run against every target.
+type Registry struct {
+	mu       sync.RWMutex
+	checkers []Checker
+	names    map[string]struct{}
+}
+
+func NewRegistry(checkers ...Checker) (*Registry, error) {
+	r := Registry{
+		names: make(map[string]struct{}),
+	}
+
+	if err := r.Register(checkers...); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// Register adds checkers to the registry, names must be unique.
+func (r *Registry) Register(checkers ...Checker) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range checkers {
+		name := checkers[i].Name()
+		if _, ok := r.names[name]; ok {
+			return fmt.Errorf("registering checker %s. Error: already registered", name)
+		}
+
+		r.names[name] = struct{}{}
+		r.checkers = append(r.checkers, checkers[i])
+	}
+
+	return nil
+}
+
+// Checkers returns the registered checkers in registration order.
+func (r *Registry) Checkers() []Checker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	checkers := make([]Checker, len(r.checkers))
+	copy(checkers, r.checkers)
+
+	return checkers
+}
+
+// Check runs every registered checker against the target and collects the findings.
+func (r *Registry) Check(ctx context.Context, target *url.URL) ([]Finding, error) {
+	var (
+		findings []Finding
+		errs     []error
+	)
+
+	for _, c := range r.Checkers() {
+		f, err := c.Check(ctx, target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name(), err))
+		}
+		findings = append(findings, f...)
+	}
+
+	return findings, errors.Join(errs...)
+}
+
+var (
+	unsafeName   = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	defaultPorts = map[string]string{"http": "80", "https": "443"}
+)
+
+// maxPathName bounds the part of a target directory name taken from the path, the path hash keeps it unique.
+const maxPathName = 64
+
+// TargetDir returns a file name that identifies a target by its scheme, host, port and path, so that targets
+// sharing a host never share a directory.
+func TargetDir(target *url.URL) string {
+	scheme := target.Scheme
+	if scheme == "" {
+		scheme = "target"
+	}
+
+	parts := []string{scheme, target.Hostname()}
+	if port := target.Port(); port != "" {
+		parts = append(parts, port)
+	} else if port, ok := defaultPorts[scheme]; ok {
+		parts = append(parts, port)
+	}
+
+	if p := strings.Trim(target.Opaque+target.EscapedPath(), "/"); p != "" {
+		sum := sha256.Sum256([]byte(p))
+		if len(p) > maxPathName {
+			p = p[:maxPathName]
+		}
+		parts = append(parts, p, hex.EncodeToString(sum[:4]))
+	}
+
+	return unsafeName.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+// JoinPath returns a copy of the target with path appended to its path.
+func JoinPath(target *url.URL, path string) *url.URL {
+	u := *target
+	u.Path += path
+
+	return &u
+}
@@ -0,0 +1,101 @@
+package checker_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/georlav/githunt/internal/checker"
+)
+
+type stubChecker struct {
+	name string
+	path string
+	err  error
+}
+
+func (s stubChecker) Name() string    { return s.name }
+func (s stubChecker) Paths() []string { return []string{s.path} }
+
+func (s stubChecker) Check(_ context.Context, target *url.URL) ([]checker.Finding, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return []checker.Finding{{Checker: s.name, URL: checker.JoinPath(target, s.path)}}, nil
+}
+
+func TestRegistry_Register(t *testing.T) {
+	_, err := checker.NewRegistry(
+		stubChecker{name: "env", path: "/.env"},
+		stubChecker{name: "env", path: "/.env.bak"},
+	)
+	if err == nil {
+		t.Fatal("Expected duplicate checker names to be rejected")
+	}
+}
+
+func TestRegistry_Check(t *testing.T) {
+	registry, err := checker.NewRegistry(
+		stubChecker{name: "env", path: "/.env"},
+		stubChecker{name: "backup", path: "/backup.zip"},
+		stubChecker{name: "broken", err: errors.New("boom")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := url.Parse("https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := registry.Check(context.Background(), target)
+	if err == nil {
+		t.Fatal("Expected error from broken checker")
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings got %d", len(findings))
+	}
+
+	if findings[0].URL.String() != "https://example.com/.env" || findings[1].URL.String() != "https://example.com/backup.zip" {
+		t.Fatalf("Unexpected findings %v", findings)
+	}
+}
+
+func TestTargetDir(t *testing.T) {
+	dirs := make(map[string]string)
+
+	for _, target := range []string{
+		"http://example.com/",
+		"https://example.com",
+		"https://example.com:8443",
+		"https://example.com/app1",
+		"https://example.com/app2/",
+		"https://example.com/app1/x",
+		"https://example.com/app1_x",
+		"https://example.com/../../etc",
+	} {
+		u, err := url.Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dir := checker.TargetDir(u)
+		if dir == "" || dir == "." || dir == ".." || strings.ContainsAny(dir, `/\:`) {
+			t.Fatalf("Unsafe directory %q for %s", dir, target)
+		}
+		if other, ok := dirs[dir]; ok {
+			t.Fatalf("%s and %s share the directory %s", target, other, dir)
+		}
+		dirs[dir] = target
+	}
+
+	u, _ := url.Parse("https://example.com:443/app1/")
+	if dir := checker.TargetDir(u); dirs[dir] != "https://example.com/app1" {
+		t.Fatalf("Expected the default port and trailing slash to map to the same directory got %s", dir)
+	}
+}
@@ -0,0 +1,353 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/config"
+	"github.com/georlav/githunt/internal/evidence"
+	"github.com/georlav/githunt/internal/metrics"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/utils"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+var (
+	fmtError = color.New(color.FgRed, color.Bold)
+	fmtInfo  = color.New(color.FgGreen, color.Bold)
+)
+
+const banner = `
+  _   o  _|_  |_        ._   _|_
+ (_|  |   |_  | |  |_|  | |   |_  %s
+  _|
+`
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(ctx context.Context, cmd *command, args []string) int
+}
+
+var commands = []*command{
+	{
+		name:        "scan",
+		usage:       "scan [options...]",
+		description: "Check targets for exposed git directories and other template matches.",
+		run:         scanCommand,
+	},
+	{
+		name:        "dump",
+		usage:       "dump [options...]",
+		description: "Download the exposed git directory of targets and recover their objects.",
+		run:         dumpCommand,
+	},
+	{
+		name:        "verify",
+		usage:       "verify [options...] results.jsonl",
+		description: "Re-check the vulnerable targets of a previous scan.",
+		run:         verifyCommand,
+	},
+	{
+		name:        "report",
+		usage:       "report [options...] results.jsonl...",
+		description: "Render stored scan results.",
+		run:         reportCommand,
+	},
+	{
+		name:        "diff",
+		usage:       "diff [options...] old.jsonl new.jsonl | diff -store githunt.db [old-scan new-scan]",
+		description: "Report new exposures, remediated hosts, moved HEAD commits and unreachable hosts between two scans.",
+		run:         diffCommand,
+	},
+	{
+		name:        "store",
+		usage:       "store hosts|scans|results|import [options...] [results.jsonl...]",
+		description: "Query the result database of past scans.",
+		run:         storeCommand,
+	},
+	{
+		name:        "watch",
+		usage:       "watch [options...]",
+		description: "Rescan targets on a schedule and report the hosts that change state.",
+		run:         watchCommand,
+	},
+	{
+		name:        "serve",
+		usage:       "serve [options...]",
+		description: "Run as a daemon that accepts scan jobs over a JSON HTTP API.",
+		run:         serveCommand,
+	},
+	{
+		name:        "coordinate",
+		usage:       "coordinate [options...]",
+		description: "Lease the targets of a scan to agents and merge their results.",
+		run:         coordinateCommand,
+	},
+	{
+		name:        "agent",
+		usage:       "agent -coordinator url [options...]",
+		description: "Scan targets leased from a coordinator.",
+		run:         agentCommand,
+	},
+	{
+		name:        "config",
+		usage:       "config validate [options...]",
+		description: "Report unknown keys and invalid values of a config file.",
+		run:         configCommand,
+	},
+}
+
+var version string
+
+// Run executes the command line and returns the exit code.
+func Run(args []string, ver string) int {
+	version = ver
+
+	// keep the flat invocation working, githunt -url example.com runs a scan
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "-help" {
+		args = append([]string{"scan"}, args...)
+	}
+
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go terminate(cancel)
+
+		return cmd.run(ctx, cmd, args[1:])
+	}
+
+	usage()
+	if args[0] != "help" && args[0] != "-h" && args[0] != "-help" {
+		fmtError.Fprintf(os.Stderr, "Unknown command %s\n", args[0])
+		return 2
+	}
+
+	return 0
+}
+
+// usage prints the list of commands.
+func usage() {
+	fmtInfo.Fprintf(os.Stderr, banner, version)
+	fmt.Fprint(os.Stderr, "Usage: githunt <command> [options...]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprint(os.Stderr, `
+Usage Examples:
+  githunt scan -url example.com
+  githunt scan -urls urls.txt -workers 100 -timeout 30s -output results.jsonl -format jsonl
+  githunt scan -urls urls.txt -shard 3/10 -shuffle
+  githunt scan -urls urls.txt -checks git-head,git-smart-http
+  githunt dump -url example.com -dir dumps -checkout
+  githunt dump -urls urls.txt -secrets -output dumps.jsonl
+  githunt dump -url example.com -deployed -output dumps.jsonl
+  githunt verify results.jsonl
+  githunt report results.jsonl
+  githunt report -format sarif -output results.sarif results.jsonl
+  githunt report -clusters results.jsonl
+  githunt scan -urls urls.txt -store githunt.db
+  githunt store hosts -store githunt.db -status fixed -changed-in last
+  githunt diff -store githunt.db
+  githunt watch -urls urls.txt -store githunt.db -schedule "0 3 * * 0" -recheck @hourly -notify events.jsonl
+  githunt scan -urls urls.txt -notify slack+https://hooks.slack.com/services/T000/B000/XXXX
+  githunt serve -listen :8080 -token s3cret -state-dir /var/lib/githunt
+  githunt coordinate -urls urls.txt -listen :8081 -token s3cret -output results.jsonl -format jsonl
+  githunt agent -coordinator http://10.0.0.1:8081 -token s3cret -workers 200
+
+Run githunt <command> -h for the options of a command.
+`)
+}
+
+// newFlagSet creates the flag set of a command with its help output.
+func newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmtInfo.Fprintf(fs.Output(), banner, version)
+		fmt.Fprintf(fs.Output(), "Usage: githunt %s\n\n%s\n\nOptions:\n", cmd.usage, cmd.description)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// networkFlags are shared by every command that sends requests.
+type networkFlags struct {
+	fs      *flag.FlagSet
+	workers *int
+	cpus    *int
+	timeout *time.Duration
+	proxy   *string
+	config  *string
+	profile *string
+	metrics *string
+}
+
+func addNetworkFlags(fs *flag.FlagSet, workers int) *networkFlags {
+	return &networkFlags{
+		fs:      fs,
+		workers: fs.Int("workers", workers, "sets the desirable number of http workers"),
+		cpus:    fs.Int("cpus", max(runtime.NumCPU()-1, 1), "sets the maximum number of CPUs that can be utilized"),
+		timeout: fs.Duration("timeout", time.Second*15,
+			`sets a time limit for requests, valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".`,
+		),
+		proxy:   fs.String("proxy", "", "route requests through a http, https or socks5 proxy"),
+		config:  fs.String("config", config.DefaultPath(), "load options from a YAML config file"),
+		profile: fs.String("profile", "", "use a named profile from the config file (stealth, fast, dump)"),
+		metrics: fs.String("metrics-addr", "", "expose Prometheus metrics on this address under /metrics e.g. :9090"),
+	}
+}
+
+// parse parses the arguments, applies the config file to the flags that were not set and starts the
+// metrics listener when requested.
+func (n *networkFlags) parse(ctx context.Context, args []string) error {
+	if err := n.fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := &config.Config{}
+	if *n.config != "" {
+		var err error
+		if cfg, err = config.Load(*n.config); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.Apply(n.fs, *n.profile); err != nil {
+		return err
+	}
+
+	// set the maximum number of CPUs that can be utilized
+	runtime.GOMAXPROCS(*n.cpus)
+
+	if *n.metrics != "" {
+		return metrics.Serve(ctx, *n.metrics)
+	}
+
+	return nil
+}
+
+// client creates the http client from the network flags.
+func (n *networkFlags) client(options ...client.Option) (*client.Client, error) {
+	proxyURL, err := config.ProxyURL(*n.proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	options = append([]client.Option{
+		client.SetTimeout(*n.timeout),
+		client.SetProxy(proxyURL),
+	}, options...)
+
+	return client.NewClient(options...), nil
+}
+
+// evidenceFlags save the exchanges behind findings.
+type evidenceFlags struct {
+	dir  *string
+	body *int64
+	har  *bool
+}
+
+func addEvidenceFlags(fs *flag.FlagSet) *evidenceFlags {
+	return &evidenceFlags{
+		dir:  fs.String("evidence-dir", "", "save the raw requests, responses and certificates of vulnerable targets in this directory"),
+		body: fs.Int64("evidence-body", 16<<10, "maximum number of response body bytes saved as evidence"),
+		har:  fs.Bool("evidence-har", false, "also bundle the evidence of each target as a HAR file"),
+	}
+}
+
+// options returns the client options that capture evidence.
+func (e *evidenceFlags) options() []client.Option {
+	if *e.dir == "" {
+		return nil
+	}
+
+	return []client.Option{client.SetEvidence(max(*e.body, 1))}
+}
+
+// save writes the evidence of a vulnerable result and references it from its records.
+func (e *evidenceFlags) save(result worker.Result, records []results.Record) error {
+	if *e.dir == "" || !result.Vulnerable {
+		return nil
+	}
+
+	w := evidence.NewWriter(*e.dir, evidence.SetHAR(*e.har), evidence.SetVersion(version))
+
+	dir, err := w.Save(result.URL, result.Findings, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		records[i].Evidence = dir
+	}
+
+	return nil
+}
+
+// targetFlags split and reorder the targets of a command.
+type targetFlags struct {
+	shard   *string
+	shuffle *bool
+	seed    *int64
+}
+
+func addTargetFlags(fs *flag.FlagSet) *targetFlags {
+	return &targetFlags{
+		shard:   fs.String("shard", "", "scan only the k-th of n shards of the targets e.g. 3/10"),
+		shuffle: fs.Bool("shuffle", false, "scan the targets in random order, the whole list is loaded in memory"),
+		seed:    fs.Int64("seed", 0, "seed of -shuffle, the same seed gives the same order (default: random)"),
+	}
+}
+
+// load streams the targets of the shard in the requested order.
+func (t *targetFlags) load(ctx context.Context, filename, target string) (<-chan worker.Target, utils.Shard, error) {
+	shard, err := utils.ParseShard(*t.shard)
+	if err != nil {
+		return nil, shard, err
+	}
+
+	targets, err := utils.LoadTargetURLs(ctx, filename, target)
+	if err != nil {
+		return nil, shard, err
+	}
+	targets = utils.ShardTargets(ctx, targets, shard)
+
+	if *t.shuffle {
+		if *t.seed == 0 {
+			*t.seed = time.Now().UnixNano()
+		}
+		fmtInfo.Printf("Shuffling targets with seed %d\n", *t.seed)
+		targets = utils.ShuffleTargets(ctx, targets, *t.seed)
+	}
+
+	return targets, shard, nil
+}
+
+// terminate on SIGINT or SIGTERM.
+func terminate(cancel context.CancelFunc) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+	cancel()
+}
@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/georlav/githunt/internal/cluster"
+	"github.com/georlav/githunt/internal/report"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/utils"
+)
+
+// EnvClusterToken holds the token shared by the coordinator and its agents when -token is not set.
+const EnvClusterToken = "GITHUNT_CLUSTER_TOKEN"
+
+//nolint:cyclop
+func coordinateCommand(ctx context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	target := fs.String("url", "", "check single url")
+	targets := fs.String("urls", "", "file containing multiple urls (one per line)")
+	listen := fs.String("listen", "127.0.0.1:8081", "address agents connect to, other than loopback addresses require -token")
+	token := fs.String("token", os.Getenv(EnvClusterToken), "require this bearer token from agents (default: $"+EnvClusterToken+")")
+	batchSize := fs.Int("batch-size", 100, "number of targets leased to an agent at once")
+	leaseTimeout := fs.Duration("lease-timeout", 5*time.Minute, "reassign leases that are not completed or renewed in time")
+	output := fs.String("output", "", "save results in a file")
+	format := fs.String("format", formatText, "output format, text saves vulnerable urls, jsonl saves every result, sarif, csv or html render a report")
+	db := fs.String("store", "", "record the results in a result database, see githunt store")
+	order := addTargetFlags(fs)
+	_ = fs.Parse(args)
+
+	if *targets == "" && *target == "" {
+		fs.Usage()
+		fmtError.Fprint(os.Stderr, "You need to specify a target\n")
+		return 2
+	}
+
+	// anyone that reaches the coordinator could lease the targets and submit results
+	if *token == "" && !loopback(*listen) {
+		fmtError.Fprintf(os.Stderr, "Listening on %s requires a -token or $%s\n", *listen, EnvClusterToken)
+		return 2
+	}
+
+	targetsCH, _, err := order.load(ctx, *targets, *target)
+	if err != nil {
+		fmtError.Printf("Failed to load targets. Error: %s\n", err)
+		return 1
+	}
+
+	started := time.Now()
+
+	rec, err := openRecorder(*db, started)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer rec.close()
+
+	recordsCH := make(chan results.Record)
+	saved, err := saveResults(ctx, recordsCH, *output, *format, report.SetVersion(version))
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer func() {
+		close(recordsCH)
+		<-saved
+	}()
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "listening on %s. Error: %s\n", *listen, err)
+		return 1
+	}
+
+	c := cluster.NewCoordinator(ctx, targetsCH,
+		cluster.SetBatchSize(*batchSize),
+		cluster.SetLeaseTimeout(*leaseTimeout),
+		cluster.SetToken(*token),
+	)
+	srv := &http.Server{
+		Handler:           c,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	defer srv.Close()
+
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+		}
+	}()
+
+	fmtInfo.Printf("Waiting for agents on %s\n", l.Addr())
+
+	var (
+		scanned    = make(map[string]struct{})
+		vulnerable = make(map[string]struct{})
+		pending    []results.Record
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return 1
+		case r, ok := <-c.Records():
+			if !ok {
+				elapsed := time.Since(started)
+
+				if err := rec.add(pending...); err != nil {
+					fmtError.Fprintf(os.Stderr, "%s\n", err)
+					return 1
+				}
+
+				// keep answering for a while so that polling agents learn that the scan is finished
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+				}
+				fmtInfo.Printf("Scanned: %d target(s) in %s found: %d vulnerable\n\n",
+					len(scanned), elapsed.String(), len(vulnerable),
+				)
+				return 0
+			}
+
+			scanned[r.Target] = struct{}{}
+			if r.Vulnerable {
+				vulnerable[r.Target] = struct{}{}
+				fmtInfo.Printf("Target: %s is vulnerable (%s).\n", r.URL, r.Checker)
+			}
+			if r.Error != "" {
+				fmtError.Fprintf(os.Stderr, "Request Error: %s\n", r.Error)
+			}
+
+			select {
+			case <-ctx.Done():
+			case recordsCH <- r:
+			}
+
+			// the records of a target are stored together
+			if len(pending) > 0 && pending[0].Target != r.Target {
+				if err := rec.add(pending...); err != nil {
+					fmtError.Fprintf(os.Stderr, "%s\n", err)
+					return 1
+				}
+				pending = pending[:0]
+			}
+			pending = append(pending, r)
+		}
+	}
+}
+
+func agentCommand(ctx context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	coordinator := fs.String("coordinator", "", "url of the coordinator e.g. http://10.0.0.1:8081")
+	hostname, _ := os.Hostname()
+	name := fs.String("name", hostname, "name reported to the coordinator")
+	token := fs.String("token", os.Getenv(EnvClusterToken), "bearer token of the coordinator (default: $"+EnvClusterToken+")")
+	leases := fs.Int("leases", 2, "number of batches scanned at once")
+	urlPath := fs.String("path", "/.git/config", "sets the path to .git config file")
+	templatesPath := fs.String("templates", "", "load additional check templates from a YAML file or directory")
+	checks := fs.String("checks", "", checksUsage)
+	network := addNetworkFlags(fs, 50)
+
+	if err := network.parse(ctx, args); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	if *coordinator == "" {
+		fs.Usage()
+		fmtError.Fprint(os.Stderr, "You need to specify a coordinator\n")
+		return 2
+	}
+
+	u, err := utils.ParseTarget(*coordinator)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	c, err := network.client()
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	registry, err := loadRegistry(c, *urlPath, *templatesPath, *checks)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	a := cluster.NewAgent(u, registry,
+		cluster.SetAgentName(*name),
+		cluster.SetAgentToken(*token),
+		cluster.SetWorkers(*network.workers),
+		cluster.SetLeases(*leases),
+	)
+
+	fmtInfo.Printf("Agent %s working for %s\n", *name, u)
+
+	var scanned, vulnerable int
+
+	err = a.Run(ctx, func(lease cluster.Lease, records []results.Record) {
+		scanned += len(lease.Targets)
+		for i := range records {
+			if records[i].Vulnerable {
+				vulnerable++
+				fmtInfo.Printf("Target: %s is vulnerable (%s).\n", records[i].URL, records[i].Checker)
+			}
+		}
+	})
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	fmtInfo.Printf("Scanned: %d target(s) found: %d vulnerable finding(s)\n\n", scanned, vulnerable)
+
+	return 0
+}
+
+// loopback reports whether a listen address only accepts local connections.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
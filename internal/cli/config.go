@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/georlav/githunt/internal/config"
+)
+
+func configCommand(_ context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	path := fs.String("config", config.DefaultPath(), "config file to validate")
+
+	if len(args) == 0 || args[0] != "validate" {
+		fs.Usage()
+		return 2
+	}
+	_ = fs.Parse(args[1:])
+
+	if *path == "" {
+		fmtError.Fprint(os.Stderr, "You need to specify a config file\n")
+		return 2
+	}
+
+	if _, err := config.Load(*path); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	fmtInfo.Printf("%s is valid\n", *path)
+
+	return 0
+}
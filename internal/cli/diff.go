@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/store"
+)
+
+// changeTitles are the section titles of the text diff.
+var changeTitles = map[string]string{
+	results.ChangeNew:         "New exposures",
+	results.ChangeRemediated:  "Remediated",
+	results.ChangeHeadChanged: "HEAD commit changed",
+	results.ChangeUnreachable: "Became unreachable",
+}
+
+func diffCommand(_ context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	db := fs.String("store", "", "compare two scans of a result database instead of two files, defaults to the last two scans")
+	format := fs.String("format", "text", "output format, text or jsonl")
+	_ = fs.Parse(args)
+
+	var (
+		before, after []results.Record
+		err           error
+	)
+
+	switch {
+	case *db != "":
+		before, after, err = loadScans(*db, fs.Args())
+	case fs.NArg() == 2:
+		if before, err = results.ReadFile(fs.Arg(0)); err == nil {
+			after, err = results.ReadFile(fs.Arg(1))
+		}
+	default:
+		fs.Usage()
+		fmtError.Fprint(os.Stderr, "You need to specify two results files or a result database\n")
+		return 2
+	}
+
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	if !hasHead(before) && !hasHead(after) {
+		fmtError.Fprint(os.Stderr, "Neither result set has HEAD commits, scan with -checks git-head to report HEAD changes\n")
+	}
+
+	changes := results.Diff(before, after)
+
+	if *format == "jsonl" {
+		enc := json.NewEncoder(os.Stdout)
+		for i := range changes {
+			if err := enc.Encode(changes[i]); err != nil {
+				fmtError.Fprintf(os.Stderr, "%s\n", err)
+				return 1
+			}
+		}
+		return 0
+	}
+
+	if err := printChanges(changes); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// loadScans reads two scans of a result database, the last two when no IDs are given.
+func loadScans(db string, ids []string) ([]results.Record, []results.Record, error) {
+	st, err := store.Open(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer st.Close()
+
+	switch len(ids) {
+	case 0:
+		scans, err := st.Scans()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(scans) < 2 {
+			return nil, nil, fmt.Errorf("%s needs at least two scans to compare", db)
+		}
+		ids = []string{scans[len(scans)-2].ID, scans[len(scans)-1].ID}
+	case 2:
+		for i := range ids {
+			if ids[i], err = scanID(st, ids[i]); err != nil {
+				return nil, nil, err
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("expected two scan IDs got %d", len(ids))
+	}
+
+	before, err := st.Records(ids[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := st.Records(ids[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return before, after, nil
+}
+
+// hasHead reports whether any finding of records holds the HEAD commit of its target.
+func hasHead(records []results.Record) bool {
+	for i := range records {
+		if records[i].Metadata["head"] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func printChanges(changes []results.Change) error {
+	counts := make(map[string]int)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	for i := range changes {
+		c := changes[i]
+
+		if i == 0 || changes[i-1].Kind != c.Kind {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s\n", changeTitles[c.Kind])
+		}
+		counts[c.Kind]++
+
+		switch c.Kind {
+		case results.ChangeHeadChanged:
+			fmt.Fprintf(w, "  %s\t%s\t->\t%s\n", c.Target, c.Before, c.After)
+		case results.ChangeUnreachable:
+			fmt.Fprintf(w, "  %s\t%s\n", c.Target, c.After)
+		default:
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", c.Target, c.Checker, dash(c.Severity), dash(c.URL))
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmtInfo.Printf("\nNew: %d remediated: %d HEAD changed: %d unreachable: %d\n",
+		counts[results.ChangeNew], counts[results.ChangeRemediated],
+		counts[results.ChangeHeadChanged], counts[results.ChangeUnreachable],
+	)
+
+	return nil
+}
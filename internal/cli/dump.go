@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/georlav/githunt/internal/cache"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/dumper"
+	"github.com/georlav/githunt/internal/secrets"
+)
+
+//nolint:cyclop
+func dumpCommand(ctx context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	target := fs.String("url", "", "dump single url")
+	targets := fs.String("urls", "", "file containing multiple urls (one per line)")
+	gitPath := fs.String("path", "/.git", "sets the path to the .git directory")
+	dir := fs.String("dir", "dumps", "directory where the recovered repositories are saved")
+	checkout := fs.Bool("checkout", false, "write the files of the HEAD commit next to the recovered .git directory")
+	checkoutFiles := fs.Int("checkout-files", 100000, "maximum number of files and directories written by -checkout")
+	checkoutSize := fs.Int64("checkout-size", 1<<30, "maximum total size in bytes of the files written by -checkout")
+	maxSize := fs.Int64("max-size", 256<<20, "maximum size in bytes of a single downloaded file or unpacked object")
+	output := fs.String("output", "", "save a JSON lines summary of every dump in a file")
+	bruteforce := fs.Bool("bruteforce", true, "probe common branch, tag and remote names and git metadata files that are not advertised")
+	wordlist := fs.String("wordlist", "", "probe the paths of this file as well, one path relative to the git directory per line")
+	crawlDepth := fs.Int("crawl-depth", 16, "maximum depth of directory listings that are crawled, 0 disables crawling")
+	crawlSize := fs.Int64("crawl-size", 1<<30, "maximum total size in bytes of the files downloaded from directory listings")
+	smart := fs.Bool("smart", true, "clone the repository through git-upload-pack when the target runs a smart HTTP server")
+	deployed := fs.Bool("deployed", false, "request every file of the index from the webroot and report files served raw or modified")
+	dedupe := fs.Bool("dedupe", true, "download a repository once when targets expose the same HEAD and index")
+	cacheDir := fs.String("cache", defaultCacheDir(), "directory of the object cache shared across dumps and runs, empty disables it")
+	cacheSize := fs.Int64("cache-size", 2<<30, "maximum size in bytes of the object cache, the least recently used objects are evicted")
+	integrity := fs.Bool("integrity", true, "verify the recovered objects and report missing, corrupt and unreachable objects")
+	scanSecrets := fs.Bool("secrets", false, "scan every recovered blob, including the history, for secrets")
+	secretRules := fs.String("secret-rules", "", "load additional secret rules from a YAML file, implies -secrets")
+	order := addTargetFlags(fs)
+	network := addNetworkFlags(fs, 10)
+
+	if err := network.parse(ctx, args); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	if *targets == "" && *target == "" {
+		fs.Usage()
+		fmtError.Fprint(os.Stderr, "You need to specify a target\n")
+		return 0
+	}
+
+	c, err := network.client(client.SetMaxBodySize(*maxSize))
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	var enc *json.Encoder
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmtError.Fprintf(os.Stderr, "creating file %s. Error: %s\n", *output, err)
+			return 1
+		}
+		defer f.Close()
+		enc = json.NewEncoder(f)
+	}
+
+	targetsCH, _, err := order.load(ctx, *targets, *target)
+	if err != nil {
+		fmtError.Printf("Failed to load targets. Error: %s\n", err)
+		return 1
+	}
+
+	options := []dumper.Option{
+		dumper.SetWorkers(*network.workers),
+		dumper.SetMaxSize(*maxSize),
+		dumper.SetGitPath(*gitPath),
+		dumper.SetCheckout(*checkout),
+		dumper.SetCheckoutLimits(*checkoutFiles, *checkoutSize),
+		dumper.SetCrawl(*crawlDepth, *crawlSize),
+		dumper.SetSmart(*smart),
+		dumper.SetDeployed(*deployed),
+		dumper.SetDedupe(*dedupe),
+		dumper.SetIntegrity(*integrity),
+	}
+
+	words := dumper.Wordlist()
+	if *wordlist != "" {
+		custom, err := dumper.LoadWordlist(*wordlist)
+		if err != nil {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+		words = append(words, custom...)
+	}
+	if !*bruteforce {
+		words = nil
+	}
+	options = append(options, dumper.SetWordlist(words))
+
+	if *cacheDir != "" {
+		objects, err := cache.Open(*cacheDir, *cacheSize)
+		if err != nil {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+		options = append(options, dumper.SetCache(objects))
+	}
+
+	if *scanSecrets || *secretRules != "" {
+		scanner, err := loadSecretRules(*secretRules)
+		if err != nil {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+		options = append(options, dumper.SetSecrets(scanner))
+	}
+
+	d := dumper.NewDumper(c, *dir, options...)
+
+	var dumped, failed int
+	for t := range targetsCH {
+		if t.Error != nil {
+			failed++
+			fmtError.Fprintf(os.Stderr, "Target Error: %s\n", t.Error)
+			continue
+		}
+
+		fmtInfo.Printf("Dumping %s\n", t.URL)
+
+		result, err := d.Dump(ctx, t.URL)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return 1
+			}
+			failed++
+			fmtError.Fprintf(os.Stderr, "Dump Error: %s\n", err)
+			continue
+		}
+
+		dumped++
+		if result.Shared != "" {
+			fmtInfo.Printf("Target: %s exposes the same repository as %s, HEAD %s\n", result.Target, result.Shared, dash(result.Head))
+			if err := encode(enc, result); err != nil {
+				fmtError.Fprintf(os.Stderr, "%s\n", err)
+				return 1
+			}
+			continue
+		}
+
+		fmtInfo.Printf("Target: %s recovered %d object(s) %d missing, HEAD %s saved in %s\n",
+			result.Target, result.Objects, len(result.Missing), dash(result.Head), result.Dir,
+		)
+
+		if result.ObjectFormat != "sha1" {
+			fmtInfo.Printf("  %s object format\n", result.ObjectFormat)
+		}
+
+		if result.Cached > 0 {
+			fmtInfo.Printf("  %d object(s) and pack file(s) read from the cache\n", result.Cached)
+		}
+
+		if i := result.Integrity; i != nil {
+			fmtInfo.Printf("  integrity: %d missing %d corrupt %d unreachable, HEAD files %.1f%% (%d/%d), commits %d/%d complete\n",
+				len(i.Missing), len(i.Corrupt), len(i.Unreachable), i.HeadCoverage*100, i.HeadRecovered, i.HeadFiles,
+				len(i.Complete), len(i.Complete)+len(i.Incomplete),
+			)
+		}
+
+		if result.Smart != "" {
+			fmtInfo.Printf("  cloned through the smart HTTP protocol %s\n", result.Smart)
+		}
+
+		if result.Listing {
+			fmtInfo.Printf("  directory listing enabled, crawled %d file(s)\n", result.Crawled)
+		}
+
+		if len(result.Discovered) > 0 {
+			fmtInfo.Printf("  discovered %s\n", strings.Join(result.Discovered, ", "))
+		}
+
+		if len(result.Timeline) > 0 {
+			fmtInfo.Printf("  %d ref update(s) recovered from the reflogs\n", len(result.Timeline))
+		}
+
+		for _, f := range result.Deployed {
+			printer := fmtInfo
+			if f.Sensitive {
+				printer = fmtError
+			}
+			printer.Printf("  %s served %s\n", f.Status, f.URL)
+		}
+
+		for _, secret := range result.Secrets {
+			fmtError.Printf("  %s %s:%d %s introduced in %s\n",
+				secret.Rule, dash(secret.Path), secret.Line, secret.Preview, dash(secret.Commit),
+			)
+		}
+
+		if err := encode(enc, result); err != nil {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+	}
+
+	fmtInfo.Printf("Dumped: %d target(s) failed: %d\n", dumped, failed)
+
+	return 0
+}
+
+// loadSecretRules creates a secret scanner from the builtin rules and the rules in filename.
+func loadSecretRules(filename string) (*secrets.Scanner, error) {
+	rules, err := secrets.Builtin()
+	if err != nil {
+		return nil, err
+	}
+
+	if filename != "" {
+		custom, err := secrets.Load(filename)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, custom...)
+	}
+
+	return secrets.NewScanner(rules...)
+}
+
+// encode saves a dump summary when an output file is set.
+func encode(enc *json.Encoder, result *dumper.Result) error {
+	if enc == nil {
+		return nil
+	}
+
+	if err := enc.Encode(result); err != nil {
+		return fmt.Errorf("saving result %s. Error: %w", result.Target, err)
+	}
+
+	return nil
+}
+
+// defaultCacheDir returns the object cache under the user cache directory, empty when there is none.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "githunt", "objects")
+}
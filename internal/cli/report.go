@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/georlav/githunt/internal/report"
+	"github.com/georlav/githunt/internal/results"
+)
+
+const formatTable = "table"
+
+//nolint:cyclop
+func reportCommand(_ context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	all := fs.Bool("all", false, "include targets without findings")
+	format := fs.String("format", formatTable, "report format, table, sarif, csv or html")
+	output := fs.String("output", "", "write the report to a file instead of stdout")
+	clusters := fs.Bool("clusters", false, "group the targets that expose the same repository, table format only")
+	_ = fs.Parse(args)
+
+	if *format != formatTable && !report.Supported(*format) {
+		fmtError.Fprintf(os.Stderr, "Invalid report format %s\n", *format)
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		fmtError.Fprint(os.Stderr, "You need to specify a results file\n")
+		return 2
+	}
+
+	var records []results.Record
+	for _, f := range fs.Args() {
+		r, err := results.ReadFile(f)
+		if err != nil {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+		records = append(records, r...)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmtError.Fprintf(os.Stderr, "Failed to create %s. Error: %s\n", *output, err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if report.Supported(*format) {
+		if err := report.Write(out, *format, records, report.SetVersion(version), report.SetAll(*all)); err != nil {
+			fmtError.Fprintf(os.Stderr, "Failed to write %s report. Error: %s\n", *format, err)
+			return 1
+		}
+
+		return 0
+	}
+
+	if *clusters {
+		if err := writeClusters(out, records, *all); err != nil {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+
+		return 0
+	}
+
+	var vulnerable, failed int
+	for i := range records {
+		switch {
+		case records[i].Vulnerable:
+			vulnerable++
+		case records[i].Error != "":
+			failed++
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tCHECK\tSEVERITY\tURL\tDETAILS")
+
+	for i := range records {
+		r := records[i]
+		if !r.Vulnerable && !*all {
+			continue
+		}
+
+		details := r.Error
+		if r.Vulnerable {
+			details = formatMetadata(r.Metadata)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Target, dash(r.Checker), dash(r.Severity), dash(r.URL), dash(details))
+	}
+
+	if err := w.Flush(); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	fmtInfo.Printf("\nTargets: %d findings: %d failed: %d\n", len(results.Targets(records)), vulnerable, failed)
+
+	return 0
+}
+
+// writeClusters prints the repositories that are exposed by more than one target, all includes the rest.
+func writeClusters(out io.Writer, records []results.Record, all bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tTARGET\tREMOTE\tHEAD\tINDEX")
+
+	fingerprints := results.Fingerprints(records)
+
+	var shared, targets int
+	for _, c := range results.Clusters(records) {
+		if len(c.Targets) < 2 && !all {
+			continue
+		}
+		if len(c.Targets) > 1 {
+			shared++
+			targets += len(c.Targets)
+		}
+
+		for _, t := range c.Targets {
+			f := fingerprints[t]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.ID, t, dash(f.Remote), dash(f.Head), dash(f.Index))
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmtInfo.Printf("\nRepositories: %d shared by %d target(s)\n", shared, targets)
+
+	return nil
+}
+
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	s := ""
+	for _, k := range keys {
+		if s != "" {
+			s += " "
+		}
+		s += k + "=" + metadata[k]
+	}
+
+	return s
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
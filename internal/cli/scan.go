@@ -0,0 +1,408 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/dumper"
+	"github.com/georlav/githunt/internal/progress"
+	"github.com/georlav/githunt/internal/report"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/sink"
+	"github.com/georlav/githunt/internal/templates"
+	"github.com/georlav/githunt/internal/utils"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+//nolint:cyclop
+func scanCommand(ctx context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	target := fs.String("url", "", "check single url")
+	targets := fs.String("urls", "", "file containing multiple urls (one per line)")
+	urlPath := fs.String("path", "/.git/config", "sets the path to .git config file")
+	templatesPath := fs.String("templates", "", "load additional check templates from a YAML file or directory")
+	checks := fs.String("checks", "", checksUsage)
+	output := fs.String("output", "", "save results in a file")
+	format := fs.String("format", formatText, "output format, text saves vulnerable urls, jsonl saves every result, sarif, csv or html render a report")
+	db := fs.String("store", "", "record the results in a result database, see githunt store")
+	capture := addEvidenceFlags(fs)
+	notify := addSinkFlags(fs)
+	order := addTargetFlags(fs)
+	network := addNetworkFlags(fs, 50)
+
+	if err := network.parse(ctx, args); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	// target is required
+	if *targets == "" && *target == "" {
+		fs.Usage()
+		fmtError.Fprint(os.Stderr, "You need to specify a target\n")
+		return 0
+	}
+
+	// Initialize http client
+	c, err := network.client(capture.options()...)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	registry, err := loadRegistry(c, *urlPath, *templatesPath, *checks)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	// load targets
+	targetsCH, shard, err := order.load(ctx, *targets, *target)
+	if err != nil {
+		fmtError.Printf("Failed to load targets. Error: %s\n", err)
+		return 1
+	}
+
+	// progress is rendered only when stdout is a terminal
+	var (
+		p       = progress.New(os.Stdout)
+		started = time.Now()
+	)
+
+	if *targets != "" && p.Enabled() {
+		go func() {
+			count, err := utils.CountTargets(ctx, *targets, shard)
+			if err == nil {
+				if u, err := utils.ParseTarget(*target); *target != "" && err == nil && shard.Contains(u) {
+					count++
+				}
+				p.SetTotal(count)
+			}
+		}()
+	} else if *target != "" {
+		p.SetTotal(1)
+	}
+
+	p.Start(ctx)
+	defer func() {
+		p.Stop()
+		fmtInfo.Printf("Scanned: %d target(s) in %s found: %d vulnerable\n\n",
+			p.Scanned(),
+			time.Since(started).String(),
+			p.Vulnerable(),
+		)
+	}()
+
+	sinks, err := notify.sinks()
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	// notify sinks about new exposures
+	eventsCH := make(chan sink.Event)
+	sent := sendEvents(ctx, eventsCH, sinks, func(err error) {
+		p.Log(func() {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+		})
+	})
+	defer func() {
+		close(eventsCH)
+		<-sent
+	}()
+
+	emit := func(e sink.Event) {
+		select {
+		case <-ctx.Done():
+		case eventsCH <- e:
+		}
+	}
+
+	rec, err := openRecorder(*db, started)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer rec.close()
+
+	// with a result store only the hosts that change state are reported
+	if len(sinks) > 0 {
+		rec.onEvent(emit)
+	}
+
+	// save results in a file
+	recordsCH := make(chan results.Record)
+	saved, err := saveResults(ctx, recordsCH, *output, *format, report.SetVersion(version))
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer func() {
+		close(recordsCH)
+		<-saved
+	}()
+
+	resultCH := worker.Work(ctx, targetsCH, registry, *network.workers)
+
+	// handle results
+	for result := range resultCH {
+		// handle request errors
+		if result.Error != nil {
+			p.Log(func() {
+				fmtError.Fprintf(os.Stderr, "Request Error: %s\n", result.Error)
+			})
+
+			if strings.Contains(result.Error.Error(), "too many open files") {
+				p.Log(func() {
+					fmtError.Fprintf(os.Stderr, "%s, You need to increase ulimit for open files or decrease number of workers\n", result.Error)
+				})
+				return 1
+			}
+		}
+
+		if result.Vulnerable {
+			p.Log(func() {
+				for _, f := range result.Findings {
+					fmtInfo.Printf("Target: %s is vulnerable (%s).\n", f.URL.String(), f.Checker)
+				}
+			})
+		}
+
+		records := results.FromResult(result, time.Now())
+		if err := capture.save(result, records); err != nil {
+			p.Log(func() {
+				fmtError.Fprintf(os.Stderr, "%s\n", err)
+			})
+		}
+
+		for _, r := range records {
+			select {
+			case <-ctx.Done():
+			case recordsCH <- r:
+			}
+		}
+
+		if e, ok := sink.FromRecords(records); ok && len(sinks) > 0 && !rec.enabled() {
+			emit(e)
+		}
+
+		if err := rec.add(records...); err != nil {
+			p.Log(func() {
+				fmtError.Fprintf(os.Stderr, "%s\n", err)
+			})
+			return 1
+		}
+
+		p.Add(result.Vulnerable, result.Error)
+	}
+
+	return 0
+}
+
+const checksUsage = "also run the builtin git-head, git-listing and git-smart-http checks, comma separated or all"
+
+// loadRegistry registers the builtin checkers and the templates found in templatesPath. The git checkers send
+// requests of their own to every target, they run only when selected by checks.
+func loadRegistry(c *client.Client, urlPath, templatesPath, checks string) (*checker.Registry, error) {
+	tpls, err := loadTemplates(urlPath, templatesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := gitCheckers(c, gitDir(urlPath), checks)
+	if err != nil {
+		return nil, err
+	}
+
+	return checker.NewRegistry(append(templates.NewCheckers(tpls, c), selected...)...)
+}
+
+// gitCheckers returns the git checkers named in a comma separated list, all selects every one.
+func gitCheckers(c *client.Client, dir, checks string) ([]checker.Checker, error) {
+	available := []checker.Checker{
+		dumper.NewHeadChecker(c, dir),
+		dumper.NewListingChecker(c, dir),
+		dumper.NewSmartChecker(c, dir),
+	}
+
+	names := make(map[string]bool)
+	for _, name := range strings.Split(checks, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = true
+		}
+	}
+
+	var selected []checker.Checker
+	for _, ch := range available {
+		if names["all"] || names[ch.Name()] {
+			selected = append(selected, ch)
+		}
+		delete(names, ch.Name())
+	}
+	delete(names, "all")
+
+	for name := range names {
+		return nil, fmt.Errorf("invalid check %s, expected git-head, git-listing, git-smart-http or all", name)
+	}
+
+	return selected, nil
+}
+
+// gitDir returns the git directory of the config path, /.git unless the path points to a config file.
+func gitDir(urlPath string) string {
+	if path.Base(urlPath) == "config" && path.Dir(urlPath) != "/" {
+		return path.Dir(urlPath)
+	}
+
+	return "/.git"
+}
+
+// loadTemplates loads the builtin templates and the templates found in templatesPath.
+func loadTemplates(urlPath, templatesPath string) ([]*templates.Template, error) {
+	tpls, err := templates.Builtin()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := templates.Find(tpls, "git-config"); t != nil {
+		t.SetPaths(urlPath)
+	}
+
+	if templatesPath != "" {
+		custom, err := templates.Load(templatesPath)
+		if err != nil {
+			return nil, err
+		}
+		tpls = append(tpls, custom...)
+	}
+
+	return tpls, nil
+}
+
+// Output formats supported by saveResults, the formats of the report package are supported as well.
+const (
+	formatText  = "text"
+	formatJSONL = "jsonl"
+)
+
+// saveResults writes records to output, the text format saves the urls of vulnerable targets, jsonl saves every
+// record and sarif, csv and html render a report. The returned channel is closed once records is closed and
+// everything is written.
+//
+//nolint:gocognit,cyclop
+func saveResults(
+	ctx context.Context, records <-chan results.Record, output, format string, options ...report.Option,
+) (<-chan struct{}, error) {
+	done := make(chan struct{})
+
+	if format != formatText && format != formatJSONL && !report.Supported(format) {
+		return nil, fmt.Errorf("invalid output format %s", format)
+	}
+
+	var out *os.File
+	if output != "" {
+		var err error
+		if out, err = os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644); err != nil {
+			return nil, fmt.Errorf("opening file %s. Error: %w", output, err)
+		}
+
+		if err := out.Truncate(0); err != nil {
+			return nil, fmt.Errorf("truncating file %s. Error: %w", output, err)
+		}
+	}
+
+	go func() {
+		defer close(done)
+		if out != nil {
+			defer out.Close()
+		}
+
+		enc := json.NewEncoder(out)
+
+		// reports are closed on interruption as well so that the document is complete
+		var reporter report.Reporter
+		if out != nil && report.Supported(format) {
+			reporter, _ = report.New(format, out, options...)
+			defer func() {
+				if err := reporter.Close(); err != nil {
+					panic(fmt.Sprintf("Failed to write %s report. Error: %s\n", format, err))
+				}
+			}()
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case r, ok := <-records:
+				if !ok {
+					return
+				}
+
+				// without an output file records are drained
+				if out == nil {
+					continue
+				}
+
+				var err error
+				switch {
+				case reporter != nil:
+					err = reporter.Add(r)
+				case format == formatJSONL:
+					err = enc.Encode(r)
+				case r.Vulnerable:
+					_, err = out.WriteString(r.URL + "\n")
+				}
+
+				if err != nil {
+					panic(fmt.Sprintf("Failed to save result %s. Error: %s\n", r.Target, err))
+				}
+			}
+		}
+	}()
+
+	return done, nil
+}
+
+// sendEvents delivers events to the sinks until events is closed, the sinks are then flushed and closed. Delivery
+// errors are passed to onError. The returned channel is closed once every sink is closed.
+func sendEvents(ctx context.Context, events <-chan sink.Event, sinks []sink.Sink, onError func(error)) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		// batched events are delivered even when the scan is interrupted
+		defer func() {
+			closing, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+			defer cancel()
+
+			if err := sink.CloseAll(closing, sinks); err != nil {
+				onError(err)
+			}
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+
+				if err := sink.SendAll(ctx, sinks, []sink.Event{e}); err != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+
+	return done
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/georlav/githunt/internal/server"
+)
+
+// EnvAPIToken holds the bearer token of the job API when -token is not set.
+const EnvAPIToken = "GITHUNT_API_TOKEN"
+
+func serveCommand(ctx context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	listen := fs.String("listen", "127.0.0.1:8080", "address of the job API, other than loopback addresses require -token")
+	stateDir := fs.String("state-dir", "githunt-state", "directory where jobs and their results are persisted")
+	token := fs.String("token", os.Getenv(EnvAPIToken), "require this bearer token on every request (default: $"+EnvAPIToken+")")
+	urlPath := fs.String("path", "/.git/config", "sets the path to .git config file")
+	templatesPath := fs.String("templates", "", "load additional check templates from a YAML file or directory")
+	network := addNetworkFlags(fs, 50)
+
+	if err := network.parse(ctx, args); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	// anyone that reaches the API could scan any target through this host
+	if *token == "" && !loopback(*listen) {
+		fmtError.Fprintf(os.Stderr, "Listening on %s requires a -token or $%s\n", *listen, EnvAPIToken)
+		return 2
+	}
+
+	c, err := network.client()
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	tpls, err := loadTemplates(*urlPath, *templatesPath)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	manager, err := server.NewManager(*stateDir, c, tpls, *network.workers)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "listening on %s. Error: %s\n", *listen, err)
+		return 1
+	}
+
+	srv := &http.Server{
+		Handler:           server.NewServer(manager, *token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go manager.Run(ctx)
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	fmtInfo.Printf("Listening on %s, jobs are persisted in %s\n", l.Addr(), *stateDir)
+
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	return 0
+}
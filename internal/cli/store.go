@@ -0,0 +1,313 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/sink"
+	"github.com/georlav/githunt/internal/store"
+)
+
+// dateLayouts are accepted by the date flags.
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"}
+
+func storeCommand(_ context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	db := fs.String("store", "githunt.db", "result database")
+	status := fs.String("status", "", "hosts: only hosts with this status, one of vulnerable, fixed, clean, unreachable")
+	since := fs.String("since", "", "hosts: only hosts that changed to their status at or after this date e.g. 2024-05-01")
+	until := fs.String("until", "", "hosts: only hosts that changed to their status before this date")
+	changedIn := fs.String("changed-in", "", "hosts: only hosts that changed status in this scan, last selects the latest scan")
+	scan := fs.String("scan", "last", "results: the scan to print")
+	format := fs.String("format", "text", "output format of hosts and scans, text or jsonl")
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Usage()
+		return 2
+	}
+	action := args[0]
+	_ = fs.Parse(args[1:])
+
+	st, err := store.Open(*db)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer st.Close()
+
+	switch action {
+	case "hosts":
+		q := store.Query{Status: *status}
+		if q.Since, err = parseDate(*since); err == nil {
+			q.Until, err = parseDate(*until)
+		}
+		if err == nil {
+			q.ChangedIn, err = scanID(st, *changedIn)
+		}
+		if err == nil {
+			err = printHosts(st, q, *format)
+		}
+	case "scans":
+		err = printScans(st, *format)
+	case "results":
+		var id string
+		if id, err = scanID(st, *scan); err == nil {
+			err = printRecords(st, id)
+		}
+	case "import":
+		err = importResults(st, fs.Args())
+	default:
+		fs.Usage()
+		fmtError.Fprintf(os.Stderr, "Unknown action %s\n", action)
+		return 2
+	}
+
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func printHosts(st *store.Store, q store.Query, format string) error {
+	hosts, err := st.Hosts(q)
+	if err != nil {
+		return err
+	}
+
+	if format == "jsonl" {
+		enc := json.NewEncoder(os.Stdout)
+		for i := range hosts {
+			if err := enc.Encode(hosts[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tSTATUS\tSINCE\tFIRST SEEN\tLAST SEEN\tCHECKS\tDETAILS")
+
+	for i := range hosts {
+		h := hosts[i]
+
+		details := h.Error
+		if details == "" {
+			details = formatMetadata(h.Metadata)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			h.Target, h.Status, formatDate(&h.Since), formatDate(h.FirstSeen), formatDate(h.LastSeen),
+			dash(strings.Join(h.Checkers, ",")), dash(details),
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmtInfo.Printf("\nHosts: %d\n", len(hosts))
+
+	return nil
+}
+
+func printScans(st *store.Store, format string) error {
+	scans, err := st.Scans()
+	if err != nil {
+		return err
+	}
+
+	if format == "jsonl" {
+		enc := json.NewEncoder(os.Stdout)
+		for i := range scans {
+			if err := enc.Encode(scans[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tFINISHED\tTARGETS")
+
+	for i := range scans {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n",
+			scans[i].ID, formatDate(&scans[i].Started), formatDate(scans[i].Finished), scans[i].Targets,
+		)
+	}
+
+	return w.Flush()
+}
+
+func printRecords(st *store.Store, id string) error {
+	records, err := st.Records(id)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for i := range records {
+		if err := enc.Encode(records[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importResults stores each JSON lines file as a scan.
+func importResults(st *store.Store, files []string) error {
+	if len(files) == 0 {
+		return errors.New("you need to specify a results file")
+	}
+
+	for _, f := range files {
+		records, err := results.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		started := records[0].Time
+		for i := range records {
+			if records[i].Time.Before(started) {
+				started = records[i].Time
+			}
+		}
+
+		session, err := st.Begin(started)
+		if err != nil {
+			return err
+		}
+		if err := session.Add(records...); err != nil {
+			return err
+		}
+		if err := session.Close(); err != nil {
+			return err
+		}
+
+		fmtInfo.Printf("Imported %s as scan %s\n", f, session.ID())
+	}
+
+	return nil
+}
+
+// scanID resolves last to the ID of the latest scan.
+func scanID(st *store.Store, id string) (string, error) {
+	if id != "last" {
+		return id, nil
+	}
+
+	scan, err := st.LastScan()
+	if err != nil {
+		return "", err
+	}
+
+	return scan.ID, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %s, expected e.g. 2024-05-01 or %s", s, time.RFC3339)
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// recorder saves the results of a command in the result database when -store is set.
+type recorder struct {
+	store   *store.Store
+	session *store.Session
+	notify  bool
+}
+
+// openRecorder starts recording a scan, the recorder is a no-op when path is empty.
+func openRecorder(path string, started time.Time) (*recorder, error) {
+	if path == "" {
+		return &recorder{}, nil
+	}
+
+	st, err := store.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := st.Begin(started)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+
+	return &recorder{store: st, session: session}, nil
+}
+
+func (r *recorder) enabled() bool {
+	return r.session != nil
+}
+
+// onEvent reports the hosts that change state, new exposures are written and reported right away.
+func (r *recorder) onEvent(fn func(sink.Event)) {
+	if r.session == nil {
+		return
+	}
+
+	r.notify = true
+	r.session.OnChange(func(c store.Change) {
+		if e, ok := sink.FromChange(r.session.ID(), c); ok {
+			fn(e)
+		}
+	})
+}
+
+func (r *recorder) add(records ...results.Record) error {
+	if r.session == nil {
+		return nil
+	}
+
+	if err := r.session.Add(records...); err != nil {
+		return err
+	}
+
+	if r.notify && len(records) > 0 && records[0].Vulnerable {
+		return r.session.Flush()
+	}
+
+	return nil
+}
+
+func (r *recorder) close() {
+	if r.session == nil {
+		return
+	}
+
+	if err := r.session.Close(); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+	}
+	if err := r.store.Close(); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+	}
+}
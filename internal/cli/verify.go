@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/georlav/githunt/internal/report"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/utils"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+func verifyCommand(ctx context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	urlPath := fs.String("path", "/.git/config", "sets the path to .git config file")
+	templatesPath := fs.String("templates", "", "load additional check templates from a YAML file or directory")
+	checks := fs.String("checks", "", checksUsage)
+	output := fs.String("output", "", "save the verified results in a file")
+	format := fs.String("format", formatJSONL, "output format, text saves vulnerable urls, jsonl saves every result, sarif, csv or html render a report")
+	db := fs.String("store", "", "record the results in a result database, see githunt store")
+	capture := addEvidenceFlags(fs)
+	network := addNetworkFlags(fs, 50)
+
+	if err := network.parse(ctx, args); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		fmtError.Fprint(os.Stderr, "You need to specify a results file\n")
+		return 2
+	}
+
+	records, err := results.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	// only targets that were reported as vulnerable are re-checked
+	var vulnerable []results.Record
+	for i := range records {
+		if records[i].Vulnerable {
+			vulnerable = append(vulnerable, records[i])
+		}
+	}
+	targets := results.Targets(vulnerable)
+
+	c, err := network.client(capture.options()...)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	registry, err := loadRegistry(c, *urlPath, *templatesPath, *checks)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	rec, err := openRecorder(*db, time.Now())
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer rec.close()
+
+	recordsCH := make(chan results.Record)
+	saved, err := saveResults(ctx, recordsCH, *output, *format, report.SetVersion(version))
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer func() {
+		close(recordsCH)
+		<-saved
+	}()
+
+	var stillVulnerable, fixed, failed int
+
+	resultCH := worker.Work(ctx, utils.LoadTargetList(ctx, targets), registry, *network.workers)
+	for result := range resultCH {
+		switch {
+		case result.Vulnerable:
+			stillVulnerable++
+			fmtError.Printf("Target: %s is still vulnerable.\n", result.URL)
+		case result.Error != nil:
+			failed++
+			fmtError.Fprintf(os.Stderr, "Target: %s could not be verified. Error: %s\n", result.URL, result.Error)
+		default:
+			fixed++
+			fmtInfo.Printf("Target: %s is fixed.\n", result.URL)
+		}
+
+		records := results.FromResult(result, time.Now())
+		if err := capture.save(result, records); err != nil {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+		}
+
+		for _, r := range records {
+			select {
+			case <-ctx.Done():
+			case recordsCH <- r:
+			}
+		}
+
+		if err := rec.add(records...); err != nil {
+			fmtError.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+	}
+
+	fmtInfo.Printf("Verified: %d target(s), %d still vulnerable, %d fixed, %d failed\n",
+		len(targets), stillVulnerable, fixed, failed,
+	)
+
+	return 0
+}
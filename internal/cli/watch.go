@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/georlav/githunt/internal/sink"
+	"github.com/georlav/githunt/internal/store"
+	"github.com/georlav/githunt/internal/watch"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+// sinkFlags collects the repeated -notify flags.
+type sinkFlags []string
+
+func (s *sinkFlags) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *sinkFlags) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// addSinkFlags registers -notify on the flag set.
+func addSinkFlags(fs *flag.FlagSet) *sinkFlags {
+	var specs sinkFlags
+	fs.Var(&specs, "notify", "send events to a sink, repeatable: -, a file, webhook+https://..., slack+https://..., discord+https://..., teams+https://... or smtp://host:port?from=...&to=...")
+
+	return &specs
+}
+
+// sinks creates the sinks, with fallback when no -notify flag was given.
+func (s *sinkFlags) sinks(fallback ...string) ([]sink.Sink, error) {
+	specs := *s
+	if len(specs) == 0 {
+		specs = fallback
+	}
+
+	sinks := make([]sink.Sink, 0, len(specs))
+	for _, spec := range specs {
+		sk, err := sink.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sk)
+	}
+
+	return sinks, nil
+}
+
+//nolint:cyclop
+func watchCommand(ctx context.Context, cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	target := fs.String("url", "", "watch single url")
+	targets := fs.String("urls", "", "file containing multiple urls (one per line)")
+	urlPath := fs.String("path", "/.git/config", "sets the path to .git config file")
+	templatesPath := fs.String("templates", "", "load additional check templates from a YAML file or directory")
+	checks := fs.String("checks", "", checksUsage)
+	db := fs.String("store", "githunt.db", "result database that keeps the state of the hosts")
+	schedule := fs.String("schedule", "@daily", "cron expression of full scans e.g. \"0 3 * * 0\", @daily or \"@every 12h\"")
+	recheck := fs.String("recheck", "@hourly", "cron expression of the rechecks of vulnerable hosts")
+	notify := addSinkFlags(fs)
+	order := addTargetFlags(fs)
+	network := addNetworkFlags(fs, 50)
+
+	if err := network.parse(ctx, args); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	if *targets == "" && *target == "" {
+		fs.Usage()
+		fmtError.Fprint(os.Stderr, "You need to specify a target\n")
+		return 2
+	}
+
+	full, err := watch.ParseSchedule(*schedule)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	rechecks, err := watch.ParseSchedule(*recheck)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	sinks, err := notify.sinks("-")
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	c, err := network.client()
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	registry, err := loadRegistry(c, *urlPath, *templatesPath, *checks)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	st, err := store.Open(*db)
+	if err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer st.Close()
+
+	load := func(ctx context.Context) (<-chan worker.Target, error) {
+		targetsCH, _, err := order.load(ctx, *targets, *target)
+		return targetsCH, err
+	}
+
+	w := watch.NewWatcher(st, registry, load,
+		watch.SetSchedule(full),
+		watch.SetRecheck(rechecks),
+		watch.SetSinks(sinks...),
+		watch.SetWorkers(*network.workers),
+		watch.SetLogger(func(format string, args ...any) {
+			fmtInfo.Fprintf(os.Stderr, format, args...)
+		}),
+	)
+
+	if err := w.Run(ctx); err != nil {
+		fmtError.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	return 0
+}
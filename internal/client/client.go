@@ -7,12 +7,29 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/georlav/githunt/internal/metrics"
 )
 
 type Client struct {
-	handle *http.Client
+	handle      *http.Client
+	maxBodySize int64
+	// evidenceBodySize enables the capture of exchanges when positive.
+	evidenceBodySize int64
+}
+
+// Response holds the parts of an http response that checks match against.
+type Response struct {
+	URL        *url.URL
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Exchange is set when evidence capture is enabled.
+	Exchange *Exchange
 }
 
 func NewClient(options ...Option) *Client {
@@ -28,6 +45,7 @@ func NewClient(options ...Option) *Client {
 			},
 			Timeout: time.Second * 15,
 		},
+		maxBodySize: 5 << 20,
 	}
 
 	for i := range options {
@@ -37,25 +55,73 @@ func NewClient(options ...Option) *Client {
 	return &client
 }
 
-// Checks check and verify if a target is vulnerable.
-func (c *Client) CheckGit(ctx context.Context, u *url.URL) (bool, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+// Do sends a request and reads up to the maximum body size of the response.
+func (c *Client) Do(ctx context.Context, method string, u *url.URL, header http.Header) (*Response, error) {
+	return c.Send(ctx, method, u, header, nil)
+}
+
+// Send is like Do but sends body as the request body.
+func (c *Client) Send(ctx context.Context, method string, u *url.URL, header http.Header, body []byte) (*Response, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
 	if err != nil {
-		return false, fmt.Errorf("creating request. Error: %w", err)
+		return nil, fmt.Errorf("creating request. Error: %w", err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	var rec *recorder
+	if c.evidenceBodySize > 0 {
+		rec = newRecorder(req)
+		req = req.WithContext(httptrace.WithClientTrace(ctx, rec.trace()))
 	}
 
+	started := time.Now()
+	defer metrics.RequestDuration.ObserveSince(started)
+
 	resp, err := c.handle.Do(req)
 	if err != nil {
-		return false, fmt.Errorf("sending request. Error: %w", err)
+		metrics.Errors.Inc(metrics.ErrorClass(err))
+		return nil, fmt.Errorf("sending request. Error: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		b, err := io.ReadAll(resp.Body)
-		if err == nil && bytes.Contains(b, []byte("[core]")) {
-			return true, nil
-		}
+	metrics.Responses.Inc(strconv.Itoa(resp.StatusCode/100) + "xx")
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBodySize))
+	metrics.BytesDownloaded.Add(float64(len(b)))
+	if err != nil {
+		metrics.Errors.Inc(metrics.ErrorClass(err))
+		return nil, fmt.Errorf("reading response. Error: %w", err)
+	}
+
+	response := Response{
+		URL:        u,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       b,
+	}
+	if rec != nil {
+		response.Exchange = rec.finish(req, resp, b, c.evidenceBodySize, c.proxied(req))
 	}
 
-	return false, nil
+	return &response, nil
+}
+
+// proxied reports whether the request was routed through a proxy.
+func (c *Client) proxied(req *http.Request) bool {
+	t, ok := c.handle.Transport.(*http.Transport)
+	if !ok || t.Proxy == nil {
+		return false
+	}
+
+	u, err := t.Proxy(req)
+
+	return err == nil && u != nil
 }
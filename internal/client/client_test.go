@@ -13,7 +13,7 @@ import (
 	"github.com/georlav/githunt/internal/client"
 )
 
-func TestClient_CheckGit(t *testing.T) {
+func TestClient_Do(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Query().Get("result") {
 		case "found":
@@ -44,23 +44,24 @@ func TestClient_CheckGit(t *testing.T) {
 	testsCases := []struct {
 		description string
 		url         string
-		vulnerable  bool
+		status      int
+		body        string
 		err         string
 	}{
 		{
-			description: "Should find a vulnerable target",
+			description: "Should return response body",
 			url:         ts.URL + "/.git/config?result=found",
-			vulnerable:  true,
+			status:      http.StatusOK,
+			body:        "[core]",
 		},
 		{
-			description: "Should not find a vulnerable target",
+			description: "Should return not found status",
 			url:         ts.URL + "/.git/config?result=notfound",
-			vulnerable:  false,
+			status:      http.StatusNotFound,
 		},
 		{
 			description: "Should fail due to timeout",
 			url:         ts.URL + "/.git/config?result=timeout",
-			vulnerable:  false,
 			err:         "context deadline exceeded",
 		},
 	}
@@ -76,14 +77,124 @@ func TestClient_CheckGit(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			isVulnerable, err := c.CheckGit(context.Background(), u)
-			if err != nil && !strings.Contains(err.Error(), tc.err) {
-				t.Fatal(err)
+			resp, err := c.Do(context.Background(), http.MethodGet, u, nil)
+			if err != nil {
+				if tc.err == "" || !strings.Contains(err.Error(), tc.err) {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if tc.status != 0 && tc.status != resp.StatusCode {
+				t.Fatalf("Expected status %d got %d", tc.status, resp.StatusCode)
 			}
 
-			if tc.vulnerable != isVulnerable {
-				t.Fatal("Unexpected result")
+			if !strings.Contains(string(resp.Body), tc.body) {
+				t.Fatal("Unexpected body")
 			}
 		})
 	}
 }
+
+func TestClient_Evidence(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "test")
+		_, _ = w.Write([]byte("[core]\n\trepositoryformatversion = 0\n"))
+	}))
+	t.Cleanup(ts.Close)
+
+	u, err := url.Parse(ts.URL + "/.git/config?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.NewClient().Do(context.Background(), http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Exchange != nil {
+		t.Fatal("Expected no exchange without evidence capture")
+	}
+
+	c := client.NewClient(client.SetEvidence(6))
+
+	resp, err = c.Do(context.Background(), http.MethodGet, u, http.Header{"User-Agent": {"githunt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := resp.Exchange
+	if e == nil {
+		t.Fatal("Expected an exchange")
+	}
+
+	request := string(e.Request)
+	if !strings.HasPrefix(request, "GET /.git/config?x=1 HTTP/1.1\r\n") || !strings.HasSuffix(request, "\r\n\r\n") {
+		t.Fatalf("Unexpected raw request %q", request)
+	}
+	for _, expected := range []string{"Host: " + u.Host + "\r\n", "User-Agent: githunt\r\n"} {
+		if !strings.Contains(request, expected) {
+			t.Fatalf("Expected %q in raw request %q", expected, request)
+		}
+	}
+
+	if string(e.Body) != "[core]" || !e.Truncated || e.BodySize != len(resp.Body) {
+		t.Fatalf("Unexpected body %q truncated %t size %d", e.Body, e.Truncated, e.BodySize)
+	}
+	if e.Header.Get("X-Served-By") != "test" || e.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected response %d %v", e.StatusCode, e.Header)
+	}
+
+	if e.TLS == nil || len(e.TLS.Certificates) == 0 || e.TLS.Version == "" {
+		t.Fatalf("Expected the certificate chain got %+v", e.TLS)
+	}
+
+	if e.RemoteAddr != u.Host {
+		t.Fatalf("Expected remote address %s got %s", u.Host, e.RemoteAddr)
+	}
+	if e.Started.IsZero() || e.FirstByte.Before(e.Started) || e.Finished.Before(e.FirstByte) {
+		t.Fatalf("Unexpected timestamps %v %v %v", e.Started, e.FirstByte, e.Finished)
+	}
+}
+
+func TestClient_EvidenceRedacted(t *testing.T) {
+	// plain http requests are sent to the proxy with its credentials
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") == "" || r.Header.Get("Cookie") != "session=secret" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	t.Cleanup(proxy.Close)
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL.User = url.UserPassword("user", "secret")
+
+	u, err := url.Parse("http://example.com/.git/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.NewClient(client.SetEvidence(1024), client.SetProxy(proxyURL))
+
+	header := http.Header{"Authorization": {"Bearer secret"}, "Cookie": {"session=secret"}}
+	resp, err := c.Do(context.Background(), http.MethodGet, u, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the credentials to be sent got status %d", resp.StatusCode)
+	}
+
+	request := string(resp.Exchange.Request)
+	if strings.Contains(request, "secret") || strings.Contains(request, "dXNlcjpzZWNyZXQ=") {
+		t.Fatalf("Expected credentials to be redacted in %q", request)
+	}
+	for _, expected := range []string{"\r\nAuthorization: [REDACTED]\r\n", "Proxy-Authorization: [REDACTED]\r\n", "Cookie: [REDACTED]\r\n"} {
+		if !strings.Contains(request, expected) {
+			t.Fatalf("Expected %q in raw request %q", expected, request)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Exchange is the evidence of a single request, it is captured only when the client has an evidence body size.
+type Exchange struct {
+	Method string
+	URL    string
+	// Request is the raw request line and header fields in the order they were written to the connection.
+	Request    []byte
+	RemoteAddr string
+
+	Proto      string
+	Status     string
+	StatusCode int
+	Header     http.Header
+	// Body holds the start of the response body, BodySize is the number of bytes that were read.
+	Body      []byte
+	BodySize  int
+	Truncated bool
+
+	TLS *TLSState
+
+	Started   time.Time
+	Connected time.Time
+	FirstByte time.Time
+	Finished  time.Time
+}
+
+// TLSState describes the TLS connection of an exchange.
+type TLSState struct {
+	Version      string
+	CipherSuite  string
+	ServerName   string
+	Certificates []*x509.Certificate
+}
+
+// credentials are replaced before a request is stored, evidence is shared with people that should not reuse them
+const redacted = "[REDACTED]"
+
+var sensitiveHeaders = map[string]struct{}{
+	"Authorization":       {},
+	"Proxy-Authorization": {},
+	"Cookie":              {},
+}
+
+// recorder collects the parts of an exchange that are only visible while the request is sent.
+type recorder struct {
+	mu       sync.Mutex
+	exchange *Exchange
+	header   bytes.Buffer
+}
+
+func newRecorder(req *http.Request) *recorder {
+	return &recorder{
+		exchange: &Exchange{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Started: time.Now(),
+		},
+	}
+}
+
+func (r *recorder) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			r.exchange.Connected = time.Now()
+			if info.Conn != nil {
+				r.exchange.RemoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		WroteHeaderField: func(key string, values []string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			for _, v := range values {
+				if _, ok := sensitiveHeaders[http.CanonicalHeaderKey(key)]; ok {
+					v = redacted
+				}
+				fmt.Fprintf(&r.header, "%s: %s\r\n", key, v)
+			}
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			r.exchange.FirstByte = time.Now()
+		},
+	}
+}
+
+// finish completes the exchange with the response and the start of its body, plain http requests are sent to a
+// proxy with the absolute url as request target.
+func (r *recorder) finish(req *http.Request, resp *http.Response, body []byte, limit int64, proxied bool) *Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := r.exchange
+	e.Finished = time.Now()
+
+	target := req.URL.RequestURI()
+	if req.URL.Scheme == "http" && proxied {
+		target = req.URL.String()
+	}
+	e.Request = append([]byte(fmt.Sprintf("%s %s %s\r\n", req.Method, target, req.Proto)), r.header.Bytes()...)
+	e.Request = append(e.Request, "\r\n"...)
+
+	e.Proto = resp.Proto
+	e.Status = resp.Status
+	e.StatusCode = resp.StatusCode
+	e.Header = resp.Header.Clone()
+	e.BodySize = len(body)
+
+	if int64(len(body)) > limit {
+		body = body[:limit]
+		e.Truncated = true
+	}
+	e.Body = append([]byte(nil), body...)
+
+	if resp.TLS != nil {
+		e.TLS = &TLSState{
+			Version:      tls.VersionName(resp.TLS.Version),
+			CipherSuite:  tls.CipherSuiteName(resp.TLS.CipherSuite),
+			ServerName:   resp.TLS.ServerName,
+			Certificates: resp.TLS.PeerCertificates,
+		}
+	}
+
+	return e
+}
@@ -1,6 +1,10 @@
 package client
 
-import "time"
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
 
 type Option func(*Client)
 
@@ -10,3 +14,26 @@ func SetTimeout(duration time.Duration) Option {
 		args.handle.Timeout = duration
 	}
 }
+
+// SetMaxBodySize change the maximum number of response body bytes that are read.
+func SetMaxBodySize(size int64) Option {
+	return func(args *Client) {
+		args.maxBodySize = size
+	}
+}
+
+// SetProxy route requests through a proxy, supported schemes are http, https and socks5.
+func SetProxy(proxy *url.URL) Option {
+	return func(args *Client) {
+		if t, ok := args.handle.Transport.(*http.Transport); ok && proxy != nil {
+			t.Proxy = http.ProxyURL(proxy)
+		}
+	}
+}
+
+// SetEvidence capture the raw exchange of every request, up to size bytes of the response body are kept.
+func SetEvidence(size int64) Option {
+	return func(args *Client) {
+		args.evidenceBodySize = size
+	}
+}
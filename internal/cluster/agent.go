@@ -0,0 +1,269 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/utils"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+var (
+	// ErrFinished is returned by the coordinator once every target is scanned.
+	ErrFinished = errors.New("scan finished")
+	// ErrLeaseExpired is returned when a lease was reassigned to another agent.
+	ErrLeaseExpired = errors.New("lease expired")
+	// ErrUnauthorized is returned when the coordinator rejects the token.
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// Agent leases batches of targets from a coordinator and scans them on a local worker pool.
+type Agent struct {
+	coordinator *url.URL
+	registry    *checker.Registry
+	http        *http.Client
+	name        string
+	token       string
+	workers     int
+	leases      int
+	poll        time.Duration
+}
+
+type batch struct {
+	lease     Lease
+	expires   time.Time
+	remaining int
+	records   []results.Record
+}
+
+// NewAgent creates an agent for the coordinator listening on the given url.
+func NewAgent(coordinator *url.URL, registry *checker.Registry, options ...AgentOption) *Agent {
+	a := Agent{
+		coordinator: coordinator,
+		registry:    registry,
+		http:        &http.Client{Timeout: 30 * time.Second},
+		workers:     50,
+		leases:      2,
+		poll:        time.Second,
+	}
+
+	for _, option := range options {
+		option(&a)
+	}
+
+	return &a
+}
+
+// Run scans leased targets until the coordinator reports that the scan is finished or the context is done.
+// Each scanned batch is passed to the optional callback before it is sent to the coordinator.
+//
+//nolint:gocognit
+func (a *Agent) Run(ctx context.Context, callback func(Lease, []results.Record)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		targets = make(chan worker.Target)
+		slots   = make(chan struct{}, a.leases)
+		mu      sync.Mutex
+		batches = make(map[string]*batch)
+		failure error
+	)
+
+	resultCH := worker.Work(ctx, targets, a.registry, a.workers)
+
+	go func() {
+		defer close(targets)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- struct{}{}:
+			}
+
+			lease, err := a.lease(ctx)
+			switch {
+			case errors.Is(err, ErrFinished):
+				return
+			case errors.Is(err, ErrUnauthorized):
+				mu.Lock()
+				failure = err
+				mu.Unlock()
+				cancel()
+				return
+			case err != nil || len(lease.Targets) == 0:
+				// nothing to do yet or the coordinator is unreachable, ask again later
+				<-slots
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(a.poll):
+				}
+				continue
+			}
+
+			mu.Lock()
+			batches[lease.ID] = &batch{
+				lease:     lease,
+				expires:   time.Now().Add(lease.TTL),
+				remaining: len(lease.Targets),
+			}
+			mu.Unlock()
+
+			for t := range utils.LoadTargetList(ctx, lease.Targets) {
+				t.Job = lease.ID
+				select {
+				case <-ctx.Done():
+					return
+				case targets <- t:
+				}
+			}
+		}
+	}()
+
+	// keep the leases alive while they are scanned
+	go func() {
+		ticker := time.NewTicker(a.poll)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			mu.Lock()
+			var due []string
+			for id, b := range batches {
+				if time.Until(b.expires) < b.lease.TTL/2 {
+					due = append(due, id)
+				}
+			}
+			mu.Unlock()
+
+			for _, id := range due {
+				renewed, err := a.renew(ctx, id)
+				if err != nil {
+					continue
+				}
+
+				mu.Lock()
+				if b, ok := batches[id]; ok {
+					b.lease.TTL = renewed.TTL
+					b.expires = time.Now().Add(renewed.TTL)
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+
+	for r := range resultCH {
+		mu.Lock()
+		b, ok := batches[r.Job]
+		if !ok {
+			mu.Unlock()
+			continue
+		}
+
+		b.records = append(b.records, results.FromResult(r, time.Now())...)
+		b.remaining--
+		if b.remaining > 0 {
+			mu.Unlock()
+			continue
+		}
+		delete(batches, r.Job)
+		mu.Unlock()
+
+		if callback != nil {
+			callback(b.lease, b.records)
+		}
+
+		// an expired lease is scanned again by another agent, its results are dropped
+		_ = a.complete(ctx, b.lease.ID, b.records)
+		<-slots
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return failure
+}
+
+// lease asks the coordinator for a batch, an empty lease means that no batch is available yet.
+func (a *Agent) lease(ctx context.Context) (Lease, error) {
+	var lease Lease
+	err := a.post(ctx, "/leases", LeaseRequest{Agent: a.name}, &lease)
+
+	return lease, err
+}
+
+func (a *Agent) renew(ctx context.Context, id string) (Lease, error) {
+	var lease Lease
+	err := a.post(ctx, "/leases/"+id+"/renew", struct{}{}, &lease)
+
+	return lease, err
+}
+
+func (a *Agent) complete(ctx context.Context, id string, records []results.Record) error {
+	return a.post(ctx, "/leases/"+id, Completion{Agent: a.name, Records: records}, nil)
+}
+
+func (a *Agent) post(ctx context.Context, path string, body, v any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encoding request. Error: %w", err)
+	}
+
+	u := *a.coordinator
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("creating request. Error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("requesting %s. Error: %w", u.String(), err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if v == nil {
+			return nil
+		}
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("decoding response of %s. Error: %w", u.String(), err)
+		}
+		return nil
+	case http.StatusNoContent:
+		return nil
+	case http.StatusGone:
+		return ErrFinished
+	case http.StatusConflict:
+		return ErrLeaseExpired
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d from %s: %s", resp.StatusCode, u.String(), bytes.TrimSpace(msg))
+	}
+}
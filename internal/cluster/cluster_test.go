@@ -0,0 +1,224 @@
+package cluster_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/cluster"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/templates"
+	"github.com/georlav/githunt/internal/utils"
+)
+
+func newRegistry(t *testing.T) *checker.Registry {
+	t.Helper()
+
+	tpls, err := templates.Builtin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := checker.NewRegistry(templates.NewCheckers(tpls, client.NewClient(client.SetTimeout(5*time.Second)))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return registry
+}
+
+// newTargets starts a target server, every second target exposes its git config.
+func newTargets(t *testing.T, n int) ([]string, map[string]bool) {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id int
+		if _, err := fmt.Sscanf(r.URL.Path, "/%d/.git/config", &id); err == nil && id%2 == 0 {
+			_, _ = w.Write([]byte("[core]\n\tbare = false\n"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	targets := make([]string, n)
+	vulnerable := make(map[string]bool, n)
+	for i := range targets {
+		targets[i] = fmt.Sprintf("%s/%d", ts.URL, i)
+		vulnerable[targets[i]] = i%2 == 0
+	}
+
+	return targets, vulnerable
+}
+
+func collect(t *testing.T, c *cluster.Coordinator) map[string][]results.Record {
+	t.Helper()
+
+	merged := make(map[string][]results.Record)
+	timeout := time.After(20 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			t.Fatalf("Timed out with %d merged targets", len(merged))
+		case r, ok := <-c.Records():
+			if !ok {
+				return merged
+			}
+			merged[r.Target] = append(merged[r.Target], r)
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	targets, vulnerable := newTargets(t, 57)
+
+	c := cluster.NewCoordinator(ctx, utils.LoadTargetList(ctx, targets),
+		cluster.SetBatchSize(5),
+		cluster.SetToken("secret"),
+	)
+	ts := httptest.NewServer(c)
+	t.Cleanup(ts.Close)
+
+	coordinator, _ := url.Parse(ts.URL)
+	registry := newRegistry(t)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		sizes = make(map[string]int)
+	)
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("agent-%d", i)
+		a := cluster.NewAgent(coordinator, registry,
+			cluster.SetAgentName(name),
+			cluster.SetAgentToken("secret"),
+			cluster.SetWorkers(4),
+			cluster.SetPollInterval(20*time.Millisecond),
+		)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := a.Run(ctx, func(_ cluster.Lease, records []results.Record) {
+				mu.Lock()
+				sizes[name] += len(records)
+				mu.Unlock()
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	merged := collect(t, c)
+	wg.Wait()
+
+	if len(merged) != len(targets) {
+		t.Fatalf("Expected %d merged targets got %d", len(targets), len(merged))
+	}
+
+	for target, records := range merged {
+		if len(records) != 1 {
+			t.Fatalf("Expected a single record for %s got %d", target, len(records))
+		}
+		if records[0].Vulnerable != vulnerable[target] {
+			t.Fatalf("Unexpected record %+v", records[0])
+		}
+	}
+
+	if len(sizes) < 2 {
+		t.Fatalf("Expected the work to be shared by the agents got %v", sizes)
+	}
+}
+
+func TestCluster_LeaseTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	targets, _ := newTargets(t, 10)
+
+	c := cluster.NewCoordinator(ctx, utils.LoadTargetList(ctx, targets),
+		cluster.SetBatchSize(4),
+		cluster.SetLeaseTimeout(200*time.Millisecond),
+	)
+	ts := httptest.NewServer(c)
+	t.Cleanup(ts.Close)
+
+	// an agent that leases a batch and disappears
+	post := func(path string, body any) (*http.Response, error) {
+		b, _ := json.Marshal(body)
+		return http.Post(ts.URL+path, "application/json", bytes.NewReader(b))
+	}
+
+	resp, err := post("/leases", cluster.LeaseRequest{Agent: "dead"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lost cluster.Lease
+	if err := json.NewDecoder(resp.Body).Decode(&lost); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(lost.Targets) != 4 {
+		t.Fatalf("Expected a lease of 4 targets got %d", len(lost.Targets))
+	}
+
+	coordinator, _ := url.Parse(ts.URL)
+	a := cluster.NewAgent(coordinator, newRegistry(t), cluster.SetPollInterval(20*time.Millisecond))
+
+	done := make(chan error)
+	go func() {
+		done <- a.Run(ctx, nil)
+	}()
+
+	merged := collect(t, c)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(merged) != len(targets) {
+		t.Fatalf("Expected %d merged targets got %d", len(targets), len(merged))
+	}
+
+	// the reassigned lease can no longer be completed by the agent that lost it
+	resp, err = post("/leases/"+lost.ID, cluster.Completion{Agent: "dead"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected status 409 got %d", resp.StatusCode)
+	}
+}
+
+func TestCluster_Unauthorized(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	c := cluster.NewCoordinator(ctx, utils.LoadTargetList(ctx, []string{"example.com"}), cluster.SetToken("secret"))
+	ts := httptest.NewServer(c)
+	t.Cleanup(ts.Close)
+
+	coordinator, _ := url.Parse(ts.URL)
+	a := cluster.NewAgent(coordinator, newRegistry(t), cluster.SetAgentToken("wrong"))
+
+	if err := a.Run(ctx, nil); !errors.Is(err, cluster.ErrUnauthorized) {
+		t.Fatalf("Expected unauthorized error got %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+// Package cluster distributes a scan over several agents. A coordinator leases batches of targets to agents
+// over HTTP, leases that are not completed or renewed in time are handed to the next agent that asks for work.
+//
+//	POST /leases             lease a batch, 204 when nothing is available yet, 410 when the scan is finished
+//	POST /leases/{id}/renew  extend a lease
+//	POST /leases/{id}        complete a lease with its results, 409 when the lease expired
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+const maxRequestSize = 64 << 20
+
+// LeaseRequest is sent by an agent asking for work.
+type LeaseRequest struct {
+	Agent string `json:"agent"`
+}
+
+// Lease is a batch of targets assigned to an agent.
+type Lease struct {
+	ID      string        `json:"id"`
+	Targets []string      `json:"targets"`
+	TTL     time.Duration `json:"ttl"`
+}
+
+// Completion carries the results of a lease.
+type Completion struct {
+	Agent   string           `json:"agent"`
+	Records []results.Record `json:"records"`
+}
+
+type lease struct {
+	agent   string
+	targets []string
+	expires time.Time
+}
+
+// Coordinator shards a target stream into leases and merges the results of the agents.
+type Coordinator struct {
+	ctx          context.Context
+	targets      <-chan worker.Target
+	records      chan results.Record
+	batchSize    int
+	leaseTimeout time.Duration
+	token        string
+
+	// deliverMu keeps the records of a lease together
+	deliverMu sync.Mutex
+
+	mu        sync.Mutex
+	leases    map[string]*lease
+	requeue   [][]string
+	exhausted bool
+	sending   int
+	finished  bool
+}
+
+// NewCoordinator creates a coordinator for targets, results are merged into Records until the context is done.
+func NewCoordinator(ctx context.Context, targets <-chan worker.Target, options ...CoordinatorOption) *Coordinator {
+	c := Coordinator{
+		ctx:          ctx,
+		targets:      targets,
+		records:      make(chan results.Record),
+		batchSize:    100,
+		leaseTimeout: 5 * time.Minute,
+		leases:       make(map[string]*lease),
+	}
+
+	for _, option := range options {
+		option(&c)
+	}
+
+	return &c
+}
+
+// Records returns the merged results of every agent, the records of a target are sent one after another.
+// The channel is closed once every target is scanned.
+func (c *Coordinator) Records() <-chan results.Record {
+	return c.records
+}
+
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(c.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "leases":
+		c.lease(w, r)
+	case len(parts) == 2 && parts[0] == "leases":
+		c.complete(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "leases" && parts[2] == "renew":
+		c.renew(w, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (c *Coordinator) lease(w http.ResponseWriter, r *http.Request) {
+	var req LeaseRequest
+	if err := decode(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.mu.Lock()
+	c.expire(time.Now())
+
+	targets, invalid := c.next()
+	if len(invalid) > 0 {
+		c.sending++
+		go c.deliver(invalid)
+	}
+
+	if len(targets) == 0 {
+		finished := c.exhausted && len(c.leases) == 0 && len(c.requeue) == 0
+		c.finish()
+		c.mu.Unlock()
+
+		if finished {
+			writeError(w, http.StatusGone, errors.New("scan finished"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	c.leases[id] = &lease{
+		agent:   req.Agent,
+		targets: targets,
+		expires: time.Now().Add(c.leaseTimeout),
+	}
+	c.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, Lease{ID: id, Targets: targets, TTL: c.leaseTimeout})
+}
+
+func (c *Coordinator) renew(w http.ResponseWriter, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire(time.Now())
+
+	l, ok := c.leases[id]
+	if !ok {
+		writeError(w, http.StatusConflict, errors.New("lease expired"))
+		return
+	}
+	l.expires = time.Now().Add(c.leaseTimeout)
+
+	writeJSON(w, http.StatusOK, Lease{ID: id, TTL: c.leaseTimeout})
+}
+
+func (c *Coordinator) complete(w http.ResponseWriter, r *http.Request, id string) {
+	var completion Completion
+	if err := decode(w, r, &completion); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	c.mu.Lock()
+	c.expire(time.Now())
+
+	// a late agent must not complete a lease that was handed to another agent
+	if _, ok := c.leases[id]; !ok {
+		c.mu.Unlock()
+		writeError(w, http.StatusConflict, errors.New("lease expired"))
+		return
+	}
+	delete(c.leases, id)
+	c.sending++
+	c.mu.Unlock()
+
+	c.deliver(completion.Records)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// next takes the targets of the next lease, reassigned batches first. Targets that failed to load are
+// returned as records. It must be called with the lock held.
+func (c *Coordinator) next() ([]string, []results.Record) {
+	if len(c.requeue) > 0 {
+		targets := c.requeue[0]
+		c.requeue = c.requeue[1:]
+		return targets, nil
+	}
+
+	var (
+		targets []string
+		invalid []results.Record
+	)
+
+	for !c.exhausted && len(targets) < c.batchSize {
+		select {
+		case <-c.ctx.Done():
+			return targets, invalid
+		case t, ok := <-c.targets:
+			if !ok {
+				c.exhausted = true
+				break
+			}
+
+			if t.Error != nil {
+				invalid = append(invalid, results.FromResult(worker.Result{Error: t.Error}, time.Now())...)
+				continue
+			}
+			targets = append(targets, t.URL.String())
+		}
+	}
+
+	return targets, invalid
+}
+
+// expire requeues the targets of expired leases, it must be called with the lock held.
+func (c *Coordinator) expire(now time.Time) {
+	for id, l := range c.leases {
+		if now.After(l.expires) {
+			c.requeue = append(c.requeue, l.targets)
+			delete(c.leases, id)
+		}
+	}
+}
+
+// deliver sends records to the consumer and closes Records once the scan is finished.
+func (c *Coordinator) deliver(records []results.Record) {
+	c.deliverMu.Lock()
+	for i := range records {
+		select {
+		case <-c.ctx.Done():
+			c.deliverMu.Unlock()
+			return
+		case c.records <- records[i]:
+		}
+	}
+	c.deliverMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sending--
+	c.finish()
+}
+
+// finish closes Records when every target is scanned, it must be called with the lock held.
+func (c *Coordinator) finish() {
+	if !c.finished && c.exhausted && c.sending == 0 && len(c.leases) == 0 && len(c.requeue) == 0 {
+		c.finished = true
+		close(c.records)
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating lease id. Error: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,84 @@
+package cluster
+
+import (
+	"net/http"
+	"time"
+)
+
+type CoordinatorOption func(*Coordinator)
+
+// SetBatchSize change the number of targets of a lease.
+func SetBatchSize(size int) CoordinatorOption {
+	return func(args *Coordinator) {
+		if size > 0 {
+			args.batchSize = size
+		}
+	}
+}
+
+// SetLeaseTimeout change the time an agent has to complete or renew a lease before it is reassigned.
+func SetLeaseTimeout(timeout time.Duration) CoordinatorOption {
+	return func(args *Coordinator) {
+		if timeout > 0 {
+			args.leaseTimeout = timeout
+		}
+	}
+}
+
+// SetToken require a bearer token on every request.
+func SetToken(token string) CoordinatorOption {
+	return func(args *Coordinator) {
+		args.token = token
+	}
+}
+
+type AgentOption func(*Agent)
+
+// SetAgentName change the name an agent reports to the coordinator.
+func SetAgentName(name string) AgentOption {
+	return func(args *Agent) {
+		args.name = name
+	}
+}
+
+// SetAgentToken send a bearer token with every request to the coordinator.
+func SetAgentToken(token string) AgentOption {
+	return func(args *Agent) {
+		args.token = token
+	}
+}
+
+// SetWorkers change the size of the local worker pool.
+func SetWorkers(workers int) AgentOption {
+	return func(args *Agent) {
+		if workers > 0 {
+			args.workers = workers
+		}
+	}
+}
+
+// SetLeases change the number of leases an agent works on at once, a second lease keeps the pool busy
+// while the results of the first are sent.
+func SetLeases(leases int) AgentOption {
+	return func(args *Agent) {
+		if leases > 0 {
+			args.leases = leases
+		}
+	}
+}
+
+// SetPollInterval change how long an agent waits before asking again when no work is available.
+func SetPollInterval(interval time.Duration) AgentOption {
+	return func(args *Agent) {
+		if interval > 0 {
+			args.poll = interval
+		}
+	}
+}
+
+// SetHTTPClient change the client used to talk to the coordinator.
+func SetHTTPClient(c *http.Client) AgentOption {
+	return func(args *Agent) {
+		args.http = c
+	}
+}
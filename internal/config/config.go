@@ -0,0 +1,316 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables that are read for secrets.
+const (
+	EnvProxy         = "GITHUNT_PROXY"
+	EnvProxyUser     = "GITHUNT_PROXY_USER"
+	EnvProxyPassword = "GITHUNT_PROXY_PASSWORD"
+)
+
+// envVar matches the ${VAR} references that are expanded in string values.
+var envVar = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Config is the content of a githunt configuration file.
+type Config struct {
+	Defaults Options            `yaml:"defaults"`
+	Profiles map[string]Options `yaml:"profiles"`
+}
+
+// Options holds flag values, nil fields are left untouched.
+type Options struct {
+	Workers   *int           `yaml:"workers"`
+	CPUs      *int           `yaml:"cpus"`
+	Timeout   *time.Duration `yaml:"timeout"`
+	Path      *string        `yaml:"path"`
+	Templates *string        `yaml:"templates"`
+	Output    *string        `yaml:"output"`
+	Proxy     *string        `yaml:"proxy"`
+	Metrics   *string        `yaml:"metrics-addr"`
+	Store     *string        `yaml:"store"`
+}
+
+// Builtin profiles, a configuration file can override them.
+var builtinProfiles = map[string]Options{
+	"stealth": {Workers: intPtr(5), Timeout: durationPtr(30 * time.Second)},
+	"fast":    {Workers: intPtr(200), Timeout: durationPtr(5 * time.Second)},
+	"dump":    {Workers: intPtr(20), Timeout: durationPtr(60 * time.Second)},
+}
+
+// DefaultPath returns the configuration file used when none is given, it is empty when the file does not exist.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	path := filepath.Join(dir, "githunt", "config.yaml")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+
+	return path
+}
+
+// Load reads and validates a configuration file, environment variables in the form ${VAR} are expanded.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s. Error: %w", path, err)
+	}
+
+	cfg, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing config %s. Error: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Parse decodes a configuration, every unknown key and invalid value is reported.
+func Parse(b []byte) (*Config, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	var (
+		cfg  Config
+		errs []error
+	)
+
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("decoding config. Error: %w", err)
+		}
+		for _, e := range typeErr.Errors {
+			errs = append(errs, errors.New(e))
+		}
+	}
+
+	cfg.Defaults.expand()
+	errs = append(errs, cfg.Defaults.validate("defaults")...)
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o := cfg.Profiles[name]
+		o.expand()
+		cfg.Profiles[name] = o
+		errs = append(errs, o.validate("profiles."+name)...)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// Profile returns the defaults merged with the named profile.
+func (c *Config) Profile(name string) (Options, error) {
+	opts := c.Defaults
+	if name == "" {
+		return opts, nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if profile, ok = builtinProfiles[name]; !ok {
+			return Options{}, fmt.Errorf("unknown profile %s", name)
+		}
+	}
+
+	opts.merge(profile)
+
+	return opts, nil
+}
+
+// Apply sets the flags that were not given on the command line from the defaults and the named profile.
+func (c *Config) Apply(fs *flag.FlagSet, profile string) error {
+	opts, err := c.Profile(profile)
+	if err != nil {
+		return err
+	}
+
+	return opts.Apply(fs)
+}
+
+// Apply sets the flags that were not given on the command line.
+func (o *Options) Apply(fs *flag.FlagSet) error {
+	visited := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		visited[f.Name] = true
+	})
+
+	for name, value := range o.values() {
+		if visited[name] || fs.Lookup(name) == nil {
+			continue
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("setting %s. Error: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// ProxyURL parses a proxy address and applies the environment credentials and address when set.
+func ProxyURL(proxy string) (*url.URL, error) {
+	if env := os.Getenv(EnvProxy); proxy == "" && env != "" {
+		proxy = env
+	}
+
+	if proxy == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("parsing proxy %s. Error: %w", proxy, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("parsing proxy %s. Error: unsupported scheme %q", proxy, u.Scheme)
+	}
+
+	if user := os.Getenv(EnvProxyUser); user != "" && u.User == nil {
+		if password, ok := os.LookupEnv(EnvProxyPassword); ok {
+			u.User = url.UserPassword(user, password)
+		} else {
+			u.User = url.User(user)
+		}
+	}
+
+	return u, nil
+}
+
+func (o *Options) merge(other Options) {
+	if other.Workers != nil {
+		o.Workers = other.Workers
+	}
+	if other.CPUs != nil {
+		o.CPUs = other.CPUs
+	}
+	if other.Timeout != nil {
+		o.Timeout = other.Timeout
+	}
+	if other.Path != nil {
+		o.Path = other.Path
+	}
+	if other.Templates != nil {
+		o.Templates = other.Templates
+	}
+	if other.Output != nil {
+		o.Output = other.Output
+	}
+	if other.Proxy != nil {
+		o.Proxy = other.Proxy
+	}
+	if other.Metrics != nil {
+		o.Metrics = other.Metrics
+	}
+	if other.Store != nil {
+		o.Store = other.Store
+	}
+}
+
+func (o *Options) values() map[string]string {
+	values := make(map[string]string)
+
+	if o.Workers != nil {
+		values["workers"] = strconv.Itoa(*o.Workers)
+	}
+	if o.CPUs != nil {
+		values["cpus"] = strconv.Itoa(*o.CPUs)
+	}
+	if o.Timeout != nil {
+		values["timeout"] = o.Timeout.String()
+	}
+	if o.Path != nil {
+		values["path"] = *o.Path
+	}
+	if o.Templates != nil {
+		values["templates"] = *o.Templates
+	}
+	if o.Output != nil {
+		values["output"] = *o.Output
+	}
+	if o.Proxy != nil {
+		values["proxy"] = *o.Proxy
+	}
+	if o.Metrics != nil {
+		values["metrics-addr"] = *o.Metrics
+	}
+	if o.Store != nil {
+		values["store"] = *o.Store
+	}
+
+	return values
+}
+
+// expand replaces the ${VAR} references of the string values with the environment, any other $ is kept.
+func (o *Options) expand() {
+	for _, v := range []*string{o.Path, o.Templates, o.Output, o.Proxy, o.Metrics, o.Store} {
+		if v != nil {
+			*v = envVar.ReplaceAllStringFunc(*v, func(ref string) string {
+				return os.Getenv(ref[2 : len(ref)-1])
+			})
+		}
+	}
+}
+
+func (o *Options) validate(section string) []error {
+	var errs []error
+
+	if o.Workers != nil && *o.Workers < 1 {
+		errs = append(errs, fmt.Errorf("%s.workers: must be greater than 0, got %d", section, *o.Workers))
+	}
+	if o.CPUs != nil && *o.CPUs < 1 {
+		errs = append(errs, fmt.Errorf("%s.cpus: must be greater than 0, got %d", section, *o.CPUs))
+	}
+	if o.Timeout != nil && *o.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout: must be greater than 0, got %s", section, o.Timeout))
+	}
+	if o.Path != nil && !strings.HasPrefix(*o.Path, "/") {
+		errs = append(errs, fmt.Errorf("%s.path: must start with /, got %q", section, *o.Path))
+	}
+	if o.Proxy != nil {
+		if _, err := ProxyURL(*o.Proxy); err != nil {
+			errs = append(errs, fmt.Errorf("%s.proxy: %w", section, err))
+		}
+	}
+
+	return errs
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
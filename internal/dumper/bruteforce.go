@@ -0,0 +1,197 @@
+package dumper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/git"
+)
+
+//go:embed wordlist.txt
+var builtinWordlist []byte
+
+// Wordlist returns the builtin paths probed by the dumper.
+func Wordlist() []string {
+	return parseWordlist(builtinWordlist)
+}
+
+// LoadWordlist reads a wordlist file, one path relative to the git directory per line.
+func LoadWordlist(filename string) ([]string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading wordlist %s. Error: %w", filename, err)
+	}
+
+	return parseWordlist(b), nil
+}
+
+func parseWordlist(b []byte) []string {
+	var words []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, strings.TrimPrefix(line, "/"))
+	}
+
+	return words
+}
+
+// soft404 recognizes the responses that servers send for any path.
+type soft404 struct {
+	bodies [][]byte
+}
+
+// newSoft404 requests paths that cannot exist in dirs under base and keeps the bodies of the ones that are
+// answered with 200.
+func (s *session) newSoft404(ctx context.Context, base *url.URL, dirs ...string) *soft404 {
+	var filter soft404
+
+	for _, dir := range dirs {
+		token := make([]byte, 8)
+		_, _ = rand.Read(token)
+
+		resp, err := s.dumper.client.Do(ctx, http.MethodGet, checker.JoinPath(base, dir+"githunt-"+hex.EncodeToString(token)), nil)
+		if err == nil && resp.StatusCode == http.StatusOK {
+			filter.bodies = append(filter.bodies, resp.Body)
+		}
+	}
+
+	return &filter
+}
+
+// match reports whether body is the response to a missing path.
+func (f *soft404) match(body []byte) bool {
+	for _, b := range f.bodies {
+		if bytes.Equal(b, body) {
+			return true
+		}
+	}
+
+	head := bytes.ToLower(bytes.TrimSpace(body[:min(len(body), 512)]))
+
+	return bytes.HasPrefix(head, []byte("<!doctype")) || bytes.HasPrefix(head, []byte("<html"))
+}
+
+// bruteforce probes the wordlist paths that are not mirrored yet. Refs that resolve to an object id are added to
+// refs, other files are mirrored. It returns the discovered paths.
+func (s *session) bruteforce(ctx context.Context, refs map[string]string) []string {
+	if len(s.dumper.wordlist) == 0 {
+		return nil
+	}
+
+	filter := s.newSoft404(ctx, s.base, "refs/heads/", "")
+
+	var (
+		mu         sync.Mutex
+		discovered []string
+		wg         sync.WaitGroup
+		pending    = make(chan string)
+	)
+
+	for i := 0; i < s.dumper.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for name := range pending {
+				hash, ok := s.probe(ctx, filter, name)
+				if !ok {
+					continue
+				}
+
+				mu.Lock()
+				discovered = append(discovered, name)
+				if hash != "" {
+					refs[name] = hash
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	seen := make(map[string]struct{})
+	for _, name := range s.dumper.wordlist {
+		mu.Lock()
+		_, known := refs[name]
+		mu.Unlock()
+
+		if _, ok := seen[name]; ok || known || !validPath(name) || s.mirrored(name) || ctx.Err() != nil {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		pending <- name
+	}
+	close(pending)
+	wg.Wait()
+
+	return discovered
+}
+
+// probe downloads a wordlist path and mirrors it unless it is a soft 404, refs must hold an object id or point to
+// another ref that does.
+func (s *session) probe(ctx context.Context, filter *soft404, name string) (string, bool) {
+	b, err := s.fetch(ctx, name)
+	if err != nil || len(b) == 0 || filter.match(b) {
+		return "", false
+	}
+
+	var hash string
+	if strings.HasPrefix(name, "refs/") {
+		ref, h, err := git.ParseHead(b)
+		switch {
+		case err != nil:
+			return "", false
+		case ref != "":
+			// symbolic refs such as refs/remotes/origin/HEAD
+			if h, err = s.resolveRef(ctx, ref); err != nil {
+				return "", false
+			}
+		}
+		hash = h
+	}
+
+	if err := s.save(name, b); err != nil {
+		return "", false
+	}
+
+	return hash, true
+}
+
+// mirrored reports whether a file was already mirrored from the git directory.
+func (s *session) mirrored(name string) bool {
+	_, err := os.Stat(filepath.Join(s.gitDir, filepath.FromSlash(name)))
+
+	return err == nil
+}
+
+// validPath reports whether a wordlist path is safe to use as a local path.
+func validPath(name string) bool {
+	if strings.HasPrefix(name, "refs/") {
+		return validRef(name)
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if !validName(part) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,69 @@
+package dumper
+
+import (
+	"github.com/georlav/githunt/internal/git"
+	"github.com/georlav/githunt/internal/metrics"
+)
+
+// fromCache returns an object of the shared cache and saves it in the git directory, nil is returned on a miss.
+func (s *session) fromCache(hash string) *git.Object {
+	if s.dumper.cache == nil {
+		return nil
+	}
+
+	o, ok := s.dumper.cache.Object(hash, s.dumper.maxSize)
+	if !ok {
+		return nil
+	}
+
+	b, err := git.EncodeLoose(o)
+	if err != nil || s.save(git.LoosePath(hash), b) != nil {
+		return nil
+	}
+
+	s.hit()
+	metrics.DumpObjects.Inc("cached")
+
+	return o
+}
+
+// packFromCache returns a pack file of the shared cache and saves it in the git directory.
+func (s *session) packFromCache(name string) ([]byte, bool) {
+	if s.dumper.cache == nil {
+		return nil, false
+	}
+
+	b, ok := s.dumper.cache.Pack(name)
+	if !ok || s.save("objects/pack/"+name, b) != nil {
+		return nil, false
+	}
+
+	s.hit()
+
+	return b, true
+}
+
+// toCache adds verified objects to the shared cache, cache errors never fail a dump.
+func (s *session) toCache(objects ...*git.Object) {
+	if s.dumper.cache == nil {
+		return
+	}
+
+	for _, o := range objects {
+		_ = s.dumper.cache.AddObject(o)
+	}
+}
+
+// packToCache adds a downloaded pack file to the shared cache, files that fail verification are skipped.
+func (s *session) packToCache(name string, b []byte) {
+	if s.dumper.cache != nil {
+		_ = s.dumper.cache.AddPack(name, b)
+	}
+}
+
+func (s *session) hit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cacheHits++
+}
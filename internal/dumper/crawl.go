@@ -0,0 +1,159 @@
+package dumper
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/git"
+)
+
+// crawl mirrors the git directory through its directory listing. Directories deeper than the crawl depth are not
+// listed and downloads stop once the crawl size is reached. It returns false when the git directory is not listed.
+func (s *session) crawl(ctx context.Context) bool {
+	if s.dumper.crawlDepth <= 0 {
+		return false
+	}
+
+	if listing, ok := s.list(ctx, ""); !ok || !listing.gitListing() {
+		return false
+	}
+
+	// listings are read one directory at a time, files are downloaded by the workers
+	var (
+		files = make(chan string)
+		size  atomic.Int64
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < s.dumper.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for name := range files {
+				if size.Load() >= s.dumper.crawlSize {
+					continue
+				}
+
+				b, err := s.mirror(ctx, name)
+				if err != nil {
+					continue
+				}
+				size.Add(int64(len(b)))
+
+				s.mu.Lock()
+				s.crawled[name] = struct{}{}
+				s.mu.Unlock()
+			}
+		}()
+	}
+
+	type dir struct {
+		name  string
+		depth int
+	}
+
+	queue := []dir{{}}
+	for len(queue) > 0 && ctx.Err() == nil && size.Load() < s.dumper.crawlSize {
+		d := queue[0]
+		queue = queue[1:]
+
+		listing, ok := s.list(ctx, d.name)
+		if !ok {
+			continue
+		}
+
+		for _, e := range listing.Entries {
+			name := d.name + e.Name
+			switch {
+			case e.Dir && d.depth+1 < s.dumper.crawlDepth:
+				queue = append(queue, dir{name: name + "/", depth: d.depth + 1})
+			case !e.Dir:
+				files <- name
+			}
+		}
+	}
+	close(files)
+	wg.Wait()
+
+	return true
+}
+
+// list downloads and parses the listing of a directory relative to the git directory.
+func (s *session) list(ctx context.Context, name string) (*Listing, bool) {
+	u := checker.JoinPath(s.base, name)
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	resp, err := s.dumper.client.Do(ctx, http.MethodGet, u, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+
+	return ParseListing(u, resp.Body)
+}
+
+// cached returns a file that was mirrored by the crawler in this dump.
+func (s *session) cached(name string) ([]byte, bool) {
+	s.mu.Lock()
+	_, ok := s.crawled[name]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	b, err := os.ReadFile(filepath.Join(s.gitDir, filepath.FromSlash(name)))
+
+	return b, err == nil
+}
+
+// local returns a crawled file or downloads and mirrors it.
+func (s *session) local(ctx context.Context, name string) ([]byte, error) {
+	if b, ok := s.cached(name); ok {
+		return b, nil
+	}
+
+	return s.mirror(ctx, name)
+}
+
+// crawledRefs adds the loose refs found by the crawler to refs.
+func (s *session) crawledRefs(refs map[string]string) {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.crawled))
+	for name := range s.crawled {
+		if strings.HasPrefix(name, "refs/") && validRef(name) {
+			names = append(names, name)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, name := range names {
+		b, _ := s.cached(name)
+		if _, hash, err := git.ParseHead(b); err == nil && hash != "" {
+			refs[name] = hash
+		}
+	}
+}
+
+// crawledPacks returns the names of the packs found by the crawler without extension.
+func (s *session) crawledPacks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var packs []string
+	for name := range s.crawled {
+		if pack, ok := strings.CutPrefix(name, "objects/pack/"); ok && strings.HasSuffix(pack, ".pack") {
+			packs = append(packs, strings.TrimSuffix(pack, ".pack"))
+		}
+	}
+
+	return packs
+}
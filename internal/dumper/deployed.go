@@ -0,0 +1,166 @@
+package dumper
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/git"
+	"github.com/georlav/githunt/internal/metrics"
+)
+
+// Deployed file statuses.
+const (
+	// DeployedRaw files are served with the exact content of the index.
+	DeployedRaw = "raw"
+	// DeployedModified files are served with content that differs from the index.
+	DeployedModified = "modified"
+)
+
+const (
+	modeSymlink = 0o120000
+	modeGitlink = 0o160000
+)
+
+// executed are the extensions of files that web servers usually run instead of serving, their output is
+// expected to differ from the source.
+var executed = map[string]struct{}{
+	".php": {}, ".php3": {}, ".php4": {}, ".php5": {}, ".php7": {}, ".phtml": {}, ".phar": {},
+	".asp": {}, ".aspx": {}, ".ashx": {}, ".asmx": {}, ".jsp": {}, ".jspx": {}, ".cfm": {},
+	".cgi": {}, ".pl": {}, ".py": {}, ".rb": {}, ".shtml": {},
+}
+
+// sensitive are the extensions of source, configuration, data and backup files that should never be served.
+var sensitive = map[string]struct{}{
+	".bak": {}, ".old": {}, ".orig": {}, ".save": {}, ".swp": {}, ".tmp": {}, ".dist": {},
+	".env": {}, ".ini": {}, ".conf": {}, ".config": {}, ".cfg": {}, ".yml": {}, ".yaml": {}, ".toml": {},
+	".properties": {}, ".sql": {}, ".sqlite": {}, ".db": {}, ".log": {}, ".key": {}, ".pem": {},
+	".sh": {}, ".go": {}, ".java": {}, ".cs": {}, ".c": {}, ".cpp": {}, ".h": {}, ".lock": {},
+}
+
+// Deployed is a file of the index that the site serves from its webroot.
+type Deployed struct {
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	// Hash is the blob id of the index entry, Served is the blob id of the served content when it differs.
+	Hash   string `json:"hash"`
+	Served string `json:"served,omitempty"`
+	// Sensitive is set for source, configuration and backup files.
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// deployed requests every path of the index from the webroot, the directory that holds the git directory. Files
+// served with the content of the index recover their blob. Files whose content differs are reported as modified
+// unless the server runs them.
+func (s *session) deployed(ctx context.Context, target *url.URL, idx *git.Index) []Deployed {
+	if idx == nil {
+		return nil
+	}
+
+	webroot := checker.JoinPath(target, strings.TrimSuffix(path.Dir(strings.TrimSuffix(s.dumper.gitPath, "/")), "/")+"/")
+	filter := s.newSoft404(ctx, webroot, "")
+
+	var (
+		mu      sync.Mutex
+		files   []Deployed
+		wg      sync.WaitGroup
+		pending = make(chan git.IndexEntry)
+	)
+
+	for i := 0; i < s.dumper.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for e := range pending {
+				if f, ok := s.deploy(ctx, webroot, filter, e); ok {
+					mu.Lock()
+					files = append(files, f)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	seen := make(map[string]struct{})
+	for _, e := range idx.Entries {
+		if _, ok := seen[e.Path]; ok || e.Mode == modeGitlink || e.Mode == modeSymlink || !validPath(e.Path) || ctx.Err() != nil {
+			continue
+		}
+		seen[e.Path] = struct{}{}
+
+		pending <- e
+	}
+	close(pending)
+	wg.Wait()
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files
+}
+
+// deploy requests a single index entry from the webroot.
+func (s *session) deploy(ctx context.Context, webroot *url.URL, filter *soft404, e git.IndexEntry) (Deployed, bool) {
+	u := checker.JoinPath(webroot, e.Path)
+
+	resp, err := s.dumper.client.Do(ctx, http.MethodGet, u, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return Deployed{}, false
+	}
+
+	f := Deployed{
+		Path:      e.Path,
+		URL:       u.String(),
+		Hash:      e.Hash,
+		Sensitive: isSensitive(e.Path),
+	}
+
+	blob := &git.Object{Type: git.ObjectBlob, Data: resp.Body, Format: s.format}
+	if blob.Hash() == e.Hash {
+		f.Status = DeployedRaw
+
+		// the served file is the blob, which may not be downloadable from the objects directory
+		if s.object(e.Hash) == nil {
+			if b, err := git.EncodeLoose(blob); err == nil && s.save(git.LoosePath(e.Hash), b) == nil {
+				s.add(e.Hash, blob)
+				s.toCache(blob)
+				metrics.DumpObjects.Inc("deployed")
+			}
+		}
+
+		return f, true
+	}
+
+	if _, ok := executed[strings.ToLower(path.Ext(e.Path))]; ok || len(resp.Body) == 0 || filter.match(resp.Body) {
+		return Deployed{}, false
+	}
+
+	f.Status = DeployedModified
+	f.Served = blob.Hash()
+
+	return f, true
+}
+
+// isSensitive reports whether a path is a dotfile or has the extension of a source, configuration or backup file.
+func isSensitive(name string) bool {
+	base := path.Base(name)
+	if strings.HasPrefix(base, ".") || strings.HasSuffix(base, "~") {
+		return true
+	}
+
+	if _, ok := executed[strings.ToLower(path.Ext(base))]; ok {
+		return true
+	}
+
+	_, ok := sensitive[strings.ToLower(path.Ext(base))]
+
+	return ok
+}
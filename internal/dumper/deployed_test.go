@@ -0,0 +1,156 @@
+package dumper_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/dumper"
+	"github.com/georlav/githunt/internal/git"
+)
+
+// index encodes a version 2 index of regular files.
+func index(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString("DIRC")
+	_ = binary.Write(&b, binary.BigEndian, uint32(2))
+	_ = binary.Write(&b, binary.BigEndian, uint32(len(names)))
+
+	for _, name := range names {
+		hash, err := hex.DecodeString((&git.Object{Type: git.ObjectBlob, Data: []byte(files[name])}).Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// ctime, mtime, dev, ino, mode, uid, gid and size
+		fields := []uint32{0, 0, 1700000000, 0, 0, 0, 0o100644, 0, 0, uint32(len(files[name]))}
+		for _, f := range fields {
+			_ = binary.Write(&b, binary.BigEndian, f)
+		}
+		b.Write(hash)
+		_ = binary.Write(&b, binary.BigEndian, uint16(len(name)))
+		b.WriteString(name)
+
+		// entries are padded with 1 to 8 NUL bytes
+		n := 62 + len(name)
+		b.Write(make([]byte, (n+8)/8*8-n))
+	}
+
+	sum := sha1.Sum(b.Bytes()) //nolint:gosec
+	b.Write(sum[:])
+
+	return b.Bytes()
+}
+
+func TestDumper_DumpDeployed(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	files := map[string]string{
+		".env":               "DB_PASSWORD=secret\n",
+		"config.php.bak":     "<?php $db = 'secret';\n",
+		"index.php":          "<?php echo 'hi';\n",
+		"assets/app.js":      "console.log(1);\n",
+		"assets/style.css":   "body { color: red; }\n",
+		"docs/missing.txt":   "missing\n",
+		"templates/404.html": "<html>not found template</html>\n",
+	}
+	head := r.commit(files, 1700000000)
+
+	if err := os.WriteFile(filepath.Join(r.dir, "HEAD"), []byte(head+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, "index"), index(t, files), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the objects directory is blocked, the webroot serves the deployed files
+	served := map[string]string{
+		"/.env":             files[".env"],
+		"/config.php.bak":   files["config.php.bak"],
+		"/index.php":        "hi",
+		"/assets/app.js":    files["assets/app.js"],
+		"/assets/style.css": "body { color: blue; }\n",
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case strings.HasPrefix(req.URL.Path, "/.git/objects/"):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		case strings.HasPrefix(req.URL.Path, "/.git/"):
+			http.ServeFile(w, req, filepath.Join(r.dir, filepath.FromSlash(strings.TrimPrefix(req.URL.Path, "/.git/"))))
+		case served[req.URL.Path] != "":
+			_, _ = w.Write([]byte(served[req.URL.Path]))
+		default:
+			// every other path renders the home page
+			_, _ = w.Write([]byte("<html>home</html>"))
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetDeployed(true), dumper.SetWordlist(nil))
+
+	result, err := d.Dump(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []dumper.Deployed{
+		{Path: ".env", Status: dumper.DeployedRaw, Sensitive: true},
+		{Path: "assets/app.js", Status: dumper.DeployedRaw},
+		{Path: "assets/style.css", Status: dumper.DeployedModified},
+		{Path: "config.php.bak", Status: dumper.DeployedRaw, Sensitive: true},
+	}
+
+	if len(result.Deployed) != len(expected) {
+		t.Fatalf("Expected %d deployed files got %+v", len(expected), result.Deployed)
+	}
+
+	for i, f := range result.Deployed {
+		if f.Path != expected[i].Path || f.Status != expected[i].Status || f.Sensitive != expected[i].Sensitive ||
+			f.URL != ts.URL+"/"+f.Path {
+			t.Fatalf("Unexpected deployed file %+v", f)
+		}
+
+		if (f.Status == dumper.DeployedModified) != (f.Served != "") {
+			t.Fatalf("Unexpected served hash %+v", f)
+		}
+	}
+
+	// the raw files recover their blobs, the commit and the other blobs of the index are blocked
+	if result.Objects != 3 || len(result.Missing) != 5 {
+		t.Fatalf("Expected 3 objects and 5 missing got %d %v", result.Objects, result.Missing)
+	}
+
+	b, err := os.ReadFile(filepath.Join(result.Dir, ".git", filepath.FromSlash(git.LoosePath(result.Deployed[0].Hash))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o, err := git.DecodeLoose(b, git.SHA1, 0); err != nil || string(o.Data) != files[".env"] {
+		t.Fatalf("Unexpected recovered blob %v", err)
+	}
+}
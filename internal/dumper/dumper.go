@@ -0,0 +1,698 @@
+package dumper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/georlav/githunt/internal/cache"
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/git"
+	"github.com/georlav/githunt/internal/metrics"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/secrets"
+)
+
+// ErrNotFound is returned when a file is not served by the target.
+var ErrNotFound = errors.New("not found")
+
+// files that are mirrored from the git directory when present.
+var metadataFiles = []string{
+	"HEAD",
+	"config",
+	"description",
+	"index",
+	"packed-refs",
+	"info/refs",
+	"info/exclude",
+	"objects/info/packs",
+}
+
+var packName = regexp.MustCompile(`^pack-([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// Dumper recovers exposed git directories.
+type Dumper struct {
+	client   *client.Client
+	dir      string
+	gitPath  string
+	workers  int
+	maxSize  int64
+	checkout bool
+	secrets  *secrets.Scanner
+	wordlist []string
+	// crawlDepth and crawlSize limit the mirroring of git directories with a directory listing.
+	crawlDepth int
+	crawlSize  int64
+	smart      bool
+	deployed   bool
+	dedupe     bool
+	integrity  bool
+	cache      *cache.Cache
+
+	// checkoutFiles and checkoutSize limit the files and bytes written by a checkout.
+	checkoutFiles int
+	checkoutSize  int64
+
+	// dumped holds the first dump of every repository state by dedupe key.
+	mu     sync.Mutex
+	dumped map[string]*Result
+}
+
+// Result summarizes a dump.
+type Result struct {
+	Target  string            `json:"target"`
+	Dir     string            `json:"dir"`
+	Head    string            `json:"head,omitempty"`
+	Refs    map[string]string `json:"refs,omitempty"`
+	Objects int               `json:"objects"`
+	Missing []string          `json:"missing,omitempty"`
+	Files   int               `json:"files,omitempty"`
+	Secrets []Secret          `json:"secrets,omitempty"`
+	// ObjectFormat is the hash algorithm of the repository, sha1 or sha256.
+	ObjectFormat string `json:"object_format"`
+	// Timeline lists the ref movements recorded in the reflogs.
+	Timeline []RefUpdate `json:"timeline,omitempty"`
+	// Discovered lists the wordlist paths that the target serves.
+	Discovered []string `json:"discovered,omitempty"`
+	// Listing is set when the git directory was mirrored through its directory listing.
+	Listing bool `json:"listing,omitempty"`
+	Crawled int  `json:"crawled,omitempty"`
+	// Smart holds the protocol version (v0 or v2) when the repository was cloned through a smart HTTP server.
+	Smart string `json:"smart,omitempty"`
+	// Deployed lists the index files that the site serves from its webroot.
+	Deployed []Deployed `json:"deployed,omitempty"`
+	// Fingerprint identifies the exposed repository, Shared is the target of an earlier dump of the same HEAD and
+	// index whose objects were not downloaded again.
+	Fingerprint results.Fingerprint `json:"fingerprint"`
+	Shared      string              `json:"shared,omitempty"`
+	// Cached is the number of objects and pack files read from the shared cache instead of the target.
+	Cached int `json:"cached,omitempty"`
+	// Integrity reports the verification of the recovered objects and the completeness of the repository.
+	Integrity *Integrity `json:"integrity,omitempty"`
+}
+
+func NewDumper(c *client.Client, dir string, options ...Option) *Dumper {
+	d := Dumper{
+		client:     c,
+		dir:        dir,
+		gitPath:    "/.git",
+		workers:    10,
+		maxSize:    git.DefaultMaxObjectSize,
+		wordlist:   Wordlist(),
+		crawlDepth: 16,
+		crawlSize:  1 << 30,
+		smart:      true,
+		dedupe:     true,
+		integrity:  true,
+		dumped:     make(map[string]*Result),
+
+		checkoutFiles: 100000,
+		checkoutSize:  1 << 30,
+	}
+
+	for i := range options {
+		options[i](&d)
+	}
+
+	return &d
+}
+
+// Dump mirrors the git directory of a target under the output directory.
+func (d *Dumper) Dump(ctx context.Context, target *url.URL) (*Result, error) {
+	result, err := d.dump(ctx, target)
+	if err != nil {
+		metrics.DumpTargets.Inc("failed")
+		return nil, err
+	}
+
+	metrics.DumpTargets.Inc("dumped")
+
+	return result, nil
+}
+
+func (d *Dumper) dump(ctx context.Context, target *url.URL) (*Result, error) {
+	s := session{
+		dumper:  d,
+		base:    checker.JoinPath(target, strings.TrimSuffix(d.gitPath, "/")+"/"),
+		dir:     filepath.Join(d.dir, checker.TargetDir(target)),
+		objects: make(map[string]*git.Object),
+		missing: make(map[string]struct{}),
+		crawled: make(map[string]struct{}),
+	}
+	s.gitDir = filepath.Join(s.dir, ".git")
+
+	// files of earlier dumps would mix into the refs, fingerprint and integrity of this one
+	if err := os.RemoveAll(s.dir); err != nil {
+		return nil, fmt.Errorf("cleaning %s. Error: %w", s.dir, err)
+	}
+
+	result := Result{
+		Target: target.String(),
+		Dir:    s.dir,
+		Refs:   make(map[string]string),
+	}
+
+	// everything can be mirrored directly when the git directory is listed
+	result.Listing = s.crawl(ctx)
+	result.Crawled = len(s.crawled)
+
+	// a smart HTTP server hands out every advertised ref and the objects they reach in a single pack
+	// failed clones fall back to the dumb protocol
+	var smartHead string
+	if d.smart {
+		smartHead, _ = s.smart(ctx, &result)
+	}
+
+	// metadata files are optional, only HEAD is required
+	for _, f := range metadataFiles {
+		_, _ = s.local(ctx, f)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// the advertised HEAD is authoritative, the served file may be missing
+	if result.Smart != "" {
+		if err := s.saveHead(smartHead, result.Refs["HEAD"]); err != nil {
+			return nil, err
+		}
+	}
+
+	head, err := os.ReadFile(filepath.Join(s.gitDir, "HEAD"))
+	if err != nil {
+		return nil, fmt.Errorf("dumping %s. Error: HEAD is not exposed", target)
+	}
+
+	if err := s.resolveRefs(ctx, head, result.Refs); err != nil {
+		return nil, err
+	}
+	if err := s.objectFormat(result.Refs); err != nil {
+		return nil, err
+	}
+	result.ObjectFormat = s.format.String()
+	switch {
+	case result.Listing:
+		s.crawledRefs(result.Refs)
+	case result.Smart == "":
+		result.Discovered = s.bruteforce(ctx, result.Refs)
+		sort.Strings(result.Discovered)
+	}
+	result.Head = result.Refs["HEAD"]
+
+	result.Fingerprint = s.fingerprint(result.Head)
+	if prev := d.shared(result.Fingerprint); prev != nil {
+		result.Shared = prev.Target
+		result.Objects, result.Missing, result.Integrity = prev.Objects, prev.Missing, prev.Integrity
+		return &result, nil
+	}
+
+	timeline, logged := s.reflogs(ctx, head, result.Refs)
+	result.Timeline = timeline
+
+	if err := s.fetchPacks(ctx); err != nil {
+		return nil, err
+	}
+
+	// start from every ref, every commit of the reflogs and every blob listed in the index
+	start := make([]string, 0, len(result.Refs)+len(logged))
+	for _, hash := range result.Refs {
+		start = append(start, hash)
+	}
+	start = append(start, logged...)
+
+	var idx *git.Index
+	if b, err := os.ReadFile(filepath.Join(s.gitDir, "index")); err == nil {
+		if idx, err = git.ParseIndex(b, s.format); err == nil {
+			for _, e := range idx.Entries {
+				start = append(start, e.Hash)
+			}
+		}
+	}
+
+	if d.deployed {
+		result.Deployed = s.deployed(ctx, target, idx)
+	}
+
+	if err := s.walk(ctx, start); err != nil {
+		return nil, err
+	}
+
+	if d.checkout && result.Head != "" {
+		if result.Files, err = s.checkout(result.Head); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.secrets != nil {
+		result.Secrets = s.scanSecrets(d.secrets, idx)
+	}
+
+	if err := s.summarize(&result); err != nil {
+		return nil, err
+	}
+
+	d.remember(&result)
+
+	return &result, nil
+}
+
+// summarize counts the recovered and missing objects and verifies what was written to the git directory.
+func (s *session) summarize(result *Result) error {
+	result.Objects = len(s.objects)
+	result.Cached = s.cacheHits
+	for hash := range s.missing {
+		result.Missing = append(result.Missing, hash)
+	}
+	sort.Strings(result.Missing)
+
+	if !s.dumper.integrity {
+		return nil
+	}
+
+	integrity, err := Verify(s.gitDir, s.format, s.dumper.maxSize, result.Refs)
+	if err != nil {
+		return err
+	}
+	result.Integrity = integrity
+
+	return nil
+}
+
+type session struct {
+	dumper *Dumper
+	base   *url.URL
+	dir    string
+	gitDir string
+	// format is the object format of the repository, detected from the advertisement or the config.
+	format git.ObjectFormat
+
+	mu      sync.Mutex
+	objects map[string]*git.Object
+	missing map[string]struct{}
+	// crawled holds the files mirrored through a directory listing.
+	crawled   map[string]struct{}
+	cacheHits int
+}
+
+// fetch downloads a file relative to the git directory.
+func (s *session) fetch(ctx context.Context, name string) ([]byte, error) {
+	u := checker.JoinPath(s.base, name)
+
+	resp, err := s.dumper.client.Do(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s. Error: %w", u, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s. Error: %w", u, ErrNotFound)
+	}
+
+	return resp.Body, nil
+}
+
+// mirror downloads a file relative to the git directory and saves it locally.
+func (s *session) mirror(ctx context.Context, name string) ([]byte, error) {
+	b, err := s.fetch(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.save(name, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (s *session) save(name string, b []byte) error {
+	p := filepath.Join(s.gitDir, filepath.FromSlash(name))
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("creating directory for %s. Error: %w", name, err)
+	}
+
+	if err := os.WriteFile(p, b, 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("saving %s. Error: %w", name, err)
+	}
+
+	return nil
+}
+
+// resolveRefs resolves HEAD and every advertised ref to an object id.
+func (s *session) resolveRefs(ctx context.Context, head []byte, refs map[string]string) error {
+	for _, f := range []string{"packed-refs", "info/refs"} {
+		b, err := os.ReadFile(filepath.Join(s.gitDir, filepath.FromSlash(f)))
+		if err != nil {
+			continue
+		}
+
+		parse := git.ParsePackedRefs
+		if f == "info/refs" {
+			parse = git.ParseInfoRefs
+		}
+
+		for name, hash := range parse(b) {
+			if strings.HasSuffix(name, "^{}") || validRef(name) {
+				refs[name] = hash
+			}
+		}
+	}
+
+	ref, hash, err := git.ParseHead(head)
+	if err != nil {
+		return fmt.Errorf("dumping %s. Error: %w", s.base, err)
+	}
+
+	// a missing loose ref is not fatal, the ref may be packed or only the index may be recoverable
+	if ref != "" {
+		if hash, _ = s.resolveRef(ctx, ref); hash == "" {
+			hash = refs[ref]
+		}
+	}
+
+	if hash != "" {
+		refs["HEAD"] = hash
+	}
+
+	return nil
+}
+
+// objectFormat detects the object format from extensions.objectFormat of the mirrored config. Without a config the
+// length of the ref ids tells SHA-256 repositories apart.
+func (s *session) objectFormat(refs map[string]string) error {
+	b, err := os.ReadFile(filepath.Join(s.gitDir, "config"))
+	if err != nil {
+		for _, hash := range refs {
+			if git.HashFormat(hash) == git.SHA256 {
+				s.format = git.SHA256
+			}
+		}
+		return nil
+	}
+
+	if s.format, err = git.ConfigObjectFormat(b); err != nil {
+		return fmt.Errorf("dumping %s. Error: %w", s.base, err)
+	}
+
+	return nil
+}
+
+// resolveRef downloads a loose ref and returns the object id it points to.
+func (s *session) resolveRef(ctx context.Context, ref string) (string, error) {
+	if !validRef(ref) {
+		return "", fmt.Errorf("resolving %s. Error: invalid ref name", ref)
+	}
+
+	b, err := s.mirror(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	_, hash, err := git.ParseHead(b)
+	if err != nil || hash == "" {
+		return "", fmt.Errorf("resolving %s. Error: invalid ref content", ref)
+	}
+
+	return hash, nil
+}
+
+// fetchPacks downloads and unpacks the packs listed in objects/info/packs.
+func (s *session) fetchPacks(ctx context.Context) error {
+	names := s.crawledPacks()
+	if b, err := os.ReadFile(filepath.Join(s.gitDir, "objects", "info", "packs")); err == nil {
+		names = append(names, git.ParsePacks(b)...)
+	}
+
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		if _, ok := seen[name]; ok || !packName.MatchString(name) {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		if _, err := s.packFile(ctx, name+".idx"); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		pack, err := s.packFile(ctx, name+".pack")
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return err
+		}
+
+		// a partially parsed pack still holds usable objects
+		objects, _ := git.ParsePack(pack, s.format, s.dumper.maxSize, s.object)
+		for _, o := range objects {
+			s.add(o.Hash(), o)
+		}
+		s.toCache(objects...)
+		metrics.DumpObjects.Add(float64(len(objects)), "packed")
+	}
+
+	return nil
+}
+
+// packFile returns a file of objects/pack from the shared cache or downloads it and adds it to the cache.
+func (s *session) packFile(ctx context.Context, name string) ([]byte, error) {
+	if b, ok := s.packFromCache(name); ok {
+		return b, nil
+	}
+
+	b, err := s.local(ctx, "objects/pack/"+name)
+	if err != nil {
+		return nil, err
+	}
+	s.packToCache(name, b)
+
+	return b, nil
+}
+
+// walk downloads every object reachable from start.
+func (s *session) walk(ctx context.Context, start []string) error {
+	seen := make(map[string]struct{})
+	queue := start
+
+	for len(queue) > 0 {
+		var next []string
+
+		pending := make(chan string)
+		found := make(chan []string)
+		wg := sync.WaitGroup{}
+
+		for i := 0; i < s.dumper.workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for hash := range pending {
+					found <- s.load(ctx, hash)
+				}
+			}()
+		}
+
+		go func() {
+			defer close(pending)
+			for _, hash := range queue {
+				if _, ok := seen[hash]; ok || !s.format.IsHash(hash) {
+					continue
+				}
+				seen[hash] = struct{}{}
+
+				select {
+				case <-ctx.Done():
+					return
+				case pending <- hash:
+				}
+			}
+		}()
+
+		go func() {
+			wg.Wait()
+			close(found)
+		}()
+
+		for refs := range found {
+			next = append(next, refs...)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		queue = next
+	}
+
+	return nil
+}
+
+// load returns the references of an object, downloading it when it is not known yet.
+func (s *session) load(ctx context.Context, hash string) []string {
+	o := s.object(hash)
+
+	if o == nil {
+		o = s.fromCache(hash)
+		if o != nil {
+			s.add(hash, o)
+		}
+	}
+
+	if o == nil {
+		b, ok := s.cached(git.LoosePath(hash))
+
+		var err error
+		if !ok {
+			b, err = s.fetch(ctx, git.LoosePath(hash))
+		}
+		if err == nil {
+			if o, err = git.DecodeLoose(b, s.format, s.dumper.maxSize); err == nil && o.Hash() != hash {
+				o = nil
+			}
+		}
+
+		if o == nil || s.save(git.LoosePath(hash), b) != nil {
+			s.mu.Lock()
+			s.missing[hash] = struct{}{}
+			s.mu.Unlock()
+
+			metrics.DumpObjects.Inc("missing")
+			return nil
+		}
+
+		s.add(hash, o)
+		s.toCache(o)
+		metrics.DumpObjects.Inc("fetched")
+	}
+
+	refs, _ := git.References(o)
+
+	return refs
+}
+
+func (s *session) object(hash string) *git.Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.objects[hash]
+}
+
+func (s *session) add(hash string, o *git.Object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[hash] = o
+}
+
+// checkoutBudget is what a checkout may still write, directories count as files.
+type checkoutBudget struct {
+	files int
+	size  int64
+}
+
+// checkout writes the tree of a commit into the working directory and returns the number of files. The tree is
+// server controlled, a checkout that needs more files or bytes than the limits of the dumper fails.
+func (s *session) checkout(commit string) (int, error) {
+	o := s.object(commit)
+	if o == nil || o.Type != git.ObjectCommit {
+		return 0, nil
+	}
+
+	c, err := git.ParseCommit(o.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	budget := &checkoutBudget{files: s.dumper.checkoutFiles, size: s.dumper.checkoutSize}
+
+	return s.checkoutTree(c.Tree, s.dir, budget)
+}
+
+func (s *session) checkoutTree(hash, dir string, budget *checkoutBudget) (int, error) {
+	o := s.object(hash)
+	if o == nil || o.Type != git.ObjectTree {
+		return 0, nil
+	}
+
+	entries, err := git.ParseTree(o.Data, o.Format)
+	if err != nil {
+		return 0, err
+	}
+
+	files := 0
+	for _, e := range entries {
+		// tree entries are server controlled, never leave the output directory
+		if !validName(e.Name) {
+			continue
+		}
+
+		p := filepath.Join(dir, e.Name)
+
+		if !e.IsSubmodule() {
+			if budget.files--; budget.files < 0 {
+				return files, fmt.Errorf("checking out %s. Error: exceeds the limit of %d files", s.dir, s.dumper.checkoutFiles)
+			}
+		}
+
+		switch {
+		case e.IsSubmodule():
+		case e.IsTree():
+			n, err := s.checkoutTree(e.Hash, p, budget)
+			if err != nil {
+				return files, err
+			}
+			files += n
+		default:
+			blob := s.object(e.Hash)
+			if blob == nil {
+				continue
+			}
+
+			if budget.size -= int64(len(blob.Data)); budget.size < 0 {
+				return files, fmt.Errorf("checking out %s. Error: exceeds the limit of %d bytes", s.dir, s.dumper.checkoutSize)
+			}
+
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return files, fmt.Errorf("creating directory %s. Error: %w", dir, err)
+			}
+
+			// symbolic links are written as regular files holding the link target
+			if err := os.WriteFile(p, blob.Data, 0o644); err != nil { //nolint:gosec
+				return files, fmt.Errorf("writing %s. Error: %w", p, err)
+			}
+			files++
+		}
+	}
+
+	return files, nil
+}
+
+// validRef reports whether a ref name is safe to use as a local path.
+func validRef(ref string) bool {
+	if !strings.HasPrefix(ref, "refs/") || path.Clean(ref) != ref {
+		return false
+	}
+
+	for _, part := range strings.Split(ref, "/") {
+		if !validName(part) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && name != ".git" &&
+		!strings.ContainsAny(name, `/\`+"\x00")
+}
@@ -0,0 +1,770 @@
+package dumper_test
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/georlav/githunt/internal/cache"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/dumper"
+	"github.com/georlav/githunt/internal/git"
+	"github.com/georlav/githunt/internal/secrets"
+)
+
+func TestDumper_Dump(t *testing.T) {
+	ts := httptest.NewServer(http.StripPrefix("/.git/", http.FileServer(http.Dir("testdata/repo.git"))))
+
+	t.Cleanup(func() {
+		ts.Close()
+	})
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	d := dumper.NewDumper(client.NewClient(), dir, dumper.SetCheckout(true))
+
+	result, err := d.Dump(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Head != "b8ce7a3bce95c90987ad45a3a5e1892f534ea463" {
+		t.Fatalf("Unexpected HEAD %s", result.Head)
+	}
+
+	// 13 packed objects, the last commit, its tree and one blob are loose
+	if result.Objects != 16 || len(result.Missing) != 0 {
+		t.Fatalf("Expected 16 objects and none missing got %d %v", result.Objects, result.Missing)
+	}
+
+	if result.Files != 5 {
+		t.Fatalf("Expected 5 checked out files got %d", result.Files)
+	}
+
+	if i := result.Integrity; i == nil || i.Objects != 16 || len(i.Missing)+len(i.Corrupt)+len(i.Incomplete) != 0 ||
+		i.HeadCoverage != 1 {
+		t.Fatalf("Unexpected integrity %+v", result.Integrity)
+	}
+
+	b, err := os.ReadFile(filepath.Join(result.Dir, "sub", "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "hi\n" {
+		t.Fatalf("Unexpected file content %q", b)
+	}
+
+	for _, f := range []string{"HEAD", "index", "objects/b8/ce7a3bce95c90987ad45a3a5e1892f534ea463", "refs/heads/main"} {
+		if _, err := os.Stat(filepath.Join(result.Dir, ".git", f)); err != nil {
+			t.Fatalf("Expected %s to be mirrored. Error: %s", f, err)
+		}
+	}
+}
+
+func TestDumper_DumpSHA256(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config string
+		head   func(commit string) string
+	}{
+		{
+			name:   "config",
+			config: "[core]\n\trepositoryformatversion = 1\n[extensions]\n\tobjectformat = sha256\n",
+			head:   func(string) string { return "ref: refs/heads/main\n" },
+		},
+		{
+			// without a config the length of the detached HEAD tells the format
+			name: "ref length",
+			head: func(commit string) string { return commit + "\n" },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &repo{t: t, dir: t.TempDir(), format: git.SHA256}
+
+			first := r.commit(map[string]string{"a.txt": "one\n"}, 1700000000)
+			second := r.commit(map[string]string{"a.txt": "one\n", "b.txt": "two\n"}, 1700000100, first)
+
+			files := map[string]string{
+				"HEAD":            tc.head(second),
+				"refs/heads/main": second + "\n",
+				"config":          tc.config,
+			}
+			for name, content := range files {
+				if content == "" {
+					continue
+				}
+				p := filepath.Join(r.dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ts := httptest.NewServer(http.StripPrefix("/.git/", http.FileServer(http.Dir(r.dir))))
+			t.Cleanup(ts.Close)
+
+			target, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetCheckout(true), dumper.SetWordlist(nil))
+
+			result, err := d.Dump(context.Background(), target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// two commits, two trees and two blobs
+			if result.ObjectFormat != "sha256" || result.Head != second || result.Objects != 6 ||
+				len(result.Missing) != 0 || result.Files != 2 {
+				t.Fatalf("Unexpected result %s %s %d %v %d",
+					result.ObjectFormat, result.Head, result.Objects, result.Missing, result.Files)
+			}
+
+			if i := result.Integrity; i == nil || i.Objects != 6 || len(i.Corrupt) != 0 || len(i.Complete) != 2 ||
+				i.HeadCoverage != 1 {
+				t.Fatalf("Unexpected integrity %+v", result.Integrity)
+			}
+
+			b, err := os.ReadFile(filepath.Join(result.Dir, "b.txt"))
+			if err != nil || string(b) != "two\n" {
+				t.Fatalf("Unexpected checked out file %q %v", b, err)
+			}
+		})
+	}
+}
+
+func TestDumper_DumpDirs(t *testing.T) {
+	repo := http.FileServer(http.Dir("testdata/repo.git"))
+	mux := http.NewServeMux()
+	mux.Handle("/app1/.git/", http.StripPrefix("/app1/.git/", repo))
+	mux.Handle("/app2/.git/", http.StripPrefix("/app2/.git/", repo))
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetWordlist(nil), dumper.SetDedupe(false))
+
+	dump := func(path string) *dumper.Result {
+		target, err := url.Parse(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := d.Dump(context.Background(), target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	first, second := dump("/app1"), dump("/app2")
+	if first.Dir == second.Dir {
+		t.Fatalf("Expected targets of one host to use their own directories got %s", first.Dir)
+	}
+
+	// files left by an earlier dump are not trusted
+	stale := filepath.Join(first.Dir, ".git", "refs", "heads", "stale")
+	if err := os.WriteFile(stale, []byte(strings.Repeat("a", 40)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	again := dump("/app1")
+	if _, err := os.Stat(stale); err == nil || again.Objects != first.Objects || again.Integrity.Objects != 16 {
+		t.Fatalf("Expected a clean dump got %d objects", again.Objects)
+	}
+}
+
+func TestDumper_DumpNotExposed(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+
+	t.Cleanup(func() {
+		ts.Close()
+	})
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dumper.NewDumper(client.NewClient(), t.TempDir()).Dump(context.Background(), target); err == nil {
+		t.Fatal("Expected error for target without exposed git directory")
+	}
+}
+
+func TestHeadChecker_Check(t *testing.T) {
+	ts := httptest.NewServer(http.StripPrefix("/.git/", http.FileServer(http.Dir("testdata/repo.git"))))
+
+	t.Cleanup(func() {
+		ts.Close()
+	})
+
+	testCases := []struct {
+		name     string
+		path     string
+		findings int
+		head     string
+	}{
+		{"exposed", "/.git", 1, "b8ce7a3bce95c90987ad45a3a5e1892f534ea463"},
+		{"not exposed", "/other", 0, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings, err := dumper.NewHeadChecker(client.NewClient(), tc.path).Check(context.Background(), target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(findings) != tc.findings {
+				t.Fatalf("Expected %d findings got %d", tc.findings, len(findings))
+			}
+
+			if tc.findings > 0 {
+				if findings[0].Metadata["head"] != tc.head || findings[0].Metadata["ref"] != "refs/heads/main" {
+					t.Fatalf("Unexpected metadata %v", findings[0].Metadata)
+				}
+			}
+		})
+	}
+}
+
+// repo builds a git directory from loose objects, the zero format is SHA-1.
+type repo struct {
+	t      *testing.T
+	dir    string
+	format git.ObjectFormat
+}
+
+func (r *repo) write(typ git.ObjectType, data string) string {
+	r.t.Helper()
+
+	o := &git.Object{Type: typ, Data: []byte(data), Format: r.format}
+	b, err := git.EncodeLoose(o)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+
+	p := filepath.Join(r.dir, filepath.FromSlash(git.LoosePath(o.Hash())))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(p, b, 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+
+	return o.Hash()
+}
+
+// commit writes a commit of a flat tree of files.
+func (r *repo) commit(files map[string]string, when int, parents ...string) string {
+	r.t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tree strings.Builder
+	for _, name := range names {
+		raw, _ := hex.DecodeString(r.write(git.ObjectBlob, files[name]))
+		tree.WriteString("100644 " + name + "\x00" + string(raw))
+	}
+
+	commit := "tree " + r.write(git.ObjectTree, tree.String()) + "\n"
+	for _, p := range parents {
+		commit += "parent " + p + "\n"
+	}
+	commit += fmt.Sprintf("author Dev <dev@example.com> %d +0000\ncommitter Dev <dev@example.com> %d +0000\n\nchange\n", when, when)
+
+	return r.write(git.ObjectCommit, commit)
+}
+
+func TestDumper_DumpSecrets(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	const (
+		dotenv = "APP_ENV=production\nDB_PASSWORD=Xk29fQ1zLp0w\n"
+		aws    = "package main\n\nconst key = \"AKIAQWERTYUIOP123456\"\n"
+	)
+
+	// the environment file is deleted in the second commit, the key survives an unrelated change
+	first := r.commit(map[string]string{".env": dotenv, "main.go": aws}, 1700000000)
+	second := r.commit(map[string]string{"main.go": aws + "\n// changed\n"}, 1700000100, first)
+
+	if err := os.WriteFile(filepath.Join(r.dir, "HEAD"), []byte(second+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.StripPrefix("/.git/", http.FileServer(http.Dir(r.dir))))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := secrets.Builtin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner, err := secrets.NewScanner(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetSecrets(scanner)).Dump(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Secrets) != 2 {
+		t.Fatalf("Expected 2 secrets got %+v", result.Secrets)
+	}
+
+	for _, expected := range []struct {
+		rule, path, commit string
+		line               int
+	}{
+		{"dotenv-secret", ".env", first, 2},
+		{"aws-access-key", "main.go", first, 3},
+	} {
+		found := false
+		for _, s := range result.Secrets {
+			if s.Rule != expected.rule {
+				continue
+			}
+			found = true
+
+			if s.Path != expected.path || s.Commit != expected.commit || s.Line != expected.line || s.Author != "Dev" {
+				t.Fatalf("Unexpected secret %+v", s)
+			}
+			if strings.Contains(s.Preview, "Xk29fQ1zLp0w") || strings.Contains(s.Preview, "AKIAQWERTYUIOP123456") {
+				t.Fatalf("Expected a redacted preview got %s", s.Preview)
+			}
+		}
+
+		if !found {
+			t.Fatalf("Expected a %s secret", expected.rule)
+		}
+	}
+}
+
+func TestDumper_DumpSecretsNested(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	tree := func(entries ...string) string {
+		var b strings.Builder
+		for i := 0; i < len(entries); i += 3 {
+			raw, _ := hex.DecodeString(entries[i+2])
+			b.WriteString(entries[i] + " " + entries[i+1] + "\x00" + string(raw))
+		}
+		return r.write(git.ObjectTree, b.String())
+	}
+	commit := func(tree string, when int, parents ...string) string {
+		c := "tree " + tree + "\n"
+		for _, p := range parents {
+			c += "parent " + p + "\n"
+		}
+		c += fmt.Sprintf("author Dev <dev@example.com> %d +0000\ncommitter Dev <dev@example.com> %d +0000\n\nchange\n", when, when)
+		return r.write(git.ObjectCommit, c)
+	}
+
+	dotenv := r.write(git.ObjectBlob, "APP_ENV=production\nDB_PASSWORD=Xk29fQ1zLp0w\n")
+	notes := r.write(git.ObjectBlob, "notes\n")
+
+	// the second commit changes the directory of the environment file but not the file
+	first := commit(tree("40000", "config", tree("100644", ".env", dotenv)), 1700000000)
+	second := commit(tree("40000", "config", tree("100644", ".env", dotenv, "100644", "notes.txt", notes)), 1700000100, first)
+
+	if err := os.WriteFile(filepath.Join(r.dir, "HEAD"), []byte(second+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.StripPrefix("/.git/", http.FileServer(http.Dir(r.dir))))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := secrets.Builtin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner, err := secrets.NewScanner(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetSecrets(scanner)).Dump(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Secrets) != 1 || result.Secrets[0].Path != "config/.env" || result.Secrets[0].Commit != first {
+		t.Fatalf("Expected the secret of the first commit got %+v", result.Secrets)
+	}
+}
+
+func TestDumper_DumpSecretsUndated(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	dotenv, _ := hex.DecodeString(r.write(git.ObjectBlob, "APP_ENV=production\nDB_PASSWORD=Xk29fQ1zLp0w\n"))
+	notes, _ := hex.DecodeString(r.write(git.ObjectBlob, "notes\n"))
+
+	withSecret := r.write(git.ObjectTree, "100644 .env\x00"+string(dotenv))
+	without := r.write(git.ObjectTree, "100644 notes.txt\x00"+string(notes))
+
+	// commits without a committer line have no date, the secret is introduced twice
+	commit := func(tree string, parents ...string) string {
+		c := "tree " + tree + "\n"
+		for _, p := range parents {
+			c += "parent " + p + "\n"
+		}
+		return r.write(git.ObjectCommit, c+"author Dev <dev@example.com> 1700000000 +0000\n\nchange\n")
+	}
+	first := commit(withSecret)
+	second := commit(without, first)
+	third := commit(withSecret, second)
+
+	if err := os.WriteFile(filepath.Join(r.dir, "HEAD"), []byte(third+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.StripPrefix("/.git/", http.FileServer(http.Dir(r.dir))))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := secrets.Builtin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner, err := secrets.NewScanner(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetSecrets(scanner)).Dump(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Secrets) != 1 || result.Secrets[0].Date != nil || result.Secrets[0].Commit == "" {
+		t.Fatalf("Expected an undated secret got %+v", result.Secrets)
+	}
+}
+
+func TestDumper_DumpCheckoutLimits(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	// every level holds the level below twice, a full checkout writes 2^40 files
+	raw, _ := hex.DecodeString(r.write(git.ObjectBlob, "shared\n"))
+	tree := r.write(git.ObjectTree, "100644 file\x00"+string(raw))
+	for i := 0; i < 40; i++ {
+		raw, _ = hex.DecodeString(tree)
+		tree = r.write(git.ObjectTree, "40000 a\x00"+string(raw)+"40000 b\x00"+string(raw))
+	}
+
+	head := r.write(git.ObjectCommit, "tree "+tree+"\nauthor Dev <dev@example.com> 1700000000 +0000\n"+
+		"committer Dev <dev@example.com> 1700000000 +0000\n\nchange\n")
+	if err := os.WriteFile(filepath.Join(r.dir, "HEAD"), []byte(head+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.StripPrefix("/.git/", http.FileServer(http.Dir(r.dir))))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, limits := range [][2]int64{{1000, 0}, {0, 64}} {
+		d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetWordlist(nil),
+			dumper.SetCheckout(true), dumper.SetCheckoutLimits(int(limits[0]), limits[1]))
+
+		if _, err := d.Dump(context.Background(), target); err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+			t.Fatalf("Expected the checkout to exceed the limits %v got %v", limits, err)
+		}
+	}
+}
+
+func TestDumper_DumpReflog(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	// the first commit was amended and the third is only left in ORIG_HEAD after a reset
+	first := r.commit(map[string]string{"config.php": "<?php $password = 'first';\n"}, 1700000000)
+	second := r.commit(map[string]string{"config.php": "<?php $password = getenv('DB');\n"}, 1700000100)
+	third := r.commit(map[string]string{"notes.txt": "wip\n"}, 1700000200, second)
+
+	files := map[string]string{
+		"HEAD":            "ref: refs/heads/main\n",
+		"refs/heads/main": second + "\n",
+		"ORIG_HEAD":       third + "\n",
+		"logs/HEAD": "0000000000000000000000000000000000000000 " + first + " Dev <dev@example.com> 1700000000 +0000\tcommit (initial): one\n" +
+			first + " " + second + " Ann <ann@example.com> 1700000100 +0100\tcommit (amend): one\n",
+		"logs/refs/heads/main": "0000000000000000000000000000000000000000 " + second + " Ann <ann@example.com> 1700000050 +0100\tbranch: Created from HEAD\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(r.dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := httptest.NewServer(http.StripPrefix("/.git/", http.FileServer(http.Dir(r.dir))))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := dumper.NewDumper(client.NewClient(), t.TempDir()).Dump(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// three commits with a tree and a blob each
+	if result.Objects != 9 || len(result.Missing) != 0 {
+		t.Fatalf("Expected 9 objects got %d missing %v", result.Objects, result.Missing)
+	}
+
+	if result.Refs["ORIG_HEAD"] != third {
+		t.Fatalf("Expected ORIG_HEAD %s got %v", third, result.Refs)
+	}
+
+	if len(result.Timeline) != 3 {
+		t.Fatalf("Expected 3 ref updates got %+v", result.Timeline)
+	}
+
+	expected := []dumper.RefUpdate{
+		{Ref: "HEAD", New: first, Author: "Dev"},
+		{Ref: "refs/heads/main", New: second, Author: "Ann"},
+		{Ref: "HEAD", Old: first, New: second, Author: "Ann", Message: "commit (amend): one"},
+	}
+	for i, e := range expected {
+		u := result.Timeline[i]
+		if u.Ref != e.Ref || u.Old != e.Old || u.New != e.New || u.Author != e.Author || (e.Message != "" && u.Message != e.Message) {
+			t.Fatalf("Unexpected update %d %+v", i, u)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(result.Dir, ".git", "logs", "refs", "heads", "main")); err != nil {
+		t.Fatalf("Expected the reflog to be mirrored. Error: %s", err)
+	}
+}
+
+func TestDumper_DumpWordlist(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	main := r.commit(map[string]string{"index.php": "<?php echo 1;\n"}, 1700000000)
+	develop := r.commit(map[string]string{"index.php": "<?php echo 2;\n"}, 1700000100, main)
+	master := r.commit(map[string]string{"index.php": "<?php echo 3;\n"}, 1700000200)
+	custom := r.commit(map[string]string{"secret.txt": "hidden\n"}, 1700000300)
+
+	files := map[string]string{
+		"HEAD":                       "ref: refs/heads/main\n",
+		"refs/heads/main":            main + "\n",
+		"refs/heads/develop":         develop + "\n",
+		"refs/remotes/origin/HEAD":   "ref: refs/remotes/origin/master\n",
+		"refs/remotes/origin/master": master + "\n",
+		"refs/heads/client/acme":     custom + "\n",
+		"COMMIT_EDITMSG":             "Remove credentials\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(r.dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every other path is answered with a soft 404 page
+	fileServer := http.StripPrefix("/.git/", http.FileServer(http.Dir(r.dir)))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := strings.TrimPrefix(req.URL.Path, "/.git/")
+		if _, err := os.Stat(filepath.Join(r.dir, filepath.FromSlash(name))); err != nil {
+			_, _ = w.Write([]byte("Page not found, try the homepage"))
+			return
+		}
+		fileServer.ServeHTTP(w, req)
+	}))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetWordlist(append(dumper.Wordlist(), "refs/heads/client/acme")))
+
+	result, err := d.Dump(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"COMMIT_EDITMSG", "refs/heads/client/acme", "refs/heads/develop", "refs/remotes/origin/HEAD", "refs/remotes/origin/master"}
+	if strings.Join(result.Discovered, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected %v got %v", expected, result.Discovered)
+	}
+
+	if result.Refs["refs/remotes/origin/HEAD"] != master || result.Refs["refs/heads/client/acme"] != custom {
+		t.Fatalf("Unexpected refs %v", result.Refs)
+	}
+
+	// four commits with a tree and a blob each
+	if result.Objects != 12 || len(result.Missing) != 0 {
+		t.Fatalf("Expected 12 objects got %d missing %v", result.Objects, result.Missing)
+	}
+}
+
+func TestDumper_DumpShared(t *testing.T) {
+	var requests [2]int
+
+	targets := make([]*url.URL, 2)
+	for i := range targets {
+		i := i
+		files := http.StripPrefix("/.git/", http.FileServer(http.Dir("testdata/repo.git")))
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/.git/objects/") && r.URL.Path != "/.git/objects/info/packs" {
+				requests[i]++
+			}
+			files.ServeHTTP(w, r)
+		}))
+		t.Cleanup(ts.Close)
+
+		u, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets[i] = u
+	}
+
+	d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetWordlist(nil))
+
+	first, err := d.Dump(context.Background(), targets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := d.Dump(context.Background(), targets[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Shared != "" || second.Shared != first.Target || second.Fingerprint != first.Fingerprint {
+		t.Fatalf("Expected the second dump to share the first got %q %+v %+v", second.Shared, first.Fingerprint, second.Fingerprint)
+	}
+
+	if first.Fingerprint.Head != first.Head || first.Fingerprint.Index == "" || second.Objects != first.Objects {
+		t.Fatalf("Unexpected fingerprint %+v", first.Fingerprint)
+	}
+
+	if requests[0] == 0 || requests[1] != 0 {
+		t.Fatalf("Expected objects to be downloaded once got %v", requests)
+	}
+
+	// without deduplication every target is downloaded
+	d = dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetWordlist(nil), dumper.SetDedupe(false))
+	for _, target := range targets {
+		if result, err := d.Dump(context.Background(), target); err != nil || result.Shared != "" {
+			t.Fatalf("Unexpected shared dump %v", err)
+		}
+	}
+
+	if requests[1] == 0 {
+		t.Fatal("Expected objects of the second target to be downloaded")
+	}
+}
+
+func TestDumper_DumpCache(t *testing.T) {
+	var objects int
+
+	files := http.StripPrefix("/.git/", http.FileServer(http.Dir("testdata/repo.git")))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/.git/objects/") && r.URL.Path != "/.git/objects/info/packs" {
+			objects++
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cache.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every run uses a new dumper, only the cache is shared
+	var results []*dumper.Result
+	for i := 0; i < 2; i++ {
+		d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetCache(c), dumper.SetWordlist(nil), dumper.SetCheckout(true))
+
+		result, err := d.Dump(context.Background(), target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, result)
+
+		if i == 0 {
+			if objects == 0 || result.Cached != 0 {
+				t.Fatalf("Expected the first run to download every object got %d requests %d cached", objects, result.Cached)
+			}
+			objects = 0
+		}
+	}
+
+	// the pack, its index and the three loose objects come from the cache
+	if objects != 0 || results[1].Cached != 5 {
+		t.Fatalf("Expected no object requests in the second run got %d requests %d cached", objects, results[1].Cached)
+	}
+
+	if results[1].Objects != results[0].Objects || results[1].Files != results[0].Files || len(results[1].Missing) != 0 {
+		t.Fatalf("Expected the same dump got %d %d objects", results[0].Objects, results[1].Objects)
+	}
+
+	if _, err := os.Stat(filepath.Join(results[1].Dir, "sub", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+}
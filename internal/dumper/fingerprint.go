@@ -0,0 +1,69 @@
+package dumper
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/georlav/githunt/internal/git"
+	"github.com/georlav/githunt/internal/results"
+)
+
+var remoteURL = regexp.MustCompile(`(?m)^\s*url\s*=\s*(\S+)`)
+
+// fingerprint identifies the repository from the mirrored config and index and the HEAD commit.
+func (s *session) fingerprint(head string) results.Fingerprint {
+	f := results.Fingerprint{Head: head}
+
+	if b, err := os.ReadFile(filepath.Join(s.gitDir, "config")); err == nil {
+		if m := remoteURL.FindSubmatch(b); m != nil {
+			f.Remote = results.NormalizeRemote(string(m[1]))
+		}
+	}
+
+	if b, err := os.ReadFile(filepath.Join(s.gitDir, "index")); err == nil {
+		if idx, err := git.ParseIndex(b, s.format); err == nil {
+			f.Index = idx.Checksum
+		}
+	}
+
+	return f
+}
+
+// dedupeKey returns the key of a repository state, the remote is left out since deployments of one remote can be
+// at different commits.
+func dedupeKey(f results.Fingerprint) string {
+	if f.Head == "" {
+		return ""
+	}
+
+	return f.Head + ":" + f.Index
+}
+
+// shared returns the earlier dump of the same repository state.
+func (d *Dumper) shared(f results.Fingerprint) *Result {
+	key := dedupeKey(f)
+	if !d.dedupe || key == "" {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.dumped[key]
+}
+
+// remember records a completed dump for deduplication.
+func (d *Dumper) remember(r *Result) {
+	key := dedupeKey(r.Fingerprint)
+	if !d.dedupe || key == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.dumped[key]; !ok {
+		d.dumped[key] = r
+	}
+}
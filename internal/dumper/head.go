@@ -0,0 +1,107 @@
+package dumper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/git"
+)
+
+// HeadChecker reports targets that serve a valid HEAD file. The commit HEAD points to is added to the
+// finding metadata so that two scans can tell when an exposed repository moved.
+type HeadChecker struct {
+	client  *client.Client
+	gitPath string
+}
+
+// NewHeadChecker creates the git-head checker for the git directory at gitPath.
+func NewHeadChecker(c *client.Client, gitPath string) *HeadChecker {
+	return &HeadChecker{
+		client:  c,
+		gitPath: gitPath,
+	}
+}
+
+func (h *HeadChecker) Name() string {
+	return "git-head"
+}
+
+func (h *HeadChecker) Paths() []string {
+	return []string{h.gitPath + "/HEAD"}
+}
+
+func (h *HeadChecker) Check(ctx context.Context, target *url.URL) ([]checker.Finding, error) {
+	base := checker.JoinPath(target, h.gitPath+"/")
+	u := checker.JoinPath(base, "HEAD")
+
+	var evidence []*client.Exchange
+
+	b, err := h.fetch(ctx, u, &evidence)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	// soft 404 pages and other content are not a finding
+	ref, hash, err := git.ParseHead(b)
+	if err != nil {
+		return nil, nil
+	}
+
+	metadata := make(map[string]string)
+	if ref != "" {
+		metadata["ref"] = ref
+		hash = h.resolve(ctx, base, ref, &evidence)
+	}
+	if hash != "" {
+		metadata["head"] = hash
+	}
+
+	return []checker.Finding{{
+		Checker:  h.Name(),
+		Severity: "medium",
+		URL:      u,
+		Metadata: metadata,
+		Evidence: evidence,
+	}}, nil
+}
+
+// resolve looks the ref up as a loose ref and then in packed-refs.
+func (h *HeadChecker) resolve(ctx context.Context, base *url.URL, ref string, evidence *[]*client.Exchange) string {
+	if !validRef(ref) {
+		return ""
+	}
+
+	if b, _ := h.fetch(ctx, checker.JoinPath(base, ref), evidence); b != nil {
+		if _, hash, err := git.ParseHead(b); err == nil && hash != "" {
+			return hash
+		}
+	}
+
+	if b, _ := h.fetch(ctx, checker.JoinPath(base, "packed-refs"), evidence); b != nil {
+		return git.ParsePackedRefs(b)[ref]
+	}
+
+	return ""
+}
+
+// fetch returns nil without error when the file is not served, served files are added to evidence.
+func (h *HeadChecker) fetch(ctx context.Context, u *url.URL, evidence *[]*client.Exchange) ([]byte, error) {
+	resp, err := h.client.Do(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s. Error: %w", u.Path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+
+	if resp.Exchange != nil {
+		*evidence = append(*evidence, resp.Exchange)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,274 @@
+package dumper
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/georlav/githunt/internal/git"
+)
+
+// Integrity summarizes how much of a dumped repository is real. Every object of the git directory is verified
+// against its hash and reachability is walked from the refs.
+type Integrity struct {
+	// Objects is the number of stored objects whose content matches their hash.
+	Objects int `json:"objects"`
+	// Missing objects are reachable from the refs but were not recovered.
+	Missing []string `json:"missing,omitempty"`
+	// Corrupt lists the loose objects and packs, relative to the git directory, whose content does not match their
+	// name or checksum.
+	Corrupt []string `json:"corrupt,omitempty"`
+	// Unreachable objects were recovered, from reflogs, packs or the index, but no ref reaches them.
+	Unreachable []string `json:"unreachable,omitempty"`
+	// HeadFiles counts the files of the HEAD tree, HeadRecovered the ones whose blob was recovered. Files of
+	// subtrees that were not recovered are unknown and not counted.
+	HeadFiles     int     `json:"head_files"`
+	HeadRecovered int     `json:"head_recovered"`
+	HeadCoverage  float64 `json:"head_coverage"`
+	// Complete commits have their whole tree recovered, their parents may be missing.
+	Complete   []string `json:"complete,omitempty"`
+	Incomplete []string `json:"incomplete,omitempty"`
+}
+
+// Verify checks the objects stored in gitDir and reports the completeness of the repository reachable from refs.
+// Objects are hashed with the object format of the repository, objects larger than maxSize are not read.
+func Verify(gitDir string, format git.ObjectFormat, maxSize int64, refs map[string]string) (*Integrity, error) {
+	var result Integrity
+
+	objects, corrupt, err := readObjects(gitDir, format, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	result.Objects = len(objects)
+	result.Corrupt = corrupt
+
+	// reachability from every ref
+	reachable := make(map[string]struct{})
+	missing := make(map[string]struct{})
+
+	queue := make([]string, 0, len(refs))
+	for _, hash := range refs {
+		queue = append(queue, hash)
+	}
+
+	for len(queue) > 0 {
+		hash := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		if _, ok := reachable[hash]; ok || !format.IsHash(hash) {
+			continue
+		}
+
+		o, ok := objects[hash]
+		if !ok {
+			missing[hash] = struct{}{}
+			continue
+		}
+		reachable[hash] = struct{}{}
+
+		refs, _ := git.References(o)
+		queue = append(queue, refs...)
+	}
+
+	result.Missing = sortedKeys(missing)
+
+	for hash := range objects {
+		if _, ok := reachable[hash]; !ok {
+			result.Unreachable = append(result.Unreachable, hash)
+		}
+	}
+	sort.Strings(result.Unreachable)
+
+	complete := make(map[string]bool)
+	for hash, o := range objects {
+		if o.Type != git.ObjectCommit {
+			continue
+		}
+
+		c, err := git.ParseCommit(o.Data)
+		if err == nil && treeComplete(objects, c.Tree, complete) {
+			result.Complete = append(result.Complete, hash)
+			continue
+		}
+		result.Incomplete = append(result.Incomplete, hash)
+	}
+	sort.Strings(result.Complete)
+	sort.Strings(result.Incomplete)
+
+	if o, ok := objects[refs["HEAD"]]; ok && o.Type == git.ObjectCommit {
+		if c, err := git.ParseCommit(o.Data); err == nil {
+			count := treeFiles(objects, c.Tree, make(map[string]fileCount))
+			result.HeadFiles, result.HeadRecovered = count.files, count.recovered
+		}
+	}
+	if result.HeadFiles > 0 {
+		result.HeadCoverage = math.Round(float64(result.HeadRecovered)/float64(result.HeadFiles)*10000) / 10000
+	}
+
+	return &result, nil
+}
+
+// readObjects loads the loose objects and packs of a git directory. Loose objects that do not match their path
+// and packs with an invalid checksum are returned as corrupt, the valid objects of a damaged pack are kept.
+func readObjects(gitDir string, format git.ObjectFormat, maxSize int64) (map[string]*git.Object, []string, error) {
+	objects := make(map[string]*git.Object)
+
+	var (
+		corrupt []string
+		packs   []string
+	)
+
+	root := filepath.Join(gitDir, "objects")
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(gitDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if strings.HasPrefix(rel, "objects/pack/") {
+			if strings.HasSuffix(rel, ".pack") {
+				packs = append(packs, rel)
+			}
+			return nil
+		}
+
+		dir, name := filepath.Base(filepath.Dir(p)), d.Name()
+		if !format.IsHash(dir + name) {
+			return nil
+		}
+
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("reading %s. Error: %w", rel, err)
+		}
+
+		o, err := git.DecodeLoose(b, format, maxSize)
+		if err != nil || o.Hash() != dir+name {
+			corrupt = append(corrupt, rel)
+			return nil
+		}
+		objects[dir+name] = o
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("verifying %s. Error: %w", gitDir, err)
+	}
+
+	lookup := func(hash string) *git.Object {
+		return objects[hash]
+	}
+
+	for _, rel := range packs {
+		b, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s. Error: %w", rel, err)
+		}
+
+		parsed, err := git.ParsePack(b, format, maxSize, lookup)
+		if err != nil || !format.Checksum(b) {
+			corrupt = append(corrupt, rel)
+		}
+		for _, o := range parsed {
+			objects[o.Hash()] = o
+		}
+	}
+
+	sort.Strings(corrupt)
+
+	return objects, corrupt, nil
+}
+
+// treeComplete reports whether a tree and every tree and blob below it were recovered.
+func treeComplete(objects map[string]*git.Object, hash string, memo map[string]bool) bool {
+	if done, ok := memo[hash]; ok {
+		return done
+	}
+
+	o, ok := objects[hash]
+	if !ok || o.Type != git.ObjectTree {
+		return false
+	}
+
+	entries, err := git.ParseTree(o.Data, o.Format)
+	complete := err == nil
+	for _, e := range entries {
+		if !complete {
+			break
+		}
+
+		switch {
+		case e.IsSubmodule():
+		case e.IsTree():
+			complete = treeComplete(objects, e.Hash, memo)
+		default:
+			_, complete = objects[e.Hash]
+		}
+	}
+	memo[hash] = complete
+
+	return complete
+}
+
+// fileCount is the number of files of a tree and the ones whose blob was recovered.
+type fileCount struct {
+	files, recovered int
+}
+
+// treeFiles counts the files of a tree and the ones whose blob was recovered, subtrees shared by several
+// directories are walked once and counted at every path they appear in.
+func treeFiles(objects map[string]*git.Object, hash string, memo map[string]fileCount) fileCount {
+	if count, ok := memo[hash]; ok {
+		return count
+	}
+
+	var count fileCount
+
+	o, ok := objects[hash]
+	if !ok || o.Type != git.ObjectTree {
+		return count
+	}
+
+	entries, err := git.ParseTree(o.Data, o.Format)
+	if err != nil {
+		return count
+	}
+
+	for _, e := range entries {
+		switch {
+		case e.IsSubmodule():
+		case e.IsTree():
+			sub := treeFiles(objects, e.Hash, memo)
+			count.files += sub.files
+			count.recovered += sub.recovered
+		default:
+			count.files++
+			if _, ok := objects[e.Hash]; ok {
+				count.recovered++
+			}
+		}
+	}
+	memo[hash] = count
+
+	return count
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
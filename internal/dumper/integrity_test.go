@@ -0,0 +1,82 @@
+package dumper_test
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/georlav/githunt/internal/dumper"
+	"github.com/georlav/githunt/internal/git"
+)
+
+func TestVerify(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	first := r.commit(map[string]string{"a.txt": "one\n", "b.txt": "two\n"}, 1700000000)
+	second := r.commit(map[string]string{"a.txt": "one\n", "c.txt": "three\n"}, 1700000100, first)
+
+	// the blob of c.txt was not recovered
+	lost := (&git.Object{Type: git.ObjectBlob, Data: []byte("three\n")}).Hash()
+	if err := os.Remove(filepath.Join(r.dir, filepath.FromSlash(git.LoosePath(lost)))); err != nil {
+		t.Fatal(err)
+	}
+
+	orphan := r.write(git.ObjectBlob, "orphan\n")
+
+	// a loose object whose content does not match its name
+	bad := r.write(git.ObjectBlob, "bad\n")
+	b, err := git.EncodeLoose(&git.Object{Type: git.ObjectBlob, Data: []byte("tampered\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, filepath.FromSlash(git.LoosePath(bad))), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := dumper.Verify(r.dir, git.SHA1, 0, map[string]string{"HEAD": second, "refs/heads/main": second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := dumper.Integrity{
+		Objects:       7,
+		Missing:       []string{lost},
+		Corrupt:       []string{git.LoosePath(bad)},
+		Unreachable:   []string{orphan},
+		HeadFiles:     2,
+		HeadRecovered: 1,
+		HeadCoverage:  0.5,
+		Complete:      []string{first},
+		Incomplete:    []string{second},
+	}
+
+	if !reflect.DeepEqual(*result, expected) {
+		t.Fatalf("Expected %+v got %+v", expected, *result)
+	}
+}
+
+func TestVerify_SharedTrees(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	// every level holds the level below twice, a walk without memoization visits 2^40 files
+	raw, _ := hex.DecodeString(r.write(git.ObjectBlob, "shared\n"))
+	tree := r.write(git.ObjectTree, "100644 file\x00"+string(raw))
+	for i := 0; i < 40; i++ {
+		raw, _ = hex.DecodeString(tree)
+		tree = r.write(git.ObjectTree, "40000 a\x00"+string(raw)+"40000 b\x00"+string(raw))
+	}
+
+	head := r.write(git.ObjectCommit, "tree "+tree+"\nauthor Dev <dev@example.com> 1700000000 +0000\n"+
+		"committer Dev <dev@example.com> 1700000000 +0000\n\nchange\n")
+
+	result, err := dumper.Verify(r.dir, git.SHA1, 0, map[string]string{"HEAD": head})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.HeadFiles != 1<<40 || result.HeadRecovered != 1<<40 || len(result.Complete) != 1 {
+		t.Fatalf("Unexpected integrity %+v", result)
+	}
+}
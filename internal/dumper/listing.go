@@ -0,0 +1,191 @@
+package dumper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+)
+
+// Directory listing formats.
+const (
+	ListingApache   = "apache"
+	ListingNginx    = "nginx"
+	ListingIIS      = "iis"
+	ListingLighttpd = "lighttpd"
+	ListingGeneric  = "generic"
+)
+
+var hrefPattern = regexp.MustCompile(`(?is)<a\s[^>]*?href\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+
+// Listing is a parsed directory listing page.
+type Listing struct {
+	Format  string
+	Entries []ListingEntry
+}
+
+// ListingEntry is a file or a directory of a listing.
+type ListingEntry struct {
+	Name string
+	Dir  bool
+}
+
+// ParseListing extracts the direct children of the directory at u from an autoindex page. It returns false when
+// the page is not a directory listing.
+func ParseListing(u *url.URL, body []byte) (*Listing, bool) {
+	format := listingFormat(body)
+	if format == "" {
+		return nil, false
+	}
+
+	dir := u.Path
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	listing := Listing{Format: format}
+	seen := make(map[string]struct{})
+
+	for _, m := range hrefPattern.FindAllSubmatch(body, -1) {
+		href := html.UnescapeString(string(bytes.Join(m[1:], nil)))
+
+		ref, err := url.Parse(strings.TrimSpace(href))
+		if err != nil || ref.RawQuery != "" && ref.Path == "" {
+			continue
+		}
+
+		target := u.ResolveReference(ref)
+		if target.Host != u.Host || !strings.HasPrefix(target.Path, dir) {
+			continue
+		}
+
+		// only direct children, parent and sorting links resolve to the directory itself or above it
+		name := strings.TrimPrefix(target.Path, dir)
+		entry := ListingEntry{Name: strings.TrimSuffix(name, "/"), Dir: strings.HasSuffix(name, "/")}
+		if !validName(entry.Name) {
+			continue
+		}
+
+		if _, ok := seen[entry.Name]; ok {
+			continue
+		}
+		seen[entry.Name] = struct{}{}
+
+		listing.Entries = append(listing.Entries, entry)
+	}
+
+	sort.Slice(listing.Entries, func(i, j int) bool {
+		return listing.Entries[i].Name < listing.Entries[j].Name
+	})
+
+	return &listing, true
+}
+
+// listingFormat recognizes the autoindex pages of common servers, it returns an empty string for other pages.
+func listingFormat(body []byte) string {
+	page := bytes.ToLower(body[:min(len(body), 64<<10)])
+
+	switch {
+	case bytes.Contains(page, []byte("[to parent directory]")):
+		return ListingIIS
+	case bytes.Contains(page, []byte("lighttpd")) || bytes.Contains(page, []byte(`<div class="list">`)):
+		return ListingLighttpd
+	case !bytes.Contains(page, []byte("index of")) && !bytes.Contains(page, []byte("directory listing for")):
+		return ""
+	case bytes.Contains(page, []byte("apache")) || bytes.Contains(page, []byte("?c=n;o=d")):
+		return ListingApache
+	case bytes.Contains(page, []byte("nginx")) || bytes.Contains(page, []byte("<hr><pre>")):
+		return ListingNginx
+	default:
+		return ListingGeneric
+	}
+}
+
+// gitListing reports whether a listing holds the entries of a git directory.
+func (l *Listing) gitListing() bool {
+	var head, objects, refs bool
+	for _, e := range l.Entries {
+		switch {
+		case e.Name == "HEAD" && !e.Dir:
+			head = true
+		case e.Name == "objects" && e.Dir:
+			objects = true
+		case e.Name == "refs" && e.Dir:
+			refs = true
+		}
+	}
+
+	return head && (objects || refs)
+}
+
+// ListingChecker reports git directories that can be browsed through a directory listing.
+type ListingChecker struct {
+	client  *client.Client
+	gitPath string
+}
+
+// NewListingChecker creates the git-listing checker for the git directory at gitPath.
+func NewListingChecker(c *client.Client, gitPath string) *ListingChecker {
+	return &ListingChecker{
+		client:  c,
+		gitPath: gitPath,
+	}
+}
+
+func (l *ListingChecker) Name() string {
+	return "git-listing"
+}
+
+func (l *ListingChecker) Paths() []string {
+	return []string{l.gitPath + "/"}
+}
+
+func (l *ListingChecker) Check(ctx context.Context, target *url.URL) ([]checker.Finding, error) {
+	u := checker.JoinPath(target, strings.TrimSuffix(l.gitPath, "/")+"/")
+
+	resp, err := l.client.Do(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s. Error: %w", u.Path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+
+	listing, ok := ParseListing(u, resp.Body)
+	if !ok || !listing.gitListing() {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(listing.Entries))
+	for _, e := range listing.Entries {
+		if e.Dir {
+			names = append(names, e.Name+"/")
+			continue
+		}
+		names = append(names, e.Name)
+	}
+
+	f := checker.Finding{
+		Checker:  l.Name(),
+		Severity: "high",
+		URL:      u,
+		Metadata: map[string]string{
+			"format":  listing.Format,
+			"entries": strings.Join(names, " "),
+		},
+	}
+	if resp.Exchange != nil {
+		f.Evidence = []*client.Exchange{resp.Exchange}
+	}
+
+	return []checker.Finding{f}, nil
+}
@@ -0,0 +1,214 @@
+package dumper_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/dumper"
+)
+
+var listingPages = map[string]string{
+	dumper.ListingApache: `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN">
+<html><head><title>Index of /.git</title></head><body><h1>Index of /.git</h1>
+<table><tr><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th></tr>
+<tr><td><a href="/">Parent Directory</a></td></tr>
+<tr><td><a href="HEAD">HEAD</a></td></tr>
+<tr><td><a href="config">config</a></td></tr>
+<tr><td><a href="objects/">objects/</a></td></tr>
+<tr><td><a href="refs/">refs/</a></td></tr>
+</table><address>Apache/2.4.41 (Ubuntu) Server at example.com Port 80</address></body></html>`,
+	dumper.ListingNginx: `<html>
+<head><title>Index of /.git/</title></head>
+<body>
+<h1>Index of /.git/</h1><hr><pre><a href="../">../</a>
+<a href="objects/">objects/</a>                                           01-Jan-2024 10:00       -
+<a href="refs/">refs/</a>                                              01-Jan-2024 10:00       -
+<a href="HEAD">HEAD</a>                                               01-Jan-2024 10:00      23
+<a href="config">config</a>                                             01-Jan-2024 10:00     137
+</pre><hr></body>
+</html>`,
+	dumper.ListingIIS: `<html><head><title>example.com - /.git/</title></head><body><H1>example.com - /.git/</H1><hr>
+<pre><A HREF="/">[To Parent Directory]</A><br><br>
+ 1/1/2024 10:00 AM        23 <A HREF="/.git/HEAD">HEAD</A><br>
+ 1/1/2024 10:00 AM       137 <A HREF="/.git/config">config</A><br>
+ 1/1/2024 10:00 AM    &lt;dir&gt; <A HREF="/.git/objects/">objects</A><br>
+ 1/1/2024 10:00 AM    &lt;dir&gt; <A HREF="/.git/refs/">refs</A><br></pre><hr></body></html>`,
+	dumper.ListingLighttpd: `<?xml version="1.0" encoding="utf-8"?>
+<html><head><title>Index of /.git/</title></head><body><h2>Index of /.git/</h2>
+<div class="list"><table summary="Directory Listing">
+<tr class="d"><td class="n"><a href="../">Parent Directory</a>/</td></tr>
+<tr class="d"><td class="n"><a href="objects/">objects</a>/</td></tr>
+<tr class="d"><td class="n"><a href="refs/">refs</a>/</td></tr>
+<tr><td class="n"><a href="HEAD">HEAD</a></td></tr>
+<tr><td class="n"><a href='config'>config</a></td></tr>
+</table></div><div class="foot">lighttpd/1.4.59</div></body></html>`,
+	dumper.ListingGeneric: `<html><head><title>Directory listing for /.git/</title></head><body>
+<ul><li><a href=HEAD>HEAD</a></li><li><a href="config">config</a></li><li><a href="objects/">objects/</a></li>
+<li><a href="refs/">refs/</a></li><li><a href="https://other.example.com/.git/evil">evil</a></li>
+<li><a href="objects/pack/">nested</a></li><li><a href="../../etc/">up</a></li></ul></body></html>`,
+}
+
+func TestParseListing(t *testing.T) {
+	u, err := url.Parse("https://example.com/.git/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for format, page := range listingPages {
+		t.Run(format, func(t *testing.T) {
+			listing, ok := dumper.ParseListing(u, []byte(page))
+			if !ok {
+				t.Fatal("Expected a directory listing")
+			}
+
+			if listing.Format != format {
+				t.Fatalf("Expected format %s got %s", format, listing.Format)
+			}
+
+			var names []string
+			for _, e := range listing.Entries {
+				if e.Dir {
+					names = append(names, e.Name+"/")
+					continue
+				}
+				names = append(names, e.Name)
+			}
+
+			if got := strings.Join(names, " "); got != "HEAD config objects/ refs/" {
+				t.Fatalf("Unexpected entries %s", got)
+			}
+		})
+	}
+
+	if _, ok := dumper.ParseListing(u, []byte("<html><body><a href=\"HEAD\">Home</a></body></html>")); ok {
+		t.Fatal("Expected a page without listing markers to be rejected")
+	}
+}
+
+// listingServer serves dir with nginx style directory listings.
+func listingServer(t *testing.T, dir string) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(r.URL.Path, "/.git")))
+
+		entries, err := os.ReadDir(name)
+		if err != nil {
+			http.ServeFile(w, r, name)
+			return
+		}
+
+		fmt.Fprintf(w, "<html><head><title>Index of %s</title></head><body><h1>Index of %s</h1><hr><pre><a href=\"../\">../</a>\n", r.URL.Path, r.URL.Path)
+		for _, e := range entries {
+			n := e.Name()
+			if e.IsDir() {
+				n += "/"
+			}
+			fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", n, n)
+		}
+		fmt.Fprint(w, "</pre><hr></body></html>")
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestDumper_DumpListing(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	main := r.commit(map[string]string{"index.php": "<?php echo 1;\n"}, 1700000000)
+	hidden := r.commit(map[string]string{"wp-config.php": "<?php define('DB', 'x');\n"}, 1700000100)
+
+	for name, content := range map[string]string{
+		"HEAD":                    "ref: refs/heads/main\n",
+		"refs/heads/main":         main + "\n",
+		"refs/heads/x/y/z/hidden": hidden + "\n",
+	} {
+		p := filepath.Join(r.dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := listingServer(t, r.dir)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		depth   int
+		listing bool
+		objects int
+	}{
+		{"crawl", 16, true, 6},
+		{"depth limit", 3, true, 3},
+		{"disabled", 0, false, 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetCrawl(tc.depth, 0), dumper.SetWordlist(nil))
+
+			result, err := d.Dump(context.Background(), target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Listing != tc.listing || result.Objects != tc.objects {
+				t.Fatalf("Expected listing %t and %d objects got %t %d", tc.listing, tc.objects, result.Listing, result.Objects)
+			}
+
+			if tc.listing && result.Refs["refs/heads/main"] != main {
+				t.Fatalf("Expected the crawled refs got %v", result.Refs)
+			}
+		})
+	}
+}
+
+func TestListingChecker_Check(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+	r.commit(map[string]string{"a": "a"}, 1700000000)
+
+	if err := os.WriteFile(filepath.Join(r.dir, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(r.dir, "refs", "heads"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := listingServer(t, r.dir)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := dumper.NewListingChecker(client.NewClient(), "/.git").Check(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(findings) != 1 || findings[0].Severity != "high" || findings[0].Metadata["format"] != dumper.ListingNginx ||
+		!strings.Contains(findings[0].Metadata["entries"], "objects/") {
+		t.Fatalf("Unexpected findings %+v", findings)
+	}
+
+	findings, err = dumper.NewListingChecker(client.NewClient(), "/other").Check(context.Background(), target)
+	if err != nil || len(findings) != 0 {
+		t.Fatalf("Expected no findings got %+v %v", findings, err)
+	}
+}
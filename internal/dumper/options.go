@@ -0,0 +1,115 @@
+package dumper
+
+import (
+	"github.com/georlav/githunt/internal/cache"
+	"github.com/georlav/githunt/internal/secrets"
+)
+
+type Option func(*Dumper)
+
+// SetWorkers change the number of concurrent object downloads.
+func SetWorkers(workers int) Option {
+	return func(args *Dumper) {
+		if workers > 0 {
+			args.workers = workers
+		}
+	}
+}
+
+// SetMaxSize change the maximum size in bytes of a single unpacked object, packs holding larger objects or deltas
+// are rejected.
+func SetMaxSize(size int64) Option {
+	return func(args *Dumper) {
+		if size > 0 {
+			args.maxSize = size
+		}
+	}
+}
+
+// SetGitPath change the path of the git directory relative to the target.
+func SetGitPath(path string) Option {
+	return func(args *Dumper) {
+		args.gitPath = path
+	}
+}
+
+// SetCheckout write the HEAD tree into the output directory after a dump.
+func SetCheckout(checkout bool) Option {
+	return func(args *Dumper) {
+		args.checkout = checkout
+	}
+}
+
+// SetCheckoutLimits change the maximum number of files and directories and the total number of bytes written by
+// a checkout.
+func SetCheckoutLimits(files int, size int64) Option {
+	return func(args *Dumper) {
+		if files > 0 {
+			args.checkoutFiles = files
+		}
+		if size > 0 {
+			args.checkoutSize = size
+		}
+	}
+}
+
+// SetSecrets scan every recovered blob for secrets with the rules of scanner.
+func SetSecrets(scanner *secrets.Scanner) Option {
+	return func(args *Dumper) {
+		args.secrets = scanner
+	}
+}
+
+// SetWordlist change the paths that are probed for refs and metadata files that are not advertised, an empty
+// wordlist disables probing.
+func SetWordlist(words []string) Option {
+	return func(args *Dumper) {
+		args.wordlist = words
+	}
+}
+
+// SetCrawl change the maximum directory depth and the total number of bytes mirrored from git directories with a
+// directory listing, a depth of 0 disables crawling.
+func SetCrawl(depth int, size int64) Option {
+	return func(args *Dumper) {
+		args.crawlDepth = depth
+		if size > 0 {
+			args.crawlSize = size
+		}
+	}
+}
+
+// SetSmart clone repositories through the git-upload-pack service when the target runs a smart HTTP server.
+func SetSmart(smart bool) Option {
+	return func(args *Dumper) {
+		args.smart = smart
+	}
+}
+
+// SetDeployed request every file of the index from the webroot and compare it with the blob of the index.
+func SetDeployed(deployed bool) Option {
+	return func(args *Dumper) {
+		args.deployed = deployed
+	}
+}
+
+// SetDedupe skip the download of repositories whose HEAD and index match an earlier dump.
+func SetDedupe(dedupe bool) Option {
+	return func(args *Dumper) {
+		args.dedupe = dedupe
+	}
+}
+
+// SetCache read objects and packs from a shared cache before requesting them and add the downloaded ones to it.
+func SetCache(c *cache.Cache) Option {
+	return func(args *Dumper) {
+		args.cache = c
+	}
+}
+
+// SetIntegrity verify the recovered objects against their hashes and report the completeness of every dump.
+func SetIntegrity(integrity bool) Option {
+	return func(args *Dumper) {
+		args.integrity = integrity
+	}
+}
@@ -0,0 +1,102 @@
+package dumper
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/georlav/githunt/internal/git"
+)
+
+// files next to HEAD that point to commits of past operations.
+var headFiles = []string{"ORIG_HEAD", "FETCH_HEAD", "MERGE_HEAD", "CHERRY_PICK_HEAD", "REVERT_HEAD"}
+
+// RefUpdate is a movement of a ref recorded in a reflog.
+type RefUpdate struct {
+	Ref     string    `json:"ref"`
+	Old     string    `json:"old,omitempty"`
+	New     string    `json:"new"`
+	Author  string    `json:"author,omitempty"`
+	Email   string    `json:"email,omitempty"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message,omitempty"`
+}
+
+// reflogs mirrors the reflogs of HEAD, the stash and every known ref and the files of past operations such as
+// ORIG_HEAD. It returns the ref movements in chronological order and every commit they mention, these commits
+// often survive amends, rebases and deleted branches.
+func (s *session) reflogs(ctx context.Context, head []byte, refs map[string]string) ([]RefUpdate, []string) {
+	var (
+		timeline []RefUpdate
+		hashes   []string
+	)
+
+	for _, f := range headFiles {
+		b, err := s.mirror(ctx, f)
+		if err != nil {
+			continue
+		}
+
+		found := git.ParseHashes(b)
+		if len(found) > 0 {
+			refs[f] = found[0]
+		}
+		hashes = append(hashes, found...)
+	}
+
+	names := map[string]struct{}{"refs/stash": {}}
+	if ref, _, _ := git.ParseHead(head); ref != "" {
+		names[ref] = struct{}{}
+	}
+	for ref := range refs {
+		if strings.HasPrefix(ref, "refs/") && !strings.HasSuffix(ref, "^{}") {
+			names[ref] = struct{}{}
+		}
+	}
+
+	logs := make([]string, 0, len(names))
+	for ref := range names {
+		logs = append(logs, ref)
+	}
+	sort.Strings(logs)
+	logs = append([]string{"HEAD"}, logs...)
+
+	for _, ref := range logs {
+		// refs are server controlled, only names that are safe as local paths are requested
+		if ref != "HEAD" && !validRef(ref) {
+			continue
+		}
+
+		b, err := s.mirror(ctx, "logs/"+ref)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range git.ParseReflog(b) {
+			update := RefUpdate{
+				Ref:     ref,
+				New:     e.New,
+				Author:  e.Committer.Name,
+				Email:   e.Committer.Email,
+				Time:    e.Committer.When,
+				Message: e.Message,
+			}
+			if !git.IsZeroHash(e.Old) {
+				update.Old = e.Old
+				hashes = append(hashes, e.Old)
+			}
+			if !git.IsZeroHash(e.New) {
+				hashes = append(hashes, e.New)
+			}
+
+			timeline = append(timeline, update)
+		}
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Time.Before(timeline[j].Time)
+	})
+
+	return timeline, hashes
+}
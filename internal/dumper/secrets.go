@@ -0,0 +1,241 @@
+package dumper
+
+import (
+	"sort"
+	"time"
+
+	"github.com/georlav/githunt/internal/git"
+	"github.com/georlav/githunt/internal/secrets"
+)
+
+// Secret is a secret found in a recovered blob.
+type Secret struct {
+	secrets.Match
+	Path string `json:"path,omitempty"`
+	Blob string `json:"blob"`
+	// Commit is the earliest commit whose tree introduced the blob at Path, empty for blobs that are only
+	// referenced by the index or by no recovered commit.
+	Commit string     `json:"commit,omitempty"`
+	Author string     `json:"author,omitempty"`
+	Date   *time.Time `json:"date,omitempty"`
+}
+
+// location is a path where a blob was introduced.
+type location struct {
+	path   string
+	commit string
+	author string
+	date   time.Time
+}
+
+// scanSecrets scans every recovered blob, not only the blobs of the HEAD tree. A secret that stays in a file
+// across commits is reported once, with the commit that introduced it.
+func (s *session) scanSecrets(scanner *secrets.Scanner, idx *git.Index) []Secret {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locations := s.history()
+
+	if idx != nil {
+		for _, e := range idx.Entries {
+			if !hasPath(locations[e.Hash], e.Path) {
+				locations[e.Hash] = append(locations[e.Hash], location{path: e.Path})
+			}
+		}
+	}
+
+	found := make(map[string]Secret)
+
+	for hash, o := range s.objects {
+		if o.Type != git.ObjectBlob {
+			continue
+		}
+
+		locs := locations[hash]
+		if len(locs) == 0 {
+			locs = []location{{}}
+		}
+
+		// path restricted rules give different matches per path
+		matches := make(map[string][]secrets.Match)
+		for _, l := range locs {
+			if _, ok := matches[l.path]; !ok {
+				matches[l.path] = scanner.Scan(l.path, o.Data)
+			}
+
+			for _, m := range matches[l.path] {
+				secret := Secret{
+					Match:  m,
+					Path:   l.path,
+					Blob:   hash,
+					Commit: l.commit,
+					Author: l.author,
+				}
+				if !l.date.IsZero() {
+					date := l.date
+					secret.Date = &date
+				}
+
+				key := m.Fingerprint + "\x00" + l.path
+				if prev, ok := found[key]; !ok || earlier(secret, prev) {
+					found[key] = secret
+				}
+			}
+		}
+	}
+
+	result := make([]Secret, 0, len(found))
+	for _, secret := range found {
+		result = append(result, secret)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+
+		return a.Rule < b.Rule
+	})
+
+	return result
+}
+
+// earlier reports whether a was introduced before b, secrets without a commit come last and commits without a
+// committer time after the dated ones.
+func earlier(a, b Secret) bool {
+	switch {
+	case a.Commit == "" || b.Commit == "":
+		return a.Commit != ""
+	case a.Date == nil || b.Date == nil:
+		if (a.Date == nil) != (b.Date == nil) {
+			return a.Date != nil
+		}
+	case !a.Date.Equal(*b.Date):
+		return a.Date.Before(*b.Date)
+	}
+
+	return a.Commit < b.Commit
+}
+
+// history maps every blob to the paths and commits that introduced it. A commit introduces a blob at a path
+// when none of its recovered parents has the same blob at that path. It must be called with the lock held.
+func (s *session) history() map[string][]location {
+	locations := make(map[string][]location)
+
+	for hash, o := range s.objects {
+		if o.Type != git.ObjectCommit {
+			continue
+		}
+
+		c := s.commit(hash)
+		if c == nil {
+			continue
+		}
+
+		var parents []string
+		for _, p := range c.Parents {
+			if parent := s.commit(p); parent != nil {
+				parents = append(parents, parent.Tree)
+			}
+		}
+
+		s.introduced(c.Tree, parents, "", func(path, blob string) {
+			locations[blob] = append(locations[blob], location{
+				path:   path,
+				commit: hash,
+				author: c.Author.Name,
+				date:   c.Committer.When,
+			})
+		})
+	}
+
+	return locations
+}
+
+// commit returns a recovered commit, it must be called with the lock held.
+func (s *session) commit(hash string) *git.Commit {
+	o := s.objects[hash]
+	if o == nil || o.Type != git.ObjectCommit {
+		return nil
+	}
+
+	c, err := git.ParseCommit(o.Data)
+	if err != nil {
+		return nil
+	}
+
+	return c
+}
+
+// introduced walks a tree and calls fn with the path of every blob that none of the parent trees has at the same
+// path. Subtrees that a parent has unchanged are skipped, so only the changes of a commit are visited.
+func (s *session) introduced(tree string, parents []string, prefix string, fn func(path, blob string)) {
+	// the entries of the parents at this level by name
+	siblings := make([]map[string]string, 0, len(parents))
+	for _, p := range parents {
+		entries := make(map[string]string)
+		for _, e := range s.entries(p) {
+			entries[e.Name] = e.Hash
+		}
+		siblings = append(siblings, entries)
+	}
+
+	for _, e := range s.entries(tree) {
+		if e.IsSubmodule() {
+			continue
+		}
+
+		var (
+			unchanged bool
+			subtrees  []string
+		)
+		for _, entries := range siblings {
+			hash, ok := entries[e.Name]
+			if hash == e.Hash {
+				unchanged = true
+				break
+			}
+			if ok {
+				subtrees = append(subtrees, hash)
+			}
+		}
+		if unchanged {
+			continue
+		}
+
+		if e.IsTree() {
+			s.introduced(e.Hash, subtrees, prefix+e.Name+"/", fn)
+			continue
+		}
+		fn(prefix+e.Name, e.Hash)
+	}
+}
+
+// entries returns the entries of a recovered tree, it must be called with the lock held.
+func (s *session) entries(hash string) []git.TreeEntry {
+	o := s.objects[hash]
+	if o == nil || o.Type != git.ObjectTree {
+		return nil
+	}
+
+	entries, err := git.ParseTree(o.Data, o.Format)
+	if err != nil {
+		return nil
+	}
+
+	return entries
+}
+
+func hasPath(locations []location, path string) bool {
+	for _, l := range locations {
+		if l.path == path {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,367 @@
+package dumper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/git"
+	"github.com/georlav/githunt/internal/metrics"
+)
+
+// smartPaths are the repository paths probed for a smart HTTP server besides the git directory.
+var smartPaths = []string{"", "/.git", "/git", "/repo.git"}
+
+const (
+	advertisementType = "application/x-git-upload-pack-advertisement"
+	uploadPackRequest = "application/x-git-upload-pack-request"
+	uploadPackResult  = "application/x-git-upload-pack-result"
+)
+
+// SmartChecker reports repositories that can be cloned anonymously through the git-upload-pack service of a
+// smart HTTP server.
+type SmartChecker struct {
+	client *client.Client
+	paths  []string
+}
+
+// NewSmartChecker creates the git-smart-http checker, it probes the git directory at gitPath and the common
+// repository paths.
+func NewSmartChecker(c *client.Client, gitPath string) *SmartChecker {
+	paths := []string{strings.TrimSuffix(gitPath, "/")}
+	for _, p := range smartPaths {
+		if p != paths[0] {
+			paths = append(paths, p)
+		}
+	}
+
+	return &SmartChecker{
+		client: c,
+		paths:  paths,
+	}
+}
+
+func (s *SmartChecker) Name() string {
+	return "git-smart-http"
+}
+
+func (s *SmartChecker) Paths() []string {
+	paths := make([]string, 0, len(s.paths))
+	for _, p := range s.paths {
+		paths = append(paths, p+"/info/refs?service=git-upload-pack")
+	}
+
+	return paths
+}
+
+func (s *SmartChecker) Check(ctx context.Context, target *url.URL) ([]checker.Finding, error) {
+	var (
+		findings []checker.Finding
+		errs     []error
+	)
+
+	for _, p := range s.paths {
+		base := checker.JoinPath(target, p+"/")
+
+		var evidence []*client.Exchange
+
+		adv, err := advertise(ctx, s.client, base, &evidence)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if adv == nil {
+			continue
+		}
+
+		// the object format is requested back, an unsupported one falls back to SHA-1 and fails on the server
+		format, _ := adv.ObjectFormat()
+
+		refs, head := adv.Refs, adv.Head
+		if adv.Version == 2 {
+			if refs, head, err = lsRefs(ctx, s.client, base, format, &evidence); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		metadata := map[string]string{
+			"protocol": "v" + strconv.Itoa(adv.Version),
+			"refs":     strconv.Itoa(len(refs)),
+		}
+		if head != "" {
+			metadata["ref"] = head
+		}
+		if hash := refs["HEAD"]; hash != "" {
+			metadata["head"] = hash
+		}
+		if agent, ok := adv.Capability("agent"); ok {
+			metadata["agent"] = agent
+		}
+		if _, ok := adv.Capability("object-format"); ok {
+			metadata["format"] = format.String()
+		}
+
+		u := checker.JoinPath(base, "info/refs")
+		u.RawQuery = "service=git-upload-pack"
+
+		findings = append(findings, checker.Finding{
+			Checker:  s.Name(),
+			Severity: "high",
+			URL:      u,
+			Metadata: metadata,
+			Evidence: evidence,
+		})
+	}
+
+	return findings, errors.Join(errs...)
+}
+
+// advertise requests the ref advertisement of the repository at base. It returns nil without error when the
+// service is not served anonymously or the response is not a valid advertisement.
+func advertise(ctx context.Context, c *client.Client, base *url.URL, evidence *[]*client.Exchange) (*git.Advertisement, error) {
+	u := checker.JoinPath(base, "info/refs")
+	u.RawQuery = "service=git-upload-pack"
+
+	header := http.Header{"Git-Protocol": {"version=2"}}
+
+	resp, err := c.Do(ctx, http.MethodGet, u, header)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s. Error: %w", u.Path, err)
+	}
+
+	// dumb servers answer with the plain info/refs file
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), advertisementType) {
+		return nil, nil
+	}
+
+	adv, err := git.ParseAdvertisement(resp.Body)
+	if err != nil {
+		return nil, nil
+	}
+
+	if evidence != nil && resp.Exchange != nil {
+		*evidence = append(*evidence, resp.Exchange)
+	}
+
+	return adv, nil
+}
+
+// uploadPack sends a request to the git-upload-pack service of the repository at base.
+func uploadPack(ctx context.Context, c *client.Client, base *url.URL, version int, body []byte) (*client.Response, error) {
+	u := checker.JoinPath(base, "git-upload-pack")
+
+	header := http.Header{
+		"Content-Type": {uploadPackRequest},
+		"Accept":       {uploadPackResult},
+	}
+	if version == 2 {
+		header.Set("Git-Protocol", "version=2")
+	}
+
+	resp, err := c.Send(ctx, http.MethodPost, u, header, body)
+	if err != nil {
+		return nil, fmt.Errorf("POST %s. Error: %w", u.Path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("POST %s. Error: unexpected status %d", u.Path, resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// lsRefs lists the refs of a protocol v2 repository and the symbolic ref of HEAD.
+func lsRefs(
+	ctx context.Context, c *client.Client, base *url.URL, format git.ObjectFormat, evidence *[]*client.Exchange,
+) (map[string]string, string, error) {
+	var body bytes.Buffer
+	body.Write(git.PktLine("command=ls-refs\n"))
+	body.Write(objectFormatCapability(format))
+	body.Write(git.DelimPkt)
+	body.Write(git.PktLine("peel\n"))
+	body.Write(git.PktLine("symrefs\n"))
+	body.Write(git.FlushPkt)
+
+	resp, err := uploadPack(ctx, c, base, 2, body.Bytes())
+	if err != nil {
+		return nil, "", err
+	}
+
+	if evidence != nil && resp.Exchange != nil {
+		*evidence = append(*evidence, resp.Exchange)
+	}
+
+	return git.ParseLsRefs(resp.Body)
+}
+
+// smart clones the repository when the target runs a smart HTTP server. The refs are saved as loose refs and
+// added to the result, the symbolic ref of HEAD is returned.
+func (s *session) smart(ctx context.Context, result *Result) (string, error) {
+	refs, head, version, err := s.clone(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for name, hash := range refs {
+		if validRef(name) {
+			if err := s.save(name, []byte(hash+"\n")); err != nil {
+				return "", err
+			}
+		}
+		result.Refs[name] = hash
+	}
+	result.Smart = version
+
+	return head, nil
+}
+
+// saveHead writes HEAD as the symbolic ref or the object id that the server advertised.
+func (s *session) saveHead(ref, hash string) error {
+	switch {
+	case validRef(ref):
+		return s.save("HEAD", []byte("ref: "+ref+"\n"))
+	case git.IsHash(hash):
+		return s.save("HEAD", []byte(hash+"\n"))
+	}
+
+	return nil
+}
+
+// clone fetches every advertised ref through the git-upload-pack service. It returns the refs, the symbolic
+// ref of HEAD and the protocol version, an empty version means the service is not available.
+func (s *session) clone(ctx context.Context) (refs map[string]string, head, version string, err error) {
+	c := s.dumper.client
+
+	adv, err := advertise(ctx, c, s.base, nil)
+	if err != nil || adv == nil {
+		return nil, "", "", err
+	}
+
+	format, err := adv.ObjectFormat()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("cloning %s. Error: %w", s.base, err)
+	}
+	s.format = format
+
+	refs, head = adv.Refs, adv.Head
+	if adv.Version == 2 {
+		if refs, head, err = lsRefs(ctx, c, s.base, format, nil); err != nil {
+			return nil, "", "", err
+		}
+	}
+	version = "v" + strconv.Itoa(adv.Version)
+
+	wants := make([]string, 0, len(refs))
+	seen := make(map[string]struct{})
+	for name, hash := range refs {
+		if _, ok := seen[hash]; ok || strings.HasSuffix(name, "^{}") {
+			continue
+		}
+		seen[hash] = struct{}{}
+		wants = append(wants, hash)
+	}
+	sort.Strings(wants)
+
+	// an empty repository has nothing to fetch
+	if len(wants) == 0 {
+		return refs, head, version, nil
+	}
+
+	body, sideband := fetchRequest(adv, format, wants)
+
+	resp, err := uploadPack(ctx, c, s.base, adv.Version, body)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	pack, err := git.ReadUploadPack(resp.Body, adv.Version, sideband)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("cloning %s. Error: %w", s.base, err)
+	}
+
+	// a partially parsed pack still holds usable objects
+	objects, _ := git.ParsePack(pack, format, s.dumper.maxSize, s.object)
+	for _, o := range objects {
+		b, err := git.EncodeLoose(o)
+		if err != nil {
+			continue
+		}
+		if err := s.save(git.LoosePath(o.Hash()), b); err != nil {
+			return nil, "", "", err
+		}
+		s.add(o.Hash(), o)
+	}
+	s.toCache(objects...)
+	metrics.DumpObjects.Add(float64(len(objects)), "cloned")
+
+	return refs, head, version, nil
+}
+
+// fetchRequest builds the upload-pack request that wants every object, only capabilities the server advertised
+// are requested in protocol v0.
+func fetchRequest(adv *git.Advertisement, format git.ObjectFormat, wants []string) (body []byte, sideband bool) {
+	var b bytes.Buffer
+
+	if adv.Version == 2 {
+		b.Write(git.PktLine("command=fetch\n"))
+		b.Write(objectFormatCapability(format))
+		b.Write(git.DelimPkt)
+		b.Write(git.PktLine("ofs-delta\n"))
+		b.Write(git.PktLine("no-progress\n"))
+		for _, hash := range wants {
+			b.Write(git.PktLine("want " + hash + "\n"))
+		}
+		b.Write(git.PktLine("done\n"))
+		b.Write(git.FlushPkt)
+
+		return b.Bytes(), true
+	}
+
+	var caps []string
+	for _, name := range []string{"side-band-64k", "side-band", "ofs-delta", "no-progress", "object-format"} {
+		if _, ok := adv.Capability(name); !ok {
+			continue
+		}
+		if strings.HasPrefix(name, "side-band") {
+			if sideband {
+				continue
+			}
+			sideband = true
+		}
+		if name == "object-format" {
+			name += "=" + format.String()
+		}
+		caps = append(caps, name)
+	}
+
+	for i, hash := range wants {
+		line := "want " + hash
+		if i == 0 && len(caps) > 0 {
+			line += " " + strings.Join(caps, " ")
+		}
+		b.Write(git.PktLine(line + "\n"))
+	}
+	b.Write(git.FlushPkt)
+	b.Write(git.PktLine("done\n"))
+
+	return b.Bytes(), sideband
+}
+
+// objectFormatCapability returns the protocol v2 capability line that selects the object format, servers use SHA-1
+// unless the client asks for another format.
+func objectFormatCapability(format git.ObjectFormat) []byte {
+	if format == git.SHA1 {
+		return nil
+	}
+
+	return git.PktLine("object-format=" + format.String() + "\n")
+}
@@ -0,0 +1,354 @@
+package dumper_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/dumper"
+	"github.com/georlav/githunt/internal/git"
+)
+
+// pack encodes the loose objects of a git directory as a packfile without deltas.
+func pack(t *testing.T, dir string) []byte {
+	t.Helper()
+
+	var objects [][]byte
+	err := filepath.WalkDir(filepath.Join(dir, "objects"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := map[git.ObjectType]byte{git.ObjectCommit: 1, git.ObjectTree: 2, git.ObjectBlob: 3, git.ObjectTag: 4}
+
+	var b bytes.Buffer
+	b.WriteString("PACK")
+	_ = binary.Write(&b, binary.BigEndian, uint32(2))
+	_ = binary.Write(&b, binary.BigEndian, uint32(len(objects)))
+
+	for _, loose := range objects {
+		o, err := git.DecodeLoose(loose, git.SHA1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		size := len(o.Data)
+		c := types[o.Type]<<4 | byte(size&0x0f)
+		for size >>= 4; size > 0; size >>= 7 {
+			b.WriteByte(c | 0x80)
+			c = byte(size & 0x7f)
+		}
+		b.WriteByte(c)
+
+		w := zlib.NewWriter(&b)
+		_, _ = w.Write(o.Data)
+		_ = w.Close()
+	}
+
+	sum := sha1.Sum(b.Bytes()) //nolint:gosec
+	b.Write(sum[:])
+
+	return b.Bytes()
+}
+
+// sideband splits data into side-band-64k packets of the data channel.
+func sideband(w io.Writer, data []byte) {
+	for len(data) > 0 {
+		n := min(len(data), 65515)
+		_, _ = w.Write(git.PktLine("\x01" + string(data[:n])))
+		data = data[n:]
+	}
+}
+
+// smartServer is a stand-in for git http-backend serving a single repository at /repo.git. Protocol v2 is
+// spoken when v2 is set and the client asks for it, side-band-64k is advertised in v0 when band is set.
+func smartServer(t *testing.T, refs map[string]string, packfile []byte, v2, band bool) *httptest.Server {
+	t.Helper()
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version2 := v2 && strings.Contains(r.Header.Get("Git-Protocol"), "version=2")
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repo.git/info/refs" && r.URL.Query().Get("service") == "git-upload-pack":
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+
+			if version2 {
+				for _, line := range []string{"version 2\n", "agent=stand-in/1.0\n", "ls-refs=unborn\n", "fetch=shallow\n"} {
+					_, _ = w.Write(git.PktLine(line))
+				}
+				_, _ = w.Write(git.FlushPkt)
+				return
+			}
+
+			caps := "ofs-delta symref=HEAD:refs/heads/main agent=stand-in/1.0"
+			if band {
+				caps = "side-band-64k " + caps
+			}
+
+			_, _ = w.Write(git.PktLine("# service=git-upload-pack\n"))
+			_, _ = w.Write(git.FlushPkt)
+			_, _ = w.Write(git.PktLine(refs["HEAD"] + " HEAD\x00" + caps + "\n"))
+			for _, name := range names {
+				if name != "HEAD" {
+					_, _ = w.Write(git.PktLine(refs[name] + " " + name + "\n"))
+				}
+			}
+			_, _ = w.Write(git.FlushPkt)
+		case r.Method == http.MethodPost && r.URL.Path == "/repo.git/git-upload-pack":
+			if r.Header.Get("Content-Type") != "application/x-git-upload-pack-request" {
+				http.Error(w, "invalid content type", http.StatusUnsupportedMediaType)
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+
+			if version2 && bytes.Contains(body, []byte("command=ls-refs")) {
+				for _, name := range names {
+					line := refs[name] + " " + name
+					if name == "HEAD" {
+						line += " symref-target:refs/heads/main"
+					}
+					_, _ = w.Write(git.PktLine(line + "\n"))
+				}
+				_, _ = w.Write(git.FlushPkt)
+				return
+			}
+
+			if !bytes.Contains(body, []byte("want "+refs["HEAD"])) || !bytes.Contains(body, []byte("done\n")) {
+				http.Error(w, "invalid fetch", http.StatusBadRequest)
+				return
+			}
+
+			switch {
+			case version2:
+				_, _ = w.Write(git.PktLine("packfile\n"))
+				sideband(w, packfile)
+				_, _ = w.Write(git.FlushPkt)
+			case band:
+				_, _ = w.Write(git.PktLine("NAK\n"))
+				sideband(w, packfile)
+				_, _ = w.Write(git.FlushPkt)
+			default:
+				_, _ = w.Write(git.PktLine("NAK\n"))
+				_, _ = w.Write(packfile)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestDumper_DumpSmart(t *testing.T) {
+	r := &repo{t: t, dir: t.TempDir()}
+
+	first := r.commit(map[string]string{"index.php": "<?php echo 1;\n"}, 1700000000)
+	main := r.commit(map[string]string{"index.php": "<?php echo 2;\n", "README": "hi\n"}, 1700000100, first)
+	feature := r.commit(map[string]string{"feature.php": "<?php echo 3;\n"}, 1700000200, first)
+
+	refs := map[string]string{"HEAD": main, "refs/heads/main": main, "refs/heads/feature": feature}
+	packfile := pack(t, r.dir)
+
+	testCases := []struct {
+		name     string
+		v2       bool
+		band     bool
+		protocol string
+	}{
+		{"v2", true, true, "v2"},
+		{"v0 side-band", false, true, "v0"},
+		{"v0 raw pack", false, false, "v0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := smartServer(t, refs, packfile, tc.v2, tc.band)
+
+			target, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetGitPath("/repo.git"), dumper.SetCheckout(true))
+
+			result, err := d.Dump(context.Background(), target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Smart != tc.protocol || result.Head != main || result.Refs["refs/heads/feature"] != feature {
+				t.Fatalf("Unexpected result %s %s %v", result.Smart, result.Head, result.Refs)
+			}
+
+			// 3 commits, 3 trees and 4 blobs
+			if result.Objects != 10 || len(result.Missing) != 0 || result.Files != 2 {
+				t.Fatalf("Expected 10 objects and 2 files got %d %d %v", result.Objects, result.Files, result.Missing)
+			}
+
+			for _, f := range []string{"HEAD", "refs/heads/feature", git.LoosePath(feature)} {
+				if _, err := os.Stat(filepath.Join(result.Dir, ".git", filepath.FromSlash(f))); err != nil {
+					t.Fatalf("Expected %s to be saved. Error: %s", f, err)
+				}
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		ts := smartServer(t, refs, packfile, true, true)
+
+		target, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetGitPath("/repo.git"), dumper.SetSmart(false))
+		if _, err := d.Dump(context.Background(), target); err == nil {
+			t.Fatal("Expected error for target without exposed git directory")
+		}
+	})
+}
+
+func TestDumper_DumpGitHTTPBackend(t *testing.T) {
+	gitBin, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+
+	out, err := exec.Command(gitBin, "--exec-path").Output()
+	if err != nil {
+		t.Skip("git exec path is not available")
+	}
+
+	for _, format := range []git.ObjectFormat{git.SHA1, git.SHA256} {
+		format := format
+		t.Run(format.String(), func(t *testing.T) {
+			root := t.TempDir()
+			r := &repo{t: t, dir: filepath.Join(root, "repo.git"), format: format}
+			if err := exec.Command(gitBin, "init", "-q", "--bare", "--object-format="+format.String(), r.dir).Run(); err != nil {
+				t.Skip("git init failed")
+			}
+
+			main := r.commit(map[string]string{"index.php": "<?php echo 1;\n"}, 1700000000)
+			if err := os.WriteFile(filepath.Join(r.dir, "refs", "heads", "main"), []byte(main+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(r.dir, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			ts := httptest.NewServer(&cgi.Handler{
+				Path:   filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"),
+				Env:    []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1", "GIT_CONFIG_NOSYSTEM=1", "HOME=" + root},
+				Stderr: io.Discard,
+			})
+			t.Cleanup(ts.Close)
+
+			target, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := dumper.NewDumper(client.NewClient(), t.TempDir(), dumper.SetGitPath("/repo.git"), dumper.SetWordlist(nil))
+
+			result, err := d.Dump(context.Background(), target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Smart == "" || result.Head != main || result.Objects != 3 || len(result.Missing) != 0 ||
+				result.ObjectFormat != format.String() {
+				t.Fatalf("Unexpected result %s %s %s %d %v",
+					result.Smart, result.ObjectFormat, result.Head, result.Objects, result.Missing)
+			}
+		})
+	}
+}
+
+func TestSmartChecker_Check(t *testing.T) {
+	refs := map[string]string{"HEAD": strings.Repeat("a", 40), "refs/heads/main": strings.Repeat("a", 40)}
+
+	testCases := []struct {
+		name     string
+		v2       bool
+		protocol string
+	}{
+		{"v0", false, "v0"},
+		{"v2", true, "v2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := smartServer(t, refs, nil, tc.v2, true)
+
+			target, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings, err := dumper.NewSmartChecker(client.NewClient(), "/.git").Check(context.Background(), target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(findings) != 1 {
+				t.Fatalf("Expected 1 finding got %d", len(findings))
+			}
+
+			f := findings[0]
+			if f.Severity != "high" || f.URL.Path != "/repo.git/info/refs" || f.Metadata["protocol"] != tc.protocol ||
+				f.Metadata["ref"] != "refs/heads/main" || f.Metadata["head"] != refs["HEAD"] || f.Metadata["agent"] != "stand-in/1.0" {
+				t.Fatalf("Unexpected finding %s %+v", f.URL, f.Metadata)
+			}
+		})
+	}
+
+	// the dumb protocol serves info/refs as a plain file
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(refs["HEAD"] + "\trefs/heads/main\n"))
+	}))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := dumper.NewSmartChecker(client.NewClient(), "/.git").Check(context.Background(), target)
+	if err != nil || len(findings) != 0 {
+		t.Fatalf("Expected no findings got %+v %v", findings, err)
+	}
+}
@@ -0,0 +1,262 @@
+// Package evidence saves the raw exchanges behind findings so that they can be handed to third parties.
+package evidence
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+)
+
+const manifestName = "evidence.json"
+
+// Writer saves the evidence of each target in its own directory.
+//
+//	<dir>/<host>/evidence.json          findings, timestamps and certificate details
+//	<dir>/<host>/001.request.http       raw request
+//	<dir>/<host>/001.response.http      status line, headers and truncated body
+//	<dir>/<host>/certificates.pem       certificate chain presented by the target
+//	<dir>/<host>/<host>.har             every exchange as a HAR 1.2 log when enabled
+type Writer struct {
+	dir     string
+	har     bool
+	version string
+}
+
+func NewWriter(dir string, options ...Option) *Writer {
+	w := Writer{
+		dir:     dir,
+		version: "dev",
+	}
+
+	for i := range options {
+		options[i](&w)
+	}
+
+	return &w
+}
+
+// Manifest describes the saved evidence of a target.
+type Manifest struct {
+	Target    string     `json:"target"`
+	Captured  time.Time  `json:"captured"`
+	Findings  []Finding  `json:"findings"`
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Finding refers to the exchanges of a finding by id.
+type Finding struct {
+	Checker   string            `json:"checker"`
+	Severity  string            `json:"severity,omitempty"`
+	URL       string            `json:"url"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Exchanges []int             `json:"exchanges"`
+}
+
+// Exchange describes a saved request and response.
+type Exchange struct {
+	ID         int        `json:"id"`
+	Method     string     `json:"method"`
+	URL        string     `json:"url"`
+	Status     int        `json:"status"`
+	RemoteAddr string     `json:"remote_addr,omitempty"`
+	Request    string     `json:"request"`
+	Response   string     `json:"response"`
+	BodySize   int        `json:"body_size"`
+	Truncated  bool       `json:"truncated"`
+	Started    time.Time  `json:"started"`
+	Connected  *time.Time `json:"connected,omitempty"`
+	FirstByte  *time.Time `json:"first_byte,omitempty"`
+	Finished   time.Time  `json:"finished"`
+	TLS        *TLS       `json:"tls,omitempty"`
+}
+
+// TLS describes the connection and certificate chain of an exchange.
+type TLS struct {
+	Version      string        `json:"version"`
+	CipherSuite  string        `json:"cipher_suite"`
+	ServerName   string        `json:"server_name,omitempty"`
+	Certificates []Certificate `json:"certificates"`
+}
+
+// Certificate holds the identifying fields of a certificate.
+type Certificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	Serial    string    `json:"serial"`
+	DNSNames  []string  `json:"dns_names,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	SHA256    string    `json:"sha256"`
+}
+
+// Save writes the evidence of the findings of target and returns its directory. Evidence of a previous run for
+// the same target is replaced.
+func (w *Writer) Save(target *url.URL, findings []checker.Finding, now time.Time) (string, error) {
+	dir := filepath.Join(w.dir, checker.TargetDir(target))
+
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("removing evidence directory %s. Error: %w", dir, err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating evidence directory %s. Error: %w", dir, err)
+	}
+
+	manifest := Manifest{
+		Target:   target.String(),
+		Captured: now.UTC(),
+	}
+
+	// findings of one target can share exchanges, each is saved once
+	var (
+		ids       = make(map[*client.Exchange]int)
+		exchanges []*client.Exchange
+	)
+
+	for _, f := range findings {
+		mf := Finding{
+			Checker:   f.Checker,
+			Severity:  f.Severity,
+			Metadata:  f.Metadata,
+			Exchanges: []int{},
+		}
+		if f.URL != nil {
+			mf.URL = f.URL.String()
+		}
+
+		for _, e := range f.Evidence {
+			id, ok := ids[e]
+			if !ok {
+				exchanges = append(exchanges, e)
+				id = len(exchanges)
+				ids[e] = id
+			}
+			mf.Exchanges = append(mf.Exchanges, id)
+		}
+
+		manifest.Findings = append(manifest.Findings, mf)
+	}
+
+	var chain []byte
+	for i, e := range exchanges {
+		me, err := w.saveExchange(dir, i+1, e)
+		if err != nil {
+			return "", err
+		}
+		manifest.Exchanges = append(manifest.Exchanges, me)
+
+		if chain == nil && e.TLS != nil {
+			for _, c := range e.TLS.Certificates {
+				chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+			}
+		}
+	}
+
+	if chain != nil {
+		if err := writeFile(dir, "certificates.pem", chain); err != nil {
+			return "", err
+		}
+	}
+
+	if w.har && len(exchanges) > 0 {
+		b, err := json.MarshalIndent(newHAR(exchanges, w.version), "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("encoding har. Error: %w", err)
+		}
+		if err := writeFile(dir, checker.TargetDir(target)+".har", b); err != nil {
+			return "", err
+		}
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding evidence manifest. Error: %w", err)
+	}
+
+	return dir, writeFile(dir, manifestName, b)
+}
+
+func (w *Writer) saveExchange(dir string, id int, e *client.Exchange) (Exchange, error) {
+	me := Exchange{
+		ID:         id,
+		Method:     e.Method,
+		URL:        e.URL,
+		Status:     e.StatusCode,
+		RemoteAddr: e.RemoteAddr,
+		Request:    fmt.Sprintf("%03d.request.http", id),
+		Response:   fmt.Sprintf("%03d.response.http", id),
+		BodySize:   e.BodySize,
+		Truncated:  e.Truncated,
+		Started:    e.Started.UTC(),
+		Connected:  optional(e.Connected),
+		FirstByte:  optional(e.FirstByte),
+		Finished:   e.Finished.UTC(),
+	}
+
+	if e.TLS != nil {
+		me.TLS = &TLS{
+			Version:      e.TLS.Version,
+			CipherSuite:  e.TLS.CipherSuite,
+			ServerName:   e.TLS.ServerName,
+			Certificates: []Certificate{},
+		}
+		for _, c := range e.TLS.Certificates {
+			sum := sha256.Sum256(c.Raw)
+			me.TLS.Certificates = append(me.TLS.Certificates, Certificate{
+				Subject:   c.Subject.String(),
+				Issuer:    c.Issuer.String(),
+				Serial:    c.SerialNumber.String(),
+				DNSNames:  c.DNSNames,
+				NotBefore: c.NotBefore.UTC(),
+				NotAfter:  c.NotAfter.UTC(),
+				SHA256:    hex.EncodeToString(sum[:]),
+			})
+		}
+	}
+
+	if err := writeFile(dir, me.Request, e.Request); err != nil {
+		return me, err
+	}
+
+	return me, writeFile(dir, me.Response, rawResponse(e))
+}
+
+// rawResponse renders the status line, the headers and the captured body of an exchange.
+func rawResponse(e *client.Exchange) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s\r\n", e.Proto, e.Status)
+	_ = e.Header.Write(&b)
+	b.WriteString("\r\n")
+	b.Write(e.Body)
+
+	return []byte(b.String())
+}
+
+// optional returns nil for the zero time.
+func optional(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+
+	return &t
+}
+
+func writeFile(dir, name string, b []byte) error {
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, b, 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("writing evidence file %s. Error: %w", p, err)
+	}
+
+	return nil
+}
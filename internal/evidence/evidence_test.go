@@ -0,0 +1,182 @@
+package evidence_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/evidence"
+)
+
+func TestWriter_Save(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.git/HEAD":
+			_, _ = w.Write([]byte("ref: refs/heads/master\n"))
+		default:
+			_, _ = w.Write([]byte("[core]\n\trepositoryformatversion = 0\n\xff\xfe"))
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.NewClient(client.SetEvidence(1024))
+
+	var findings []checker.Finding
+	for _, path := range []string{"/.git/config", "/.git/HEAD"} {
+		u := checker.JoinPath(target, path)
+
+		resp, err := c.Do(context.Background(), http.MethodGet, u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		findings = append(findings, checker.Finding{
+			Checker:  strings.TrimPrefix(path, "/.git/"),
+			Severity: "medium",
+			URL:      u,
+			Evidence: []*client.Exchange{resp.Exchange},
+		})
+	}
+
+	// findings sharing an exchange save it once
+	findings = append(findings, checker.Finding{Checker: "shared", URL: target, Evidence: findings[0].Evidence})
+
+	w := evidence.NewWriter(t.TempDir(), evidence.SetHAR(true), evidence.SetVersion("v1.0.0"))
+
+	// a previous run is replaced
+	if _, err := w.Save(target, findings[:1], time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := w.Save(target, findings, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest evidence.Manifest
+	readJSON(t, filepath.Join(dir, "evidence.json"), &manifest)
+
+	if manifest.Target != ts.URL || len(manifest.Findings) != 3 || len(manifest.Exchanges) != 2 {
+		t.Fatalf("Unexpected manifest %+v", manifest)
+	}
+	if ids := manifest.Findings[2].Exchanges; len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("Expected the shared finding to refer to exchange 1 got %v", ids)
+	}
+
+	e := manifest.Exchanges[1]
+	if e.Status != http.StatusOK || e.TLS == nil || len(e.TLS.Certificates) == 0 || e.TLS.Certificates[0].SHA256 == "" {
+		t.Fatalf("Unexpected exchange %+v", e)
+	}
+
+	request, err := os.ReadFile(filepath.Join(dir, e.Request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(request), "GET /.git/HEAD HTTP/1.1\r\n") {
+		t.Fatalf("Unexpected request %q", request)
+	}
+
+	response, err := os.ReadFile(filepath.Join(dir, e.Response))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(response), "HTTP/1.1 200 OK\r\n") || !strings.HasSuffix(string(response), "\r\n\r\nref: refs/heads/master\n") {
+		t.Fatalf("Unexpected response %q", response)
+	}
+
+	chain, err := os.ReadFile(filepath.Join(dir, "certificates.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(chain)
+	if block == nil {
+		t.Fatal("Expected a PEM certificate")
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		t.Fatal(err)
+	}
+
+	var har struct {
+		Log struct {
+			Version string `json:"version"`
+			Creator struct {
+				Version string `json:"version"`
+			} `json:"creator"`
+			Entries []struct {
+				Request struct {
+					URL     string `json:"url"`
+					Headers []struct {
+						Name string `json:"name"`
+					} `json:"headers"`
+				} `json:"request"`
+				Response struct {
+					Status  int `json:"status"`
+					Content struct {
+						Encoding string `json:"encoding"`
+					} `json:"content"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	readJSON(t, filepath.Join(dir, filepath.Base(dir)+".har"), &har)
+
+	if har.Log.Version != "1.2" || har.Log.Creator.Version != "v1.0.0" || len(har.Log.Entries) != 2 {
+		t.Fatalf("Unexpected har %+v", har)
+	}
+	if entry := har.Log.Entries[0]; entry.Response.Content.Encoding != "base64" || len(entry.Request.Headers) == 0 {
+		t.Fatalf("Expected a base64 encoded binary body and request headers got %+v", entry)
+	}
+}
+
+func TestWriter_SaveTargets(t *testing.T) {
+	w := evidence.NewWriter(t.TempDir())
+
+	var dirs []string
+	for _, raw := range []string{"http://example.com/app1", "http://example.com/app2", "https://example.com/app1"} {
+		target, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dir, err := w.Save(target, []checker.Finding{{Checker: "config", URL: target}}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+	}
+
+	// saving a target does not replace the evidence of the others on the same host
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, "evidence.json")); err != nil {
+			t.Fatalf("Expected the evidence of %s to be kept. Error: %s", dir, err)
+		}
+	}
+}
+
+func readJSON(t *testing.T, path string, v any) {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,204 @@
+package evidence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/georlav/githunt/internal/client"
+)
+
+// HAR 1.2 log, see http://www.softwareishard.com/blog/har-12-spec/
+type har struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+}
+
+type harRequest struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []struct{}  `json:"cookies"`
+	Headers     []harHeader `json:"headers"`
+	QueryString []harHeader `json:"queryString"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []struct{}  `json:"cookies"`
+	Headers     []harHeader `json:"headers"`
+	Content     harContent  `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+	Comment     string      `json:"comment,omitempty"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHAR(exchanges []*client.Exchange, version string) har {
+	h := har{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "githunt", Version: version},
+		Entries: make([]harEntry, 0, len(exchanges)),
+	}}
+
+	for _, e := range exchanges {
+		h.Log.Entries = append(h.Log.Entries, newEntry(e))
+	}
+
+	return h
+}
+
+func newEntry(e *client.Exchange) harEntry {
+	entry := harEntry{
+		StartedDateTime: e.Started.UTC(),
+		Time:            milliseconds(e.Finished.Sub(e.Started)),
+		Request: harRequest{
+			Method:      e.Method,
+			URL:         e.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []struct{}{},
+			Headers:     requestHeaders(e.Request),
+			QueryString: []harHeader{},
+			HeadersSize: len(e.Request),
+		},
+		Response: harResponse{
+			Status:      e.StatusCode,
+			StatusText:  statusText(e.Status),
+			HTTPVersion: e.Proto,
+			Cookies:     []struct{}{},
+			Headers:     []harHeader{},
+			Content:     content(e),
+			RedirectURL: e.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    e.BodySize,
+		},
+		Timings: harTimings{Connect: -1, Send: 0, Wait: -1, Receive: -1},
+	}
+
+	if u, err := url.Parse(e.URL); err == nil {
+		for k, values := range u.Query() {
+			for _, v := range values {
+				entry.Request.QueryString = append(entry.Request.QueryString, harHeader{Name: k, Value: v})
+			}
+		}
+	}
+
+	for k, values := range e.Header {
+		for _, v := range values {
+			entry.Response.Headers = append(entry.Response.Headers, harHeader{Name: k, Value: v})
+		}
+	}
+
+	if e.Truncated {
+		entry.Response.Comment = "body truncated"
+	}
+
+	start := e.Started
+	if !e.Connected.IsZero() {
+		start = e.Connected
+		entry.Timings.Connect = milliseconds(e.Connected.Sub(e.Started))
+	}
+	if !e.FirstByte.IsZero() {
+		entry.Timings.Wait = milliseconds(e.FirstByte.Sub(start))
+		entry.Timings.Receive = milliseconds(e.Finished.Sub(e.FirstByte))
+	}
+
+	if host, _, err := net.SplitHostPort(e.RemoteAddr); err == nil {
+		entry.ServerIPAddress = host
+	}
+
+	return entry
+}
+
+// requestHeaders parses the header fields of a raw request.
+func requestHeaders(raw []byte) []harHeader {
+	headers := []harHeader{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Scan() // request line
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		headers = append(headers, harHeader{Name: name, Value: strings.TrimSpace(value)})
+	}
+
+	return headers
+}
+
+// content holds the captured body, bodies that are not valid UTF-8 are base64 encoded.
+func content(e *client.Exchange) harContent {
+	c := harContent{
+		Size:     e.BodySize,
+		MimeType: e.Header.Get("Content-Type"),
+	}
+
+	if utf8.Valid(e.Body) {
+		c.Text = string(e.Body)
+	} else {
+		c.Text = base64.StdEncoding.EncodeToString(e.Body)
+		c.Encoding = "base64"
+	}
+
+	return c
+}
+
+// statusText returns the reason phrase of a status such as 200 OK.
+func statusText(status string) string {
+	_, text, _ := strings.Cut(status, " ")
+
+	return text
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,19 @@
+package evidence
+
+type Option func(*Writer)
+
+// SetHAR bundle the exchanges of each target in a HAR file.
+func SetHAR(har bool) Option {
+	return func(args *Writer) {
+		args.har = har
+	}
+}
+
+// SetVersion sets the tool version recorded as the creator of HAR files.
+func SetVersion(version string) Option {
+	return func(args *Writer) {
+		if version != "" {
+			args.version = version
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// ObjectFormat is the hash algorithm of a repository, repositories created with --object-format=sha256 name
+// their objects by SHA-256 and store longer ids in trees, indexes, packs and refs.
+type ObjectFormat int
+
+const (
+	SHA1 ObjectFormat = iota
+	SHA256
+)
+
+func (f ObjectFormat) String() string {
+	if f == SHA256 {
+		return "sha256"
+	}
+
+	return "sha1"
+}
+
+// ParseObjectFormat converts the value of extensions.objectFormat to its format, an empty value is SHA-1.
+func ParseObjectFormat(s string) (ObjectFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "sha1":
+		return SHA1, nil
+	case "sha256":
+		return SHA256, nil
+	}
+
+	return SHA1, fmt.Errorf("unsupported object format %q", truncate(s, 64))
+}
+
+// Size returns the length in bytes of a raw object id.
+func (f ObjectFormat) Size() int {
+	if f == SHA256 {
+		return sha256.Size
+	}
+
+	return sha1.Size
+}
+
+// HexSize returns the length of a hex encoded object id.
+func (f ObjectFormat) HexSize() int {
+	return hex.EncodedLen(f.Size())
+}
+
+// New returns the hash function of the format.
+func (f ObjectFormat) New() hash.Hash {
+	if f == SHA256 {
+		return sha256.New()
+	}
+
+	return sha1.New() //nolint:gosec
+}
+
+// IsHash reports whether s is a hex encoded object id of the format.
+func (f ObjectFormat) IsHash(s string) bool {
+	return len(s) == f.HexSize() && IsHash(s)
+}
+
+// Checksum reports whether b ends with the checksum of its content, as pack, pack index and index files do.
+func (f ObjectFormat) Checksum(b []byte) bool {
+	if len(b) < f.Size() {
+		return false
+	}
+
+	h := f.New()
+	_, _ = h.Write(b[:len(b)-f.Size()])
+
+	return bytes.Equal(h.Sum(nil), b[len(b)-f.Size():])
+}
+
+// HashFormat returns the format of a hex encoded object id by its length.
+func HashFormat(hash string) ObjectFormat {
+	if len(hash) == SHA256.HexSize() {
+		return SHA256
+	}
+
+	return SHA1
+}
+
+// ConfigObjectFormat reads extensions.objectFormat from a git config file. Section and key names are case
+// insensitive, the format of configs without the extension is SHA-1.
+func ConfigObjectFormat(b []byte) (ObjectFormat, error) {
+	var section string
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			name, _, _ := strings.Cut(strings.Trim(line, "[]"), " ")
+			section = strings.ToLower(strings.TrimSpace(name))
+		case section == "extensions":
+			key, value, _ := strings.Cut(line, "=")
+			if strings.EqualFold(strings.TrimSpace(key), "objectformat") {
+				value, _, _ = strings.Cut(value, "#")
+				value, _, _ = strings.Cut(value, ";")
+				return ParseObjectFormat(strings.Trim(strings.TrimSpace(value), `"`))
+			}
+		}
+	}
+
+	return SHA1, nil
+}
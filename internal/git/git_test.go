@@ -0,0 +1,540 @@
+package git_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/georlav/githunt/internal/git"
+)
+
+func TestLoose(t *testing.T) {
+	o := &git.Object{Type: git.ObjectBlob, Data: []byte("hi\n")}
+
+	// git hash-object for "hi\n"
+	if o.Hash() != "45b983be36b73c0788dc9cbcb76cbb80fc7bb057" {
+		t.Fatalf("Unexpected hash %s", o.Hash())
+	}
+
+	b, err := git.EncodeLoose(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := git.DecodeLoose(b, git.SHA1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Type != o.Type || string(decoded.Data) != string(o.Data) {
+		t.Fatal("Unexpected decoded object")
+	}
+
+	if _, err := git.DecodeLoose([]byte("<html>not found</html>"), git.SHA1, 0); err == nil {
+		t.Fatal("Expected error for invalid object")
+	}
+}
+
+func TestParseIndex(t *testing.T) {
+	b, err := os.ReadFile("testdata/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := git.ParseIndex(b, git.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		".env":      "65ec2679eeaac690801f2a00b7de9baebbef7a2d",
+		"big.txt":   "ff1fbbc4b0cd232cfdb093a8b6620798aae4acfa",
+		"c.txt":     "587be6b4c3f93f93c489c0111bba5596147a26cb",
+		"d.txt":     "975fbec8256d3e8a3797e7a3611380f27c49f4ac",
+		"sub/a.txt": "45b983be36b73c0788dc9cbcb76cbb80fc7bb057",
+	}
+
+	if len(idx.Entries) != len(expected) {
+		t.Fatalf("Expected %d entries got %d", len(expected), len(idx.Entries))
+	}
+
+	for _, e := range idx.Entries {
+		if expected[e.Path] != e.Hash {
+			t.Fatalf("Unexpected entry %s %s", e.Path, e.Hash)
+		}
+	}
+
+	if _, err := git.ParseIndex(b[:40], git.SHA1); err == nil {
+		t.Fatal("Expected error for truncated index")
+	}
+}
+
+func TestParsePack(t *testing.T) {
+	b, err := os.ReadFile("testdata/test.pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := git.ParsePack(b, git.SHA1, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(objects) != 13 {
+		t.Fatalf("Expected 13 objects got %d", len(objects))
+	}
+
+	byHash := make(map[string]*git.Object)
+	for _, o := range objects {
+		byHash[o.Hash()] = o
+	}
+
+	// resolved from an offset delta against big.txt
+	if o := byHash["b66acce0f48429f3cf2cf20827bbd058ba44ddfb"]; o == nil || o.Type != git.ObjectBlob {
+		t.Fatal("Expected delta object to be resolved")
+	}
+
+	c := byHash["7f322182cad6122deef5201dd52db08baa427799"]
+	if c == nil {
+		t.Fatal("Expected commit")
+	}
+
+	commit, err := git.ParseCommit(c.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(commit.Parents) != 1 || commit.Parents[0] != "812cc648cb12f6372d896559a0748a30fc0c2160" || commit.Author.Email != "a@b.c" {
+		t.Fatalf("Unexpected commit %+v", commit)
+	}
+
+	tree := byHash[commit.Tree]
+	if tree == nil {
+		t.Fatal("Expected tree")
+	}
+
+	entries, err := git.ParseTree(tree.Data, tree.Format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := ""
+	for _, e := range entries {
+		names += e.Name + " "
+	}
+	if names != ".env big.txt c.txt sub " || !entries[3].IsTree() {
+		t.Fatalf("Unexpected tree entries %s", names)
+	}
+
+	tag, err := git.ParseTag(byHash["d86c6955e50c1cf3e1c1ee38bd9c01ab1f1d5263"].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tag.Name != "v1" || tag.Object != "812cc648cb12f6372d896559a0748a30fc0c2160" {
+		t.Fatalf("Unexpected tag %+v", tag)
+	}
+}
+
+func TestParseRefs(t *testing.T) {
+	ref, hash, err := git.ParseHead([]byte("ref: refs/heads/main\n"))
+	if err != nil || ref != "refs/heads/main" || hash != "" {
+		t.Fatalf("Unexpected head %s %s %v", ref, hash, err)
+	}
+
+	if _, _, err := git.ParseHead([]byte("<html>")); err == nil {
+		t.Fatal("Expected error for invalid head")
+	}
+
+	refs := git.ParsePackedRefs([]byte("# pack-refs with: peeled\n" +
+		"7f322182cad6122deef5201dd52db08baa427799 refs/heads/main\n" +
+		"d86c6955e50c1cf3e1c1ee38bd9c01ab1f1d5263 refs/tags/v1\n" +
+		"^812cc648cb12f6372d896559a0748a30fc0c2160\n"))
+
+	if refs["refs/heads/main"] != "7f322182cad6122deef5201dd52db08baa427799" ||
+		refs["refs/tags/v1^{}"] != "812cc648cb12f6372d896559a0748a30fc0c2160" {
+		t.Fatalf("Unexpected refs %v", refs)
+	}
+
+	packs := git.ParsePacks([]byte("P pack-b9467246402c25d5d16925dde7a9374f877bd754.pack\n\n"))
+	if len(packs) != 1 || packs[0] != "pack-b9467246402c25d5d16925dde7a9374f877bd754" {
+		t.Fatalf("Unexpected packs %v", packs)
+	}
+
+	entries := git.ParseReflog([]byte("0000000000000000000000000000000000000000 00010db3bbb4a0ec9be7a2d4e7a7e0ab1e9e2a61 " +
+		"Dev <dev@example.com> 1700000000 +0200\tcommit (initial): one\n" +
+		"00010db3bbb4a0ec9be7a2d4e7a7e0ab1e9e2a61 812cc648cb12f6372d896559a0748a30fc0c2160 " +
+		"Dev <dev@example.com> 1700000100 +0200\tcommit (amend): two\n" +
+		"garbage\n"))
+	if len(entries) != 2 || !git.IsZeroHash(entries[0].Old) || entries[1].Message != "commit (amend): two" ||
+		entries[1].Committer.Email != "dev@example.com" || entries[1].Committer.When.Unix() != 1700000100 {
+		t.Fatalf("Unexpected reflog %+v", entries)
+	}
+
+	hashes := git.ParseHashes([]byte("812cc648cb12f6372d896559a0748a30fc0c2160\t\tbranch 'main' of https://example.com/repo\n" +
+		"7f322182cad6122deef5201dd52db08baa427799\tnot-for-merge\tbranch 'dev' of https://example.com/repo\n" +
+		"0000000000000000000000000000000000000000\n"))
+	if len(hashes) != 2 || hashes[1] != "7f322182cad6122deef5201dd52db08baa427799" {
+		t.Fatalf("Unexpected hashes %v", hashes)
+	}
+}
+
+// pkts encodes lines as pkt-lines, an empty line is a flush packet.
+func pkts(lines ...string) []byte {
+	var b []byte
+	for _, line := range lines {
+		if line == "" {
+			b = append(b, git.FlushPkt...)
+			continue
+		}
+		b = append(b, git.PktLine(line)...)
+	}
+
+	return b
+}
+
+func TestParseAdvertisement(t *testing.T) {
+	a, b := strings.Repeat("a", 40), strings.Repeat("b", 40)
+
+	adv, err := git.ParseAdvertisement(pkts(
+		"# service=git-upload-pack\n", "",
+		a+" HEAD\x00multi_ack side-band-64k symref=HEAD:refs/heads/main agent=git/2.39\n",
+		a+" refs/heads/main\n",
+		b+" refs/tags/v1\n",
+		a+" refs/tags/v1^{}\n",
+		"",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if adv.Version != 0 || adv.Head != "refs/heads/main" || len(adv.Refs) != 4 || adv.Refs["refs/tags/v1"] != b {
+		t.Fatalf("Unexpected advertisement %+v", adv)
+	}
+
+	if agent, ok := adv.Capability("agent"); !ok || agent != "git/2.39" {
+		t.Fatalf("Unexpected agent %s", agent)
+	}
+
+	adv, err = git.ParseAdvertisement(pkts("version 2\n", "agent=git/2.39\n", "ls-refs=unborn\n", "fetch=shallow\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := adv.Capability("fetch"); adv.Version != 2 || !ok || len(adv.Refs) != 0 {
+		t.Fatalf("Unexpected advertisement %+v", adv)
+	}
+
+	// empty repositories advertise the capabilities on a null ref
+	adv, err = git.ParseAdvertisement(pkts("# service=git-upload-pack\n", "", strings.Repeat("0", 40)+" capabilities^{}\x00ofs-delta\n", ""))
+	if err != nil || len(adv.Refs) != 0 {
+		t.Fatalf("Unexpected advertisement %+v %v", adv, err)
+	}
+
+	for _, invalid := range [][]byte{
+		[]byte(a + "\trefs/heads/main\n"),
+		[]byte("<html>Not Found</html>"),
+		pkts("# service=git-receive-pack\n", ""),
+		pkts("# service=git-upload-pack\n", "", "not a ref\n", ""),
+		pkts("# service=git-upload-pack\n", "", a+" HEAD\x00side-band\n"),
+	} {
+		if _, err := git.ParseAdvertisement(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParseLsRefs(t *testing.T) {
+	a, b := strings.Repeat("a", 40), strings.Repeat("b", 40)
+
+	refs, head, err := git.ParseLsRefs(pkts(
+		a+" HEAD symref-target:refs/heads/main\n",
+		a+" refs/heads/main\n",
+		b+" refs/tags/v1 peeled:"+a+"\n",
+		"unborn refs/heads/empty\n",
+		"",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if head != "refs/heads/main" || len(refs) != 4 || refs["refs/tags/v1^{}"] != a {
+		t.Fatalf("Unexpected refs %s %v", head, refs)
+	}
+}
+
+func TestReadUploadPack(t *testing.T) {
+	pack := "PACK\x00\x00\x00\x02\x00\x00\x00\x00"
+
+	testCases := []struct {
+		name     string
+		body     []byte
+		version  int
+		sideband bool
+		err      bool
+	}{
+		{"v0 raw", append(pkts("NAK\n"), pack...), 0, false, false},
+		{"v0 side-band", pkts("NAK\n", "\x02Counting objects\n", "\x01"+pack[:6], "\x01"+pack[6:], ""), 0, true, false},
+		{"v2", pkts("packfile\n", "\x02progress\n", "\x01"+pack, ""), 2, true, false},
+		{"remote error", pkts("NAK\n", "\x03upload-pack: not our ref\n", ""), 0, true, true},
+		{"ERR line", pkts("ERR access denied\n"), 0, true, true},
+		{"missing pack section", pkts("acknowledgments\n", "NAK\n", ""), 2, true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := git.ReadUploadPack(tc.body, tc.version, tc.sideband)
+			if tc.err {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+
+			if err != nil || string(b) != pack {
+				t.Fatalf("Unexpected pack %q %v", b, err)
+			}
+		})
+	}
+}
+
+func TestObjectFormat(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config string
+		format git.ObjectFormat
+		err    bool
+	}{
+		{"no extension", "[core]\n\trepositoryformatversion = 0\n", git.SHA1, false},
+		{"sha256", "[core]\n\trepositoryformatversion = 1\n[extensions]\n\tobjectformat = sha256\n", git.SHA256, false},
+		{"case insensitive", "[Extensions]\n\tobjectFormat = SHA256 ; comment\n", git.SHA256, false},
+		{"other section", "[remote \"origin\"]\n\tobjectformat = sha256\n", git.SHA1, false},
+		{"unsupported", "[extensions]\n\tobjectformat = blake3\n", git.SHA1, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			format, err := git.ConfigObjectFormat([]byte(tc.config))
+			if (err != nil) != tc.err || format != tc.format {
+				t.Fatalf("Expected %s got %s %v", tc.format, format, err)
+			}
+		})
+	}
+
+	// git hash-object --object-format=sha256 for "hi\n"
+	o := &git.Object{Type: git.ObjectBlob, Data: []byte("hi\n"), Format: git.SHA256}
+	if o.Hash() != "96c18f0297e38d01f4b2dacddea4259aea6b2961eb0822bd2c0c3f6029030045" {
+		t.Fatalf("Unexpected hash %s", o.Hash())
+	}
+
+	if !git.IsHash(o.Hash()) || !git.SHA256.IsHash(o.Hash()) || git.SHA1.IsHash(o.Hash()) ||
+		git.HashFormat(o.Hash()) != git.SHA256 {
+		t.Fatal("Unexpected SHA-256 id validation")
+	}
+
+	adv, err := git.ParseAdvertisement(pkts("# service=git-upload-pack\n", "", "version 2\n", "object-format=sha256\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format, err := adv.ObjectFormat(); err != nil || format != git.SHA256 {
+		t.Fatalf("Expected sha256 advertisement got %s %v", format, err)
+	}
+}
+
+func TestParseIndex_SHA256(t *testing.T) {
+	b, err := os.ReadFile("testdata/index-sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := git.ParseIndex(b, git.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"big.txt":   "a27c3c4a6194b0ebb6d3b453df6f0ed4239939ee48f4aee0b4ab3568ca871ec2",
+		"sub/a.txt": "96c18f0297e38d01f4b2dacddea4259aea6b2961eb0822bd2c0c3f6029030045",
+	}
+
+	if len(idx.Entries) != len(expected) || len(idx.Checksum) != 64 || !git.SHA256.Checksum(b) {
+		t.Fatalf("Unexpected index %+v", idx)
+	}
+
+	for _, e := range idx.Entries {
+		if expected[e.Path] != e.Hash {
+			t.Fatalf("Unexpected entry %s %s", e.Path, e.Hash)
+		}
+	}
+}
+
+func TestParsePack_SHA256(t *testing.T) {
+	b, err := os.ReadFile("testdata/sha256.pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !git.SHA256.Checksum(b) || git.SHA1.Checksum(b) {
+		t.Fatal("Expected a SHA-256 pack checksum")
+	}
+
+	objects, err := git.ParsePack(b, git.SHA256, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byHash := make(map[string]*git.Object)
+	for _, o := range objects {
+		if o.Format != git.SHA256 {
+			t.Fatalf("Unexpected format %s", o.Format)
+		}
+		byHash[o.Hash()] = o
+	}
+
+	if len(byHash) != 8 {
+		t.Fatalf("Expected 8 objects got %d", len(byHash))
+	}
+
+	// resolved from an offset delta against the first big.txt
+	if o := byHash["c5a21ed25d980604b4e4a4db1fbc49ce4d01445d5d1257bcdb068270af40b9fe"]; o == nil || o.Type != git.ObjectBlob {
+		t.Fatal("Expected delta object to be resolved")
+	}
+
+	c := byHash["29ef41786b5736d3a326b1441ab5ef958702d5a37b8d5d51b4eecddc3f356e76"]
+	if c == nil {
+		t.Fatal("Expected commit")
+	}
+
+	commit, err := git.ParseCommit(c.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(commit.Parents) != 1 || commit.Parents[0] != "b3af79f848284eb6fc854e10be1c15a264be4e6cc22477422f9ab1ca8c11593a" ||
+		commit.Tree != "c72cff769da6ab16a19579301e328c199982c0eb122e99cbd7a01547d6799c2c" {
+		t.Fatalf("Unexpected commit %+v", commit)
+	}
+
+	entries, err := git.ParseTree(byHash[commit.Tree].Data, git.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || entries[0].Name != "big.txt" || entries[1].Name != "sub" || !entries[1].IsTree() ||
+		entries[0].Hash != "a27c3c4a6194b0ebb6d3b453df6f0ed4239939ee48f4aee0b4ab3568ca871ec2" {
+		t.Fatalf("Unexpected tree entries %+v", entries)
+	}
+
+	refs, err := git.References(byHash[commit.Tree])
+	if err != nil || len(refs) != 2 || refs[1] != entries[1].Hash {
+		t.Fatalf("Unexpected references %v %v", refs, err)
+	}
+}
+
+// packOf builds a SHA-1 pack from a header count and raw entries.
+func packOf(count uint32, entries ...[]byte) []byte {
+	b := []byte("PACK\x00\x00\x00\x02")
+	b = binary.BigEndian.AppendUint32(b, count)
+	for _, e := range entries {
+		b = append(b, e...)
+	}
+	sum := sha1.Sum(b) //nolint:gosec
+
+	return append(b, sum[:]...)
+}
+
+// packEntry encodes an entry header declaring size followed by the compressed data.
+func packEntry(typ byte, size int, prefix, data []byte) []byte {
+	c := typ<<4 | byte(size&0x0f)
+	size >>= 4
+	var b []byte
+	for size > 0 {
+		b = append(b, c|0x80)
+		c = byte(size & 0x7f)
+		size >>= 7
+	}
+	b = append(b, c)
+	b = append(b, prefix...)
+
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+
+	return append(b, z.Bytes()...)
+}
+
+func TestParsePack_Limits(t *testing.T) {
+	blob := []byte("hello\n")
+	base := (&git.Object{Type: git.ObjectBlob, Data: blob}).Hash()
+	raw, _ := hex.DecodeString(base)
+
+	// a delta of the 6 byte base declaring a 1GiB target
+	delta := []byte{6, 0x80, 0x80, 0x80, 0x80, 0x04, 0x01, 'x'}
+
+	testCases := []struct {
+		name string
+		pack []byte
+	}{
+		{"huge object count", packOf(0xffffffff)},
+		{"declared size over the limit", packOf(1, packEntry(3, 2<<20, nil, blob))},
+		{"inflates past the declared size", packOf(1, packEntry(3, 2, nil, bytes.Repeat([]byte("a"), 1<<20)))},
+		{"delta target over the limit", packOf(2, packEntry(3, len(blob), nil, blob), packEntry(7, len(delta), raw, delta))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := git.ParsePack(tc.pack, git.SHA1, 1<<20, nil); err == nil {
+				t.Fatal("Expected error")
+			}
+		})
+	}
+
+	// the same entries within the limit
+	objects, err := git.ParsePack(packOf(1, packEntry(3, len(blob), nil, blob)), git.SHA1, 1<<20, nil)
+	if err != nil || len(objects) != 1 || objects[0].Hash() != base {
+		t.Fatalf("Unexpected objects %v %v", objects, err)
+	}
+}
+
+func TestDecodeLoose_Limits(t *testing.T) {
+	loose := func(header string, data []byte) []byte {
+		var b bytes.Buffer
+		zw := zlib.NewWriter(&b)
+		_, _ = zw.Write(append([]byte(header+"\x00"), data...))
+		_ = zw.Close()
+		return b.Bytes()
+	}
+
+	// a megabyte of zeros compresses to about a kilobyte
+	zeros := make([]byte, 1<<20)
+
+	testCases := []struct {
+		name  string
+		loose []byte
+	}{
+		{"declared size over the limit", loose("blob 1048576", zeros)},
+		{"inflates past the declared size", loose("blob 6", zeros)},
+		{"inflates short of the declared size", loose("blob 1024", []byte("hello\n"))},
+		{"negative size", loose("blob -1", nil)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := git.DecodeLoose(tc.loose, git.SHA1, 64<<10); err == nil {
+				t.Fatal("Expected error")
+			}
+		})
+	}
+
+	// the same object within the limit
+	o, err := git.DecodeLoose(loose("blob 1048576", zeros), git.SHA1, 1<<20)
+	if err != nil || len(o.Data) != len(zeros) {
+		t.Fatalf("Unexpected object %v", err)
+	}
+}
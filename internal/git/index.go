@@ -0,0 +1,136 @@
+package git
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Index is a parsed .git/index file.
+type Index struct {
+	Version  uint32
+	Entries  []IndexEntry
+	Checksum string
+}
+
+type IndexEntry struct {
+	Path    string
+	Hash    string
+	Mode    uint32
+	Size    uint32
+	ModTime time.Time
+}
+
+const (
+	indexHeaderSize   = 12
+	indexEntryFixed   = 40
+	indexFlagExtended = 0x4000
+	indexNameMask     = 0x0fff
+)
+
+// ParseIndex decodes an index file, versions 2, 3 and 4 are supported. Object ids and the trailing checksum have
+// the length of the repository format.
+//
+//nolint:cyclop
+func ParseIndex(b []byte, format ObjectFormat) (*Index, error) {
+	size := format.Size()
+
+	if len(b) < indexHeaderSize+size || !bytes.Equal(b[:4], []byte("DIRC")) {
+		return nil, errors.New("parsing index. Error: invalid signature")
+	}
+
+	idx := Index{
+		Version:  binary.BigEndian.Uint32(b[4:8]),
+		Checksum: hex.EncodeToString(b[len(b)-size:]),
+	}
+	if idx.Version < 2 || idx.Version > 4 {
+		return nil, fmt.Errorf("parsing index. Error: unsupported version %d", idx.Version)
+	}
+
+	count := binary.BigEndian.Uint32(b[8:12])
+	data := b[indexHeaderSize : len(b)-size]
+	prev := ""
+
+	for i := uint32(0); i < count; i++ {
+		if len(data) < indexEntryFixed+size+2 {
+			return nil, fmt.Errorf("parsing index. Error: truncated entry %d", i)
+		}
+
+		entry := IndexEntry{
+			ModTime: time.Unix(int64(binary.BigEndian.Uint32(data[8:12])), int64(binary.BigEndian.Uint32(data[12:16]))).UTC(),
+			Mode:    binary.BigEndian.Uint32(data[24:28]),
+			Size:    binary.BigEndian.Uint32(data[36:40]),
+			Hash:    hex.EncodeToString(data[indexEntryFixed : indexEntryFixed+size]),
+		}
+
+		flags := binary.BigEndian.Uint16(data[indexEntryFixed+size:])
+		offset := indexEntryFixed + size + 2
+		if flags&indexFlagExtended != 0 && idx.Version >= 3 {
+			offset += 2
+		}
+
+		if idx.Version == 4 {
+			strip, n := readOffset(data[offset:])
+			if n == 0 || strip > uint64(len(prev)) {
+				return nil, fmt.Errorf("parsing index. Error: invalid path prefix in entry %d", i)
+			}
+			offset += n
+
+			end := bytes.IndexByte(data[offset:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("parsing index. Error: unterminated path in entry %d", i)
+			}
+
+			entry.Path = prev[:uint64(len(prev))-strip] + string(data[offset:offset+end])
+			data = data[offset+end+1:]
+		} else {
+			end := bytes.IndexByte(data[offset:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("parsing index. Error: unterminated path in entry %d", i)
+			}
+
+			entry.Path = string(data[offset : offset+end])
+
+			// entries are padded with 1-8 nul bytes to a multiple of 8
+			size := (offset + end + 8) &^ 7
+			if size > len(data) {
+				return nil, fmt.Errorf("parsing index. Error: truncated entry %d", i)
+			}
+			data = data[size:]
+		}
+
+		if int(flags&indexNameMask) < indexNameMask && int(flags&indexNameMask) != len(entry.Path) {
+			return nil, fmt.Errorf("parsing index. Error: invalid path length in entry %d", i)
+		}
+
+		prev = entry.Path
+		idx.Entries = append(idx.Entries, entry)
+	}
+
+	return &idx, nil
+}
+
+// readOffset decodes the variable length offset encoding used by packs and index v4.
+func readOffset(b []byte) (value uint64, n int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+
+	c := b[0]
+	value = uint64(c & 0x7f)
+	n = 1
+
+	for c&0x80 != 0 {
+		if n >= len(b) {
+			return 0, 0
+		}
+		c = b[n]
+		n++
+		value = ((value + 1) << 7) | uint64(c&0x7f)
+	}
+
+	return value, n
+}
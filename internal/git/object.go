@@ -0,0 +1,351 @@
+package git
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ObjectType int
+
+const (
+	ObjectInvalid ObjectType = iota
+	ObjectCommit
+	ObjectTree
+	ObjectBlob
+	ObjectTag
+)
+
+func (t ObjectType) String() string {
+	switch t {
+	case ObjectCommit:
+		return "commit"
+	case ObjectTree:
+		return "tree"
+	case ObjectBlob:
+		return "blob"
+	case ObjectTag:
+		return "tag"
+	case ObjectInvalid:
+	}
+
+	return "invalid"
+}
+
+// ParseObjectType converts an object type name to its type.
+func ParseObjectType(s string) (ObjectType, error) {
+	switch s {
+	case "commit":
+		return ObjectCommit, nil
+	case "tree":
+		return ObjectTree, nil
+	case "blob":
+		return ObjectBlob, nil
+	case "tag":
+		return ObjectTag, nil
+	}
+
+	return ObjectInvalid, fmt.Errorf("invalid object type %q", s)
+}
+
+// Object is a decompressed git object, the zero Format is SHA-1.
+type Object struct {
+	Type   ObjectType
+	Data   []byte
+	Format ObjectFormat
+}
+
+// Hash returns the hex encoded object id.
+func (o *Object) Hash() string {
+	h := o.Format.New()
+	_, _ = fmt.Fprintf(h, "%s %d\x00", o.Type, len(o.Data))
+	_, _ = h.Write(o.Data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// maxLooseHeader is the length of the longest loose object header, "commit " followed by a 19 digit size and NUL.
+const maxLooseHeader = 27
+
+// DecodeLoose decompresses a loose object as stored under .git/objects. Objects that inflate to more than maxSize
+// bytes are rejected, a maxSize of 0 or less selects DefaultMaxObjectSize.
+func DecodeLoose(b []byte, format ObjectFormat, maxSize int64) (*Object, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxObjectSize
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing object. Error: %w", err)
+	}
+	defer zr.Close()
+
+	// one byte more than the largest object with its header is read so that larger objects are detected
+	raw, err := io.ReadAll(io.LimitReader(zr, maxSize+maxLooseHeader+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing object. Error: %w", err)
+	}
+
+	header, data, ok := bytes.Cut(raw, []byte{0})
+	if !ok {
+		return nil, errors.New("decoding object. Error: missing header")
+	}
+
+	typ, size, ok := strings.Cut(string(header), " ")
+	if !ok {
+		return nil, fmt.Errorf("decoding object. Error: invalid header %q", header)
+	}
+
+	t, err := ParseObjectType(typ)
+	if err != nil {
+		return nil, fmt.Errorf("decoding object. Error: %w", err)
+	}
+
+	n, err := strconv.ParseInt(size, 10, 64)
+	switch {
+	case err != nil || n < 0:
+		return nil, fmt.Errorf("decoding object. Error: invalid size %q", size)
+	case n > maxSize:
+		return nil, fmt.Errorf("decoding object. Error: object size %d exceeds the limit of %d bytes", n, maxSize)
+	case n != int64(len(data)):
+		return nil, fmt.Errorf("decoding object. Error: inflated %d bytes, the header declares %d", len(data), n)
+	}
+
+	return &Object{Type: t, Data: data, Format: format}, nil
+}
+
+// EncodeLoose compresses an object in the loose object format.
+func EncodeLoose(o *Object) ([]byte, error) {
+	var b bytes.Buffer
+
+	zw := zlib.NewWriter(&b)
+	if _, err := fmt.Fprintf(zw, "%s %d\x00", o.Type, len(o.Data)); err != nil {
+		return nil, fmt.Errorf("compressing object. Error: %w", err)
+	}
+	if _, err := zw.Write(o.Data); err != nil {
+		return nil, fmt.Errorf("compressing object. Error: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compressing object. Error: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// LoosePath returns the path of a loose object relative to the git directory.
+func LoosePath(hash string) string {
+	return "objects/" + hash[:2] + "/" + hash[2:]
+}
+
+// IsHash reports whether s is a hex encoded SHA-1 or SHA-256 object id.
+func IsHash(s string) bool {
+	if len(s) != SHA1.HexSize() && len(s) != SHA256.HexSize() {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+
+	return err == nil
+}
+
+// Signature identifies the author or committer of a commit.
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+type Commit struct {
+	Tree      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	Message   string
+}
+
+// ParseCommit decodes the data of a commit object.
+func ParseCommit(data []byte) (*Commit, error) {
+	var c Commit
+
+	header, message, _ := bytes.Cut(data, []byte("\n\n"))
+	c.Message = string(message)
+
+	for _, line := range strings.Split(string(header), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+
+		switch key {
+		case "tree":
+			c.Tree = value
+		case "parent":
+			c.Parents = append(c.Parents, value)
+		case "author":
+			c.Author = ParseSignature(value)
+		case "committer":
+			c.Committer = ParseSignature(value)
+		}
+	}
+
+	if !IsHash(c.Tree) {
+		return nil, fmt.Errorf("parsing commit. Error: invalid tree %q", c.Tree)
+	}
+
+	return &c, nil
+}
+
+// ParseSignature decodes a "Name <email> unix-time tz" signature.
+func ParseSignature(s string) Signature {
+	var sig Signature
+
+	start, end := strings.Index(s, "<"), strings.Index(s, ">")
+	if start < 0 || end < start {
+		sig.Name = strings.TrimSpace(s)
+		return sig
+	}
+
+	sig.Name = strings.TrimSpace(s[:start])
+	sig.Email = s[start+1 : end]
+
+	fields := strings.Fields(s[end+1:])
+	if len(fields) > 0 {
+		if sec, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			sig.When = time.Unix(sec, 0).UTC()
+		}
+	}
+
+	if len(fields) > 1 {
+		if tz, err := time.Parse("-0700", fields[1]); err == nil {
+			sig.When = sig.When.In(tz.Location())
+		}
+	}
+
+	return sig
+}
+
+type TreeEntry struct {
+	Mode uint32
+	Name string
+	Hash string
+}
+
+// Tree entry modes.
+const (
+	ModeTree      = 0o40000
+	ModeSubmodule = 0o160000
+)
+
+// IsTree reports whether the entry is a sub tree.
+func (e TreeEntry) IsTree() bool {
+	return e.Mode == ModeTree
+}
+
+// IsSubmodule reports whether the entry points at a commit of another repository.
+func (e TreeEntry) IsSubmodule() bool {
+	return e.Mode == ModeSubmodule
+}
+
+// ParseTree decodes the data of a tree object, entries hold raw object ids of the format.
+func ParseTree(data []byte, format ObjectFormat) ([]TreeEntry, error) {
+	var entries []TreeEntry
+
+	size := format.Size()
+
+	for len(data) > 0 {
+		header, rest, ok := bytes.Cut(data, []byte{0})
+		if !ok || len(rest) < size {
+			return nil, errors.New("parsing tree. Error: truncated entry")
+		}
+
+		mode, name, ok := bytes.Cut(header, []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("parsing tree. Error: invalid entry %q", header)
+		}
+
+		m, err := strconv.ParseUint(string(mode), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parsing tree. Error: invalid mode %q", mode)
+		}
+
+		entries = append(entries, TreeEntry{
+			Mode: uint32(m),
+			Name: string(name),
+			Hash: hex.EncodeToString(rest[:size]),
+		})
+
+		data = rest[size:]
+	}
+
+	return entries, nil
+}
+
+type Tag struct {
+	Object string
+	Type   string
+	Name   string
+	Tagger Signature
+}
+
+// ParseTag decodes the data of an annotated tag object.
+func ParseTag(data []byte) (*Tag, error) {
+	var t Tag
+
+	header, _, _ := bytes.Cut(data, []byte("\n\n"))
+	for _, line := range strings.Split(string(header), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+
+		switch key {
+		case "object":
+			t.Object = value
+		case "type":
+			t.Type = value
+		case "tag":
+			t.Name = value
+		case "tagger":
+			t.Tagger = ParseSignature(value)
+		}
+	}
+
+	if !IsHash(t.Object) {
+		return nil, fmt.Errorf("parsing tag. Error: invalid object %q", t.Object)
+	}
+
+	return &t, nil
+}
+
+// References returns the ids of the objects that an object points to.
+func References(o *Object) ([]string, error) {
+	switch o.Type {
+	case ObjectCommit:
+		c, err := ParseCommit(o.Data)
+		if err != nil {
+			return nil, err
+		}
+		return append([]string{c.Tree}, c.Parents...), nil
+	case ObjectTree:
+		entries, err := ParseTree(o.Data, o.Format)
+		if err != nil {
+			return nil, err
+		}
+		refs := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if !e.IsSubmodule() {
+				refs = append(refs, e.Hash)
+			}
+		}
+		return refs, nil
+	case ObjectTag:
+		t, err := ParseTag(o.Data)
+		if err != nil {
+			return nil, err
+		}
+		return []string{t.Object}, nil
+	case ObjectBlob, ObjectInvalid:
+	}
+
+	return nil, nil
+}
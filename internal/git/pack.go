@@ -0,0 +1,300 @@
+package git
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Packed object types, 5 is reserved.
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+
+	packHeaderSize = 12
+	// a one byte header and the smallest zlib stream, the object count of the header is bounded by it.
+	minPackEntrySize = 9
+)
+
+// DefaultMaxObjectSize bounds the inflated size of loose and packed objects when no limit is given.
+const DefaultMaxObjectSize = 256 << 20
+
+type packEntry struct {
+	offset int64
+	typ    int
+	data   []byte
+	base   string
+	baseAt int64
+}
+
+// ParsePack decodes every object of a packfile of the format and resolves deltas. Deltas whose base is not part
+// of the pack are resolved through lookup, which can be nil. Objects that inflate to more than maxSize bytes fail
+// the pack, a maxSize of 0 or less selects DefaultMaxObjectSize.
+//
+//nolint:cyclop
+func ParsePack(b []byte, format ObjectFormat, maxSize int64, lookup func(hash string) *Object) ([]*Object, error) {
+	size := format.Size()
+	if maxSize <= 0 {
+		maxSize = DefaultMaxObjectSize
+	}
+
+	if len(b) < packHeaderSize+size || !bytes.Equal(b[:4], []byte("PACK")) {
+		return nil, errors.New("parsing pack. Error: invalid signature")
+	}
+
+	if v := binary.BigEndian.Uint32(b[4:8]); v != 2 && v != 3 {
+		return nil, fmt.Errorf("parsing pack. Error: unsupported version %d", v)
+	}
+
+	count := binary.BigEndian.Uint32(b[8:12])
+	r := bytes.NewReader(b[:len(b)-size])
+	if _, err := r.Seek(packHeaderSize, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("parsing pack. Error: %w", err)
+	}
+
+	// the count is server controlled, never preallocate more entries than the pack can hold
+	entries := make([]*packEntry, 0, min(int64(count), int64(len(b)/minPackEntrySize)))
+	for i := uint32(0); i < count; i++ {
+		e, err := readPackEntry(r, size, maxSize)
+		if err != nil {
+			return nil, fmt.Errorf("parsing pack entry %d. Error: %w", i, err)
+		}
+		entries = append(entries, e)
+	}
+
+	var (
+		byOffset = make(map[int64]*Object, len(entries))
+		byHash   = make(map[string]*Object, len(entries))
+		objects  = make([]*Object, 0, len(entries))
+		pending  []*packEntry
+	)
+
+	for _, e := range entries {
+		if e.typ == packOfsDelta || e.typ == packRefDelta {
+			pending = append(pending, e)
+			continue
+		}
+
+		o := &Object{Type: packObjectType(e.typ), Data: e.data, Format: format}
+		byOffset[e.offset] = o
+		byHash[o.Hash()] = o
+		objects = append(objects, o)
+	}
+
+	// resolve deltas until no progress is made, bases may be deltas themselves
+	for len(pending) > 0 {
+		var unresolved []*packEntry
+
+		for _, e := range pending {
+			var base *Object
+			if e.typ == packOfsDelta {
+				base = byOffset[e.baseAt]
+			} else {
+				base = byHash[e.base]
+				if base == nil && lookup != nil {
+					base = lookup(e.base)
+				}
+			}
+
+			if base == nil {
+				unresolved = append(unresolved, e)
+				continue
+			}
+
+			data, err := ApplyDelta(base.Data, e.data, maxSize)
+			if err != nil {
+				return nil, fmt.Errorf("parsing pack entry at %d. Error: %w", e.offset, err)
+			}
+
+			o := &Object{Type: base.Type, Data: data, Format: format}
+			byOffset[e.offset] = o
+			byHash[o.Hash()] = o
+			objects = append(objects, o)
+		}
+
+		if len(unresolved) == len(pending) {
+			return objects, fmt.Errorf("parsing pack. Error: %d deltas with missing bases", len(unresolved))
+		}
+		pending = unresolved
+	}
+
+	return objects, nil
+}
+
+func readPackEntry(r *bytes.Reader, hashSize int, maxSize int64) (*packEntry, error) {
+	offset := r.Size() - int64(r.Len())
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	e := packEntry{
+		offset: offset,
+		typ:    int(c>>4) & 0x07,
+	}
+
+	// the declared size of the object or delta bounds the inflation
+	size := uint64(c & 0x0f)
+	for shift := uint(4); c&0x80 != 0; shift += 7 {
+		if shift > 57 {
+			return nil, errors.New("invalid object size")
+		}
+		if c, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		size |= uint64(c&0x7f) << shift
+	}
+	if size > uint64(maxSize) {
+		return nil, fmt.Errorf("object size %d exceeds the limit of %d bytes", size, maxSize)
+	}
+
+	switch e.typ {
+	case packCommit, packTree, packBlob, packTag:
+	case packOfsDelta:
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+			rel = ((rel + 1) << 7) | int64(c&0x7f)
+		}
+		if rel <= 0 || rel > offset {
+			return nil, fmt.Errorf("invalid delta offset %d", rel)
+		}
+		e.baseAt = offset - rel
+	case packRefDelta:
+		base := make([]byte, hashSize)
+		if _, err := io.ReadFull(r, base); err != nil {
+			return nil, err
+		}
+		e.base = hex.EncodeToString(base)
+	default:
+		return nil, fmt.Errorf("invalid object type %d", e.typ)
+	}
+
+	// bytes.Reader is an io.ByteReader so zlib does not read past the end of the stream
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// one byte more than declared is requested so that the end of the stream and its checksum are read
+	if e.data, err = io.ReadAll(io.LimitReader(zr, int64(size)+1)); err != nil {
+		return nil, err
+	}
+	if uint64(len(e.data)) != size {
+		return nil, fmt.Errorf("inflated %d bytes, the entry declares %d", len(e.data), size)
+	}
+
+	return &e, zr.Close()
+}
+
+func packObjectType(t int) ObjectType {
+	switch t {
+	case packCommit:
+		return ObjectCommit
+	case packTree:
+		return ObjectTree
+	case packBlob:
+		return ObjectBlob
+	case packTag:
+		return ObjectTag
+	}
+
+	return ObjectInvalid
+}
+
+// ApplyDelta reconstructs an object from its base and a delta, targets larger than maxSize bytes are rejected.
+func ApplyDelta(base, delta []byte, maxSize int64) ([]byte, error) {
+	srcSize, n := deltaSize(delta)
+	if n == 0 || srcSize != uint64(len(base)) {
+		return nil, errors.New("applying delta. Error: base size mismatch")
+	}
+	delta = delta[n:]
+
+	dstSize, n := deltaSize(delta)
+	if n == 0 {
+		return nil, errors.New("applying delta. Error: invalid target size")
+	}
+	if dstSize > uint64(maxSize) {
+		return nil, fmt.Errorf("applying delta. Error: target size %d exceeds the limit of %d bytes", dstSize, maxSize)
+	}
+	delta = delta[n:]
+
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 == 0 {
+			// insert the next op bytes
+			if op == 0 || int(op) > len(delta) {
+				return nil, errors.New("applying delta. Error: invalid insert")
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+			continue
+		}
+
+		// copy from base, the low bits select which offset and size bytes follow
+		var offset, size uint64
+		for i := uint(0); i < 7; i++ {
+			if op&(1<<i) == 0 {
+				continue
+			}
+			if len(delta) == 0 {
+				return nil, errors.New("applying delta. Error: truncated copy")
+			}
+			if i < 4 {
+				offset |= uint64(delta[0]) << (8 * i)
+			} else {
+				size |= uint64(delta[0]) << (8 * (i - 4))
+			}
+			delta = delta[1:]
+		}
+
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > uint64(len(base)) {
+			return nil, errors.New("applying delta. Error: copy out of bounds")
+		}
+		out = append(out, base[offset:offset+size]...)
+	}
+
+	if uint64(len(out)) != dstSize {
+		return nil, errors.New("applying delta. Error: target size mismatch")
+	}
+
+	return out, nil
+}
+
+func deltaSize(b []byte) (size uint64, n int) {
+	var shift uint
+
+	for n < len(b) {
+		c := b[n]
+		n++
+		size |= uint64(c&0x7f) << shift
+		shift += 7
+
+		if c&0x80 == 0 {
+			return size, n
+		}
+	}
+
+	return 0, 0
+}
@@ -0,0 +1,321 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Packet kinds of the pkt-line format.
+const (
+	PktData = iota
+	PktFlush
+	PktDelim
+	PktResponseEnd
+)
+
+// Sideband channels multiplexed into the pack response.
+const (
+	bandData     = 1
+	bandProgress = 2
+	bandError    = 3
+)
+
+const pktMaxSize = 65520
+
+// PktLine encodes a data packet, an empty payload is not allowed by the protocol.
+func PktLine(payload string) []byte {
+	return []byte(fmt.Sprintf("%04x%s", len(payload)+4, payload))
+}
+
+// FlushPkt and DelimPkt are the special packets that end a message and separate its sections.
+var (
+	FlushPkt = []byte("0000")
+	DelimPkt = []byte("0001")
+)
+
+// PktReader decodes a pkt-line stream.
+type PktReader struct {
+	b   []byte
+	off int
+}
+
+func NewPktReader(b []byte) *PktReader {
+	return &PktReader{b: b}
+}
+
+// Next returns the kind and payload of the next packet, io.EOF is returned at the end of the stream.
+func (r *PktReader) Next() (kind int, payload []byte, err error) {
+	if r.off == len(r.b) {
+		return 0, nil, io.EOF
+	}
+
+	if len(r.b)-r.off < 4 {
+		return 0, nil, errors.New("reading pkt-line. Error: truncated length")
+	}
+
+	n, err := strconv.ParseUint(string(r.b[r.off:r.off+4]), 16, 16)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading pkt-line. Error: invalid length %q", r.b[r.off:r.off+4])
+	}
+
+	switch n {
+	case 0:
+		r.off += 4
+		return PktFlush, nil, nil
+	case 1:
+		r.off += 4
+		return PktDelim, nil, nil
+	case 2:
+		r.off += 4
+		return PktResponseEnd, nil, nil
+	case 3:
+		return 0, nil, errors.New("reading pkt-line. Error: invalid length 3")
+	}
+
+	if n > pktMaxSize || int(n) > len(r.b)-r.off {
+		return 0, nil, fmt.Errorf("reading pkt-line. Error: invalid length %d", n)
+	}
+
+	payload = r.b[r.off+4 : r.off+int(n)]
+	r.off += int(n)
+
+	return PktData, payload, nil
+}
+
+// Rest returns the bytes that were not decoded yet.
+func (r *PktReader) Rest() []byte {
+	return r.b[r.off:]
+}
+
+// Advertisement is the response of info/refs?service=git-upload-pack. Protocol v2 servers only advertise
+// capabilities, the refs are listed through the ls-refs command.
+type Advertisement struct {
+	Version      int
+	Refs         map[string]string
+	Head         string
+	Capabilities []string
+}
+
+// Capability returns the value of a capability and whether it was advertised.
+func (a *Advertisement) Capability(name string) (string, bool) {
+	for _, c := range a.Capabilities {
+		if key, value, _ := strings.Cut(c, "="); key == name {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// ObjectFormat returns the hash algorithm of the advertised repository, servers that do not announce the
+// object-format capability use SHA-1.
+func (a *Advertisement) ObjectFormat() (ObjectFormat, error) {
+	value, _ := a.Capability("object-format")
+
+	return ParseObjectFormat(value)
+}
+
+// ParseAdvertisement decodes a smart HTTP ref advertisement of the git-upload-pack service.
+//
+//nolint:cyclop
+func ParseAdvertisement(b []byte) (*Advertisement, error) {
+	r := NewPktReader(b)
+
+	kind, payload, err := r.Next()
+	if err != nil {
+		return nil, fmt.Errorf("parsing advertisement. Error: %w", err)
+	}
+
+	// v0 responses start with the service announcement, v2 responses may omit it
+	if kind == PktData && strings.HasPrefix(string(payload), "# service=") {
+		if strings.TrimSpace(string(payload)) != "# service=git-upload-pack" {
+			return nil, fmt.Errorf("parsing advertisement. Error: unexpected service %q", truncate(string(payload), 64))
+		}
+
+		if kind, _, err = r.Next(); err != nil || kind != PktFlush {
+			return nil, errors.New("parsing advertisement. Error: missing flush after the service announcement")
+		}
+
+		if kind, payload, err = r.Next(); err != nil {
+			return nil, fmt.Errorf("parsing advertisement. Error: %w", err)
+		}
+	}
+
+	a := Advertisement{
+		Version: 0,
+		Refs:    make(map[string]string),
+	}
+
+	if kind == PktData && strings.TrimSpace(string(payload)) == "version 2" {
+		a.Version = 2
+
+		for {
+			kind, payload, err := r.Next()
+			if err != nil {
+				return nil, fmt.Errorf("parsing advertisement. Error: %w", err)
+			}
+			if kind == PktFlush {
+				return &a, nil
+			}
+			a.Capabilities = append(a.Capabilities, strings.TrimSpace(string(payload)))
+		}
+	}
+
+	// an empty repository is advertised with a flush only
+	if kind == PktFlush {
+		return &a, nil
+	}
+
+	for first := true; ; first = false {
+		if kind != PktData {
+			return nil, errors.New("parsing advertisement. Error: unexpected special packet")
+		}
+
+		line, caps, ok := strings.Cut(strings.TrimSuffix(string(payload), "\n"), "\x00")
+		if first {
+			if !ok {
+				return nil, errors.New("parsing advertisement. Error: missing capabilities")
+			}
+			a.Capabilities = strings.Fields(caps)
+		}
+
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok || !IsHash(hash) {
+			return nil, fmt.Errorf("parsing advertisement. Error: invalid ref line %q", truncate(line, 64))
+		}
+		if name != "capabilities^{}" {
+			a.Refs[name] = hash
+		}
+
+		if kind, payload, err = r.Next(); err != nil {
+			return nil, fmt.Errorf("parsing advertisement. Error: %w", err)
+		}
+		if kind == PktFlush {
+			break
+		}
+	}
+
+	for _, c := range a.Capabilities {
+		if target, ok := strings.CutPrefix(c, "symref=HEAD:"); ok {
+			a.Head = target
+		}
+	}
+
+	return &a, nil
+}
+
+// ParseLsRefs decodes the response of the protocol v2 ls-refs command. It returns the refs and the symbolic
+// ref of HEAD when the symrefs argument was sent.
+func ParseLsRefs(b []byte) (refs map[string]string, head string, err error) {
+	refs = make(map[string]string)
+	r := NewPktReader(b)
+
+	for {
+		kind, payload, err := r.Next()
+		if err != nil {
+			return nil, "", fmt.Errorf("parsing ls-refs. Error: %w", err)
+		}
+		if kind == PktFlush {
+			return refs, head, nil
+		}
+		if kind != PktData {
+			return nil, "", errors.New("parsing ls-refs. Error: unexpected special packet")
+		}
+
+		fields := strings.Fields(string(payload))
+		// unborn HEADs are listed without an object id
+		if len(fields) < 2 || !IsHash(fields[0]) {
+			continue
+		}
+
+		refs[fields[1]] = fields[0]
+		for _, attr := range fields[2:] {
+			if target, ok := strings.CutPrefix(attr, "symref-target:"); ok && fields[1] == "HEAD" {
+				head = target
+			}
+			if peeled, ok := strings.CutPrefix(attr, "peeled:"); ok && IsHash(peeled) {
+				refs[fields[1]+"^{}"] = peeled
+			}
+		}
+	}
+}
+
+// ReadSideband collects the data channel of a side-band-64k stream until a flush packet or the end of the
+// stream. Errors sent on the error channel are returned.
+func ReadSideband(r *PktReader) ([]byte, error) {
+	var data []byte
+
+	for {
+		kind, payload, err := r.Next()
+		if errors.Is(err, io.EOF) || kind == PktFlush {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kind != PktData || len(payload) == 0 {
+			continue
+		}
+
+		switch payload[0] {
+		case bandData:
+			data = append(data, payload[1:]...)
+		case bandProgress:
+		case bandError:
+			return nil, fmt.Errorf("reading sideband. Error: remote error %q", truncate(strings.TrimSpace(string(payload[1:])), 256))
+		default:
+			return nil, fmt.Errorf("reading sideband. Error: invalid band %d", payload[0])
+		}
+	}
+}
+
+// ReadUploadPack extracts the pack of a git-upload-pack response. Protocol v0 responses start with the
+// acknowledgements and carry the pack either raw or multiplexed, protocol v2 responses hold it in the packfile
+// section.
+func ReadUploadPack(b []byte, version int, sideband bool) ([]byte, error) {
+	r := NewPktReader(b)
+
+	if version == 2 {
+		for {
+			kind, payload, err := r.Next()
+			if err != nil {
+				return nil, fmt.Errorf("reading upload-pack response. Error: %w", err)
+			}
+			if kind == PktData && strings.TrimSpace(string(payload)) == "packfile" {
+				return ReadSideband(r)
+			}
+		}
+	}
+
+	for {
+		// the raw pack follows the acknowledgements directly
+		if rest := r.Rest(); strings.HasPrefix(string(rest), "PACK") {
+			return rest, nil
+		}
+
+		kind, payload, err := r.Next()
+		if err != nil {
+			return nil, fmt.Errorf("reading upload-pack response. Error: %w", err)
+		}
+		if kind != PktData {
+			continue
+		}
+
+		line := strings.TrimSpace(string(payload))
+		if msg, ok := strings.CutPrefix(line, "ERR "); ok {
+			return nil, fmt.Errorf("reading upload-pack response. Error: remote error %q", truncate(msg, 256))
+		}
+
+		if line == "NAK" || strings.HasPrefix(line, "ACK ") {
+			if sideband && !strings.HasPrefix(string(r.Rest()), "PACK") {
+				return ReadSideband(r)
+			}
+			continue
+		}
+
+		return nil, fmt.Errorf("reading upload-pack response. Error: unexpected line %q", truncate(line, 64))
+	}
+}
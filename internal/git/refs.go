@@ -0,0 +1,139 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseHead decodes a HEAD like file, it returns either the symbolic ref or the object id it holds.
+func ParseHead(b []byte) (ref, hash string, err error) {
+	s := strings.TrimSpace(string(b))
+
+	if target, ok := strings.CutPrefix(s, "ref:"); ok {
+		ref = strings.TrimSpace(target)
+		if !strings.HasPrefix(ref, "refs/") {
+			return "", "", fmt.Errorf("parsing head. Error: invalid ref %q", ref)
+		}
+		return ref, "", nil
+	}
+
+	// FETCH_HEAD like files carry the object id as the first field
+	if fields := strings.Fields(s); len(fields) > 0 && IsHash(fields[0]) {
+		return "", fields[0], nil
+	}
+
+	return "", "", fmt.Errorf("parsing head. Error: invalid content %q", truncate(s, 64))
+}
+
+// ParsePackedRefs decodes a packed-refs file into ref names and object ids, peeled tags are
+// returned as "<ref>^{}".
+func ParsePackedRefs(b []byte) map[string]string {
+	refs := make(map[string]string)
+
+	var last string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "^"):
+			if hash := line[1:]; IsHash(hash) && last != "" {
+				refs[last+"^{}"] = hash
+			}
+		default:
+			hash, name, ok := strings.Cut(line, " ")
+			if ok && IsHash(hash) {
+				refs[name] = hash
+				last = name
+			}
+		}
+	}
+
+	return refs
+}
+
+// ParseInfoRefs decodes the info/refs file generated by git update-server-info.
+func ParseInfoRefs(b []byte) map[string]string {
+	refs := make(map[string]string)
+
+	for _, line := range strings.Split(string(b), "\n") {
+		hash, name, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if ok && IsHash(hash) {
+			refs[name] = hash
+		}
+	}
+
+	return refs
+}
+
+// ParsePacks decodes objects/info/packs and returns the pack names without extension.
+func ParsePacks(b []byte) []string {
+	var packs []string
+
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "P" && strings.HasSuffix(fields[1], ".pack") {
+			packs = append(packs, strings.TrimSuffix(fields[1], ".pack"))
+		}
+	}
+
+	return packs
+}
+
+// ReflogEntry is a single movement of a ref recorded under logs/.
+type ReflogEntry struct {
+	Old       string
+	New       string
+	Committer Signature
+	Message   string
+}
+
+// ParseReflog decodes a reflog, lines have the form "<old> <new> Name <email> unix-time tz\tmessage".
+func ParseReflog(b []byte) []ReflogEntry {
+	var entries []ReflogEntry
+
+	for _, line := range strings.Split(string(b), "\n") {
+		header, message, _ := strings.Cut(line, "\t")
+
+		fields := strings.SplitN(header, " ", 3)
+		if len(fields) != 3 || !IsHash(fields[0]) || !IsHash(fields[1]) {
+			continue
+		}
+
+		entries = append(entries, ReflogEntry{
+			Old:       fields[0],
+			New:       fields[1],
+			Committer: ParseSignature(fields[2]),
+			Message:   strings.TrimSpace(message),
+		})
+	}
+
+	return entries
+}
+
+// ParseHashes returns the object ids at the start of each line of files such as ORIG_HEAD, MERGE_HEAD and
+// FETCH_HEAD.
+func ParseHashes(b []byte) []string {
+	var hashes []string
+
+	for _, line := range strings.Split(string(b), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && IsHash(fields[0]) && !IsZeroHash(fields[0]) {
+			hashes = append(hashes, fields[0])
+		}
+	}
+
+	return hashes
+}
+
+// IsZeroHash reports whether hash is the null object id used for refs that did not exist.
+func IsZeroHash(hash string) bool {
+	return strings.Trim(hash, "0") == ""
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+
+	return s
+}
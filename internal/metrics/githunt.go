@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Metrics instrumented by the worker pool, the http client and the dumper.
+var (
+	TargetsScanned    = Default.NewCounter("githunt_targets_scanned_total", "Number of scanned targets.")
+	TargetsVulnerable = Default.NewCounter("githunt_targets_vulnerable_total", "Number of targets with at least one finding.")
+	Findings          = Default.NewCounter("githunt_findings_total", "Number of findings by checker.", "checker")
+	Errors            = Default.NewCounter("githunt_errors_total", "Number of request errors by class.", "class")
+	Responses         = Default.NewCounter("githunt_responses_total", "Number of responses by status class.", "code")
+	RequestDuration   = Default.NewHistogram("githunt_request_duration_seconds", "Latency of http requests.", DefaultBuckets)
+	BytesDownloaded   = Default.NewCounter("githunt_downloaded_bytes_total", "Number of response body bytes read.")
+	Workers           = Default.NewGauge("githunt_workers", "Number of running http workers.")
+	WorkersBusy       = Default.NewGauge("githunt_workers_busy", "Number of workers checking a target.")
+	DumpTargets       = Default.NewCounter("githunt_dump_targets_total", "Number of dumped targets by result.", "result")
+	DumpObjects       = Default.NewCounter("githunt_dump_objects_total", "Number of git objects recovered or missed by source.", "status")
+)
+
+// ErrorClass classifies a request error for the errors metric.
+func ErrorClass(err error) string {
+	var (
+		dnsErr  *net.DNSError
+		netErr  net.Error
+		certErr *tls.CertificateVerificationError
+		unknown x509.UnknownAuthorityError
+		header  tls.RecordHeaderError
+	)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "reset"
+	case errors.As(err, &certErr), errors.As(err, &unknown), errors.As(err, &header):
+		return "tls"
+	}
+
+	return "other"
+}
@@ -0,0 +1,126 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georlav/githunt/internal/metrics"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := &metrics.Registry{}
+
+	c := r.NewCounter("test_errors_total", "Errors by class.", "class")
+	c.Inc("timeout")
+	c.Add(2, `dns"`)
+
+	g := r.NewGauge("test_workers", "Workers.")
+	g.Set(5)
+	g.Dec()
+
+	h := r.NewHistogram("test_duration_seconds", "Duration.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	var b bytes.Buffer
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"# TYPE test_errors_total counter",
+		`test_errors_total{class="dns\""} 2`,
+		`test_errors_total{class="timeout"} 1`,
+		"# TYPE test_workers gauge",
+		"test_workers 4",
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{le="0.1"} 1`,
+		`test_duration_seconds_bucket{le="1"} 2`,
+		`test_duration_seconds_bucket{le="+Inf"} 3`,
+		"test_duration_seconds_sum 3.55",
+		"test_duration_seconds_count 3",
+	} {
+		if !strings.Contains(b.String(), expected+"\n") {
+			t.Fatalf("Expected %q in output\n%s", expected, b.String())
+		}
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	testsCases := []struct {
+		description string
+		err         error
+		class       string
+	}{
+		{
+			description: "Should classify deadline as timeout",
+			err:         fmt.Errorf("sending request. Error: %w", context.DeadlineExceeded),
+			class:       "timeout",
+		},
+		{
+			description: "Should classify dns errors",
+			err:         &net.DNSError{Err: "no such host", Name: "example.invalid"},
+			class:       "dns",
+		},
+		{
+			description: "Should classify unknown errors",
+			err:         errors.New("boom"),
+			class:       "other",
+		},
+	}
+
+	for i := range testsCases {
+		tc := testsCases[i]
+
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if class := metrics.ErrorClass(tc.err); class != tc.class {
+				t.Fatalf("Expected %s got %s", tc.class, class)
+			}
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	if err := metrics.Serve(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics.TargetsScanned.Inc()
+
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), "githunt_targets_scanned_total ") {
+		t.Fatalf("Expected scanned targets metric got\n%s", b)
+	}
+}
@@ -0,0 +1,205 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	barWidth        = 25
+	refreshInterval = 200 * time.Millisecond
+)
+
+// Progress renders a live status line with totals, throughput and ETA.
+type Progress struct {
+	out     io.Writer
+	enabled bool
+	started time.Time
+
+	total      atomic.Int64
+	scanned    atomic.Int64
+	vulnerable atomic.Int64
+	errors     atomic.Int64
+	timeouts   atomic.Int64
+
+	mu      sync.Mutex
+	drawn   bool
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+// New creates a progress display writing to out, it is disabled when out is not a terminal.
+func New(out io.Writer) *Progress {
+	enabled := false
+	if f, ok := out.(*os.File); ok {
+		enabled = isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+
+	return &Progress{
+		out:     out,
+		enabled: enabled,
+		started: time.Now(),
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Enabled reports whether the status line is rendered.
+func (p *Progress) Enabled() bool {
+	return p.enabled
+}
+
+// SetTotal sets the number of targets, zero means unknown.
+func (p *Progress) SetTotal(n int64) {
+	p.total.Store(n)
+}
+
+// Add records the outcome of a scanned target.
+func (p *Progress) Add(vulnerable bool, err error) {
+	p.scanned.Add(1)
+
+	if vulnerable {
+		p.vulnerable.Add(1)
+	}
+
+	switch {
+	case err == nil:
+	case IsTimeout(err):
+		p.timeouts.Add(1)
+	default:
+		p.errors.Add(1)
+	}
+}
+
+// Scanned returns the number of scanned targets.
+func (p *Progress) Scanned() int64 {
+	return p.scanned.Load()
+}
+
+// Vulnerable returns the number of vulnerable targets.
+func (p *Progress) Vulnerable() int64 {
+	return p.vulnerable.Load()
+}
+
+// Start redraws the status line until Stop is called or the context is done.
+func (p *Progress) Start(ctx context.Context) {
+	if !p.enabled {
+		close(p.done)
+		return
+	}
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.stopped:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.draw()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing and clears the status line.
+func (p *Progress) Stop() {
+	select {
+	case <-p.stopped:
+		return
+	default:
+		close(p.stopped)
+	}
+	<-p.done
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+}
+
+// Log clears the status line while fn writes to the terminal so that the output is not interleaved.
+func (p *Progress) Log(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	fn()
+}
+
+// Line renders the status line at the given time.
+func (p *Progress) Line(now time.Time) string {
+	var (
+		total   = p.total.Load()
+		scanned = p.scanned.Load()
+		elapsed = now.Sub(p.started)
+		rate    float64
+	)
+
+	if elapsed > 0 {
+		rate = float64(scanned) / elapsed.Seconds()
+	}
+
+	var b strings.Builder
+	if total > 0 {
+		ratio := min(float64(scanned)/float64(total), 1)
+		filled := int(ratio * barWidth)
+
+		b.WriteString("[" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "] ")
+		fmt.Fprintf(&b, "%5.1f%% %d/%d", ratio*100, scanned, total)
+	} else {
+		fmt.Fprintf(&b, "%d/?", scanned)
+	}
+
+	fmt.Fprintf(&b, " | %.1f req/s", rate)
+
+	if total > 0 && rate > 0 && scanned < total {
+		eta := time.Duration(float64(total-scanned) / rate * float64(time.Second))
+		fmt.Fprintf(&b, " | ETA %s", eta.Round(time.Second))
+	}
+
+	fmt.Fprintf(&b, " | vulnerable: %d errors: %d timeouts: %d | %s",
+		p.vulnerable.Load(), p.errors.Load(), p.timeouts.Load(), elapsed.Round(time.Second),
+	)
+
+	return b.String()
+}
+
+func (p *Progress) draw() {
+	_, _ = fmt.Fprint(p.out, "\r\033[K"+p.Line(time.Now()))
+	p.drawn = true
+}
+
+func (p *Progress) clear() {
+	if p.drawn {
+		_, _ = fmt.Fprint(p.out, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+// IsTimeout reports whether an error was caused by a request timeout.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
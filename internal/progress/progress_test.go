@@ -0,0 +1,53 @@
+package progress_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georlav/githunt/internal/progress"
+)
+
+func TestProgress_Line(t *testing.T) {
+	var out bytes.Buffer
+
+	p := progress.New(&out)
+	if p.Enabled() {
+		t.Fatal("Expected progress to be disabled for non terminal output")
+	}
+
+	p.SetTotal(10)
+	p.Add(true, nil)
+	p.Add(false, errors.New("connection refused"))
+	p.Add(false, fmt.Errorf("sending request. Error: %w", context.DeadlineExceeded))
+	p.Add(false, nil)
+
+	line := p.Line(time.Now().Add(2 * time.Second))
+
+	for _, expected := range []string{"40.0% 4/10", "req/s", "ETA", "vulnerable: 1 errors: 1 timeouts: 1"} {
+		if !strings.Contains(line, expected) {
+			t.Fatalf("Expected %q in %q", expected, line)
+		}
+	}
+
+	p.Start(context.Background())
+	p.Stop()
+
+	if out.Len() != 0 {
+		t.Fatalf("Expected no output got %q", out.String())
+	}
+}
+
+func TestProgress_LineUnknownTotal(t *testing.T) {
+	p := progress.New(&bytes.Buffer{})
+	p.Add(false, nil)
+
+	line := p.Line(time.Now().Add(time.Second))
+	if !strings.HasPrefix(line, "1/?") || strings.Contains(line, "ETA") {
+		t.Fatalf("Unexpected line %q", line)
+	}
+}
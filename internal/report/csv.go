@@ -0,0 +1,75 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georlav/githunt/internal/results"
+)
+
+var csvHeader = []string{"target", "checker", "severity", "vulnerable", "url", "metadata", "error", "time", "evidence"}
+
+// csvReporter writes a row per record as they arrive.
+type csvReporter struct {
+	w      *csv.Writer
+	cfg    config
+	header bool
+}
+
+func newCSV(w io.Writer, cfg config) *csvReporter {
+	return &csvReporter{w: csv.NewWriter(w), cfg: cfg}
+}
+
+func (c *csvReporter) Add(records ...results.Record) error {
+	if !c.header {
+		c.header = true
+		if err := c.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+
+	for i := range records {
+		r := records[i]
+		if !r.Vulnerable && !c.cfg.all {
+			continue
+		}
+
+		metadata := make([]string, 0, len(r.Metadata))
+		for _, k := range metadataKeys(r.Metadata) {
+			metadata = append(metadata, k+"="+r.Metadata[k])
+		}
+
+		row := []string{
+			r.Target, r.Checker, r.Severity, strconv.FormatBool(r.Vulnerable), r.URL,
+			strings.Join(metadata, "; "), r.Error, r.Time.Format(time.RFC3339), r.Evidence,
+		}
+		for j := range row {
+			row[j] = escapeFormula(row[j])
+		}
+
+		if err := c.w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	// rows are flushed per call so a live report can be followed
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+func (c *csvReporter) Close() error {
+	return c.Add()
+}
+
+// escapeFormula prevents spreadsheets from evaluating cells taken from responses as formulas.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
@@ -0,0 +1,180 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/georlav/githunt/internal/results"
+)
+
+// htmlTemplate is a self-contained page, it loads no external styles or scripts.
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>githunt report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0; }
+.meta { color: #666; margin-top: .3em; }
+.summary { display: flex; gap: 1em; margin: 1.5em 0; flex-wrap: wrap; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: .8em 1.2em; min-width: 7em; }
+.card b { display: block; font-size: 1.6em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #eee; padding: .5em; text-align: left; vertical-align: top; }
+th { background: #f6f6f6; }
+td.evidence { font-family: monospace; font-size: .9em; word-break: break-all; }
+.sev { border-radius: 4px; color: #fff; padding: .1em .5em; font-size: .85em; text-transform: uppercase; }
+.critical { background: #7b0000; } .high { background: #c62828; } .medium { background: #ef6c00; }
+.low { background: #f9a825; } .info, .unknown { background: #607d8b; }
+</style>
+</head>
+<body>
+<h1>githunt report</h1>
+<p class="meta">Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}} by githunt {{.Version}}</p>
+<div class="summary">
+<div class="card"><b>{{.Targets}}</b>targets</div>
+<div class="card"><b>{{.Vulnerable}}</b>vulnerable</div>
+{{- range .Severities}}
+<div class="card"><b>{{.Count}}</b><span class="sev {{.Class}}">{{.Name}}</span></div>
+{{- end}}
+<div class="card"><b>{{.Failed}}</b>errors</div>
+</div>
+<h2>Findings</h2>
+{{- if .Findings}}
+<table>
+<tr><th>Severity</th><th>Target</th><th>Check</th><th>URL</th><th>Evidence</th><th>Time</th></tr>
+{{- range .Findings}}
+<tr>
+<td><span class="sev {{class .Severity}}">{{or .Severity "unknown"}}</span></td>
+<td>{{.Target}}</td>
+<td>{{.Checker}}</td>
+<td><a href="{{.URL}}" rel="noreferrer">{{.URL}}</a></td>
+<td class="evidence">{{range $k, $v := .Metadata}}{{$k}}: {{$v}}<br>{{end}}{{if .Evidence}}saved in {{.Evidence}}{{end}}</td>
+<td>{{.Time.Format "2006-01-02 15:04:05"}}</td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No findings.</p>
+{{- end}}
+{{- if .Clusters}}
+<h2>Shared repositories</h2>
+<table>
+<tr><th>Cluster</th><th>Targets</th><th>Remote</th><th>HEAD</th><th>Index</th></tr>
+{{- range .Clusters}}
+<tr>
+<td class="evidence">{{.ID}}</td>
+<td>{{range .Targets}}{{.}}<br>{{end}}</td>
+<td class="evidence">{{range .Remotes}}{{.}}<br>{{end}}</td>
+<td class="evidence">{{range .Heads}}{{.}}<br>{{end}}</td>
+<td class="evidence">{{range .Indexes}}{{.}}<br>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Others}}
+<h2>Other targets</h2>
+<table>
+<tr><th>Target</th><th>Error</th><th>Time</th></tr>
+{{- range .Others}}
+<tr><td>{{.Target}}</td><td>{{.Error}}</td><td>{{.Time.Format "2006-01-02 15:04:05"}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{"class": class}).Parse(htmlTemplate))
+
+type htmlSeverity struct {
+	Name  string
+	Class string
+	Count int
+}
+
+type htmlData struct {
+	Generated  time.Time
+	Version    string
+	Targets    int
+	Vulnerable int
+	Failed     int
+	Severities []htmlSeverity
+	Findings   []results.Record
+	Others     []results.Record
+	// Clusters lists the repositories exposed by more than one target.
+	Clusters []results.Cluster
+}
+
+// htmlReporter collects the records and writes the page on Close.
+type htmlReporter struct {
+	w       io.Writer
+	cfg     config
+	records []results.Record
+}
+
+func newHTML(w io.Writer, cfg config) *htmlReporter {
+	return &htmlReporter{w: w, cfg: cfg}
+}
+
+func (h *htmlReporter) Add(records ...results.Record) error {
+	h.records = append(h.records, records...)
+
+	return nil
+}
+
+func (h *htmlReporter) Close() error {
+	data := htmlData{
+		Generated: time.Now(),
+		Version:   h.cfg.version,
+		Targets:   len(results.Targets(h.records)),
+	}
+
+	counts := make(map[string]int)
+	vulnerable := make(map[string]struct{})
+	for _, r := range h.records {
+		switch {
+		case r.Vulnerable:
+			data.Findings = append(data.Findings, r)
+			counts[class(r.Severity)]++
+			vulnerable[r.Target] = struct{}{}
+		case r.Error != "":
+			data.Failed++
+			if h.cfg.all {
+				data.Others = append(data.Others, r)
+			}
+		case h.cfg.all:
+			data.Others = append(data.Others, r)
+		}
+	}
+	data.Vulnerable = len(vulnerable)
+
+	for _, s := range append(severities, "unknown") {
+		if counts[s] > 0 {
+			data.Severities = append(data.Severities, htmlSeverity{Name: s, Class: s, Count: counts[s]})
+		}
+	}
+
+	for _, c := range results.Clusters(data.Findings) {
+		if len(c.Targets) > 1 {
+			data.Clusters = append(data.Clusters, c)
+		}
+	}
+
+	sortRecords(data.Findings)
+	sortRecords(data.Others)
+
+	return page.Execute(h.w, data)
+}
+
+// class returns the css class of a severity.
+func class(severity string) string {
+	if s := strings.ToLower(severity); rank(s) < len(severities) {
+		return s
+	}
+
+	return "unknown"
+}
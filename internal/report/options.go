@@ -0,0 +1,19 @@
+package report
+
+type Option func(*config)
+
+// SetVersion sets the tool version recorded in reports.
+func SetVersion(version string) Option {
+	return func(args *config) {
+		if version != "" {
+			args.version = version
+		}
+	}
+}
+
+// SetAll include targets without findings in CSV and HTML reports.
+func SetAll(all bool) Option {
+	return func(args *config) {
+		args.all = all
+	}
+}
@@ -0,0 +1,118 @@
+// Package report renders scan results as SARIF, CSV or HTML.
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/georlav/githunt/internal/results"
+)
+
+// Report formats.
+const (
+	FormatSARIF = "sarif"
+	FormatCSV   = "csv"
+	FormatHTML  = "html"
+)
+
+// Formats lists the supported report formats.
+var Formats = []string{FormatSARIF, FormatCSV, FormatHTML}
+
+// Reporter renders records as they arrive, documents that need every record are written on Close.
+type Reporter interface {
+	Add(records ...results.Record) error
+	Close() error
+}
+
+type config struct {
+	version string
+	all     bool
+}
+
+// New creates the reporter of format writing to w.
+func New(format string, w io.Writer, options ...Option) (Reporter, error) {
+	cfg := config{version: "dev"}
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	switch format {
+	case FormatSARIF:
+		return newSARIF(w, cfg), nil
+	case FormatCSV:
+		return newCSV(w, cfg), nil
+	case FormatHTML:
+		return newHTML(w, cfg), nil
+	default:
+		return nil, fmt.Errorf("invalid report format %s", format)
+	}
+}
+
+// Supported reports whether format is a report format.
+func Supported(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write renders records in one pass.
+func Write(w io.Writer, format string, records []results.Record, options ...Option) error {
+	r, err := New(format, w, options...)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Add(records...); err != nil {
+		return err
+	}
+
+	return r.Close()
+}
+
+// severities from the most to the least severe, unknown severities rank last.
+var severities = []string{"critical", "high", "medium", "low", "info"}
+
+func rank(severity string) int {
+	for i, s := range severities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+
+	return len(severities)
+}
+
+// sortRecords orders records by severity, target and checker.
+func sortRecords(records []results.Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Vulnerable != b.Vulnerable {
+			return a.Vulnerable
+		}
+		if ra, rb := rank(a.Severity), rank(b.Severity); ra != rb {
+			return ra < rb
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+
+		return a.Checker < b.Checker
+	})
+}
+
+// metadataKeys returns the sorted keys of metadata.
+func metadataKeys(metadata map[string]string) []string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
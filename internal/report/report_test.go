@@ -0,0 +1,247 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georlav/githunt/internal/report"
+	"github.com/georlav/githunt/internal/results"
+)
+
+var (
+	now     = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	records = []results.Record{
+		{
+			Target: "https://a.example.com", URL: "https://a.example.com/.git/config", Checker: "git-config",
+			Severity: "medium", Vulnerable: true, Metadata: map[string]string{"remote": "=HYPERLINK(\"x\")"}, Time: now,
+		},
+		{
+			Target: "https://b.example.com", URL: "https://b.example.com/.env", Checker: "dotenv",
+			Severity: "high", Vulnerable: true, Metadata: map[string]string{"app_key": "<script>alert(1)</script>"}, Time: now,
+		},
+		{
+			Target: "https://b.example.com", URL: "https://b.example.com/.git/config", Checker: "git-config",
+			Severity: "medium", Vulnerable: true, Time: now,
+		},
+		{Target: "https://c.example.com", Time: now},
+		{Target: "https://d.example.com", Error: "connection refused", Time: now},
+	}
+)
+
+func TestSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report.Write(&buf, report.FormatSARIF, records, report.SetVersion("v1.2.3")); err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Version string `json:"version"`
+					Rules   []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+				PartialFingerprints map[string]string `json:"partialFingerprints"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || log.Runs[0].Tool.Driver.Version != "v1.2.3" {
+		t.Fatalf("Unexpected log %s", buf.String())
+	}
+
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Fatalf("Expected 2 rules got %d", len(run.Tool.Driver.Rules))
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("Expected 3 results got %d", len(run.Results))
+	}
+
+	// the most severe finding comes first
+	first := run.Results[0]
+	if first.RuleID != "dotenv" || first.Level != "error" || first.Locations[0].PhysicalLocation.ArtifactLocation.URI != records[1].URL {
+		t.Fatalf("Unexpected result %+v", first)
+	}
+
+	fingerprints := make(map[string]bool)
+	for _, r := range run.Results {
+		if run.Tool.Driver.Rules[r.RuleIndex].ID != r.RuleID {
+			t.Fatalf("Rule index %d does not point to %s", r.RuleIndex, r.RuleID)
+		}
+		fingerprints[r.PartialFingerprints["githunt/v1"]] = true
+	}
+	if len(fingerprints) != 3 {
+		t.Fatalf("Expected distinct fingerprints got %v", fingerprints)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options []report.Option
+		rows    int
+	}{
+		{"findings", nil, 3},
+		{"all", []report.Option{report.SetAll(true)}, 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			r, err := report.New(report.FormatCSV, &buf, tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// rows are written as records arrive
+			for i := range records {
+				if err := r.Add(records[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			rows, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(rows) != tc.rows+1 {
+				t.Fatalf("Expected %d rows got %d", tc.rows+1, len(rows))
+			}
+			if rows[0][0] != "target" {
+				t.Fatalf("Expected header got %v", rows[0])
+			}
+			if rows[1][5] != `remote==HYPERLINK("x")` {
+				t.Fatalf("Unexpected metadata %q", rows[1][5])
+			}
+		})
+	}
+}
+
+func TestCSVFormula(t *testing.T) {
+	var buf bytes.Buffer
+
+	record := results.Record{Target: "=cmd|' /C calc'!A0", Vulnerable: true, Time: now}
+	if err := report.Write(&buf, report.FormatCSV, []results.Record{record}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "'=cmd") {
+		t.Fatalf("Expected the formula to be escaped got %s", buf.String())
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report.Write(&buf, report.FormatHTML, records, report.SetAll(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	page := buf.String()
+	for _, expected := range []string{
+		"<!DOCTYPE html>",
+		"https://b.example.com/.env",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		"connection refused",
+		`<span class="sev high">high</span>`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Fatalf("Expected %q in report", expected)
+		}
+	}
+
+	if strings.Contains(page, "<script") || strings.Contains(page, "<link") {
+		t.Fatal("Expected a self-contained report without scripts")
+	}
+
+	if strings.Index(page, "https://b.example.com/.env") > strings.Index(page, "https://a.example.com/.git/config") {
+		t.Fatal("Expected findings ordered by severity")
+	}
+}
+
+func TestClusters(t *testing.T) {
+	head := map[string]string{"head": "1111111111111111111111111111111111111111"}
+	shared := []results.Record{
+		{Target: "https://a.example.com", URL: "https://a.example.com/.git/HEAD", Checker: "git-head", Vulnerable: true, Metadata: head, Time: now},
+		{Target: "https://b.example.com", URL: "https://b.example.com/.git/HEAD", Checker: "git-head", Vulnerable: true, Metadata: head, Time: now},
+		{Target: "https://c.example.com", URL: "https://c.example.com/.git/HEAD", Checker: "git-head", Vulnerable: true, Time: now},
+	}
+	id := results.Clusters(shared)[0].ID
+
+	var buf bytes.Buffer
+	if err := report.Write(&buf, report.FormatSARIF, shared); err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	clustered := 0
+	for _, r := range log.Runs[0].Results {
+		if cluster, ok := r.Properties["cluster"]; ok {
+			if cluster != id {
+				t.Fatalf("Unexpected cluster %v", cluster)
+			}
+			clustered++
+		}
+	}
+	if clustered != 2 {
+		t.Fatalf("Expected 2 clustered results got %d", clustered)
+	}
+
+	buf.Reset()
+	if err := report.Write(&buf, report.FormatHTML, shared); err != nil {
+		t.Fatal(err)
+	}
+
+	if page := buf.String(); !strings.Contains(page, "Shared repositories") || !strings.Contains(page, id) {
+		t.Fatal("Expected the shared repositories in the report")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, format := range report.Formats {
+		if _, err := report.New(format, &bytes.Buffer{}); err != nil {
+			t.Fatalf("Unexpected error %s", err)
+		}
+	}
+
+	if _, err := report.New("pdf", &bytes.Buffer{}); err == nil {
+		t.Fatal("Expected an error for an unknown format")
+	}
+}
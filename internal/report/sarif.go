@@ -0,0 +1,217 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/georlav/githunt/internal/results"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolURI      = "https://github.com/georlav/githunt"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string         `json:"id"`
+	Name                 string         `json:"name"`
+	ShortDescription     sarifMessage   `json:"shortDescription"`
+	DefaultConfiguration sarifRuleLevel `json:"defaultConfiguration"`
+	Properties           map[string]any `json:"properties,omitempty"`
+}
+
+type sarifRuleLevel struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]any    `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifReporter collects the findings and writes a SARIF 2.1.0 log with one run on Close.
+type sarifReporter struct {
+	w       io.Writer
+	cfg     config
+	records []results.Record
+}
+
+func newSARIF(w io.Writer, cfg config) *sarifReporter {
+	return &sarifReporter{w: w, cfg: cfg}
+}
+
+func (s *sarifReporter) Add(records ...results.Record) error {
+	for i := range records {
+		if records[i].Vulnerable {
+			s.records = append(s.records, records[i])
+		}
+	}
+
+	return nil
+}
+
+func (s *sarifReporter) Close() error {
+	sortRecords(s.records)
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "githunt",
+			Version:        s.cfg.version,
+			InformationURI: toolURI,
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	clusters := results.ClusterIDs(results.Clusters(s.records))
+
+	rules := make(map[string]int)
+	for _, r := range s.records {
+		idx, ok := rules[r.Checker]
+		if !ok {
+			idx = len(run.Tool.Driver.Rules)
+			rules[r.Checker] = idx
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:                   r.Checker,
+				Name:                 r.Checker,
+				ShortDescription:     sarifMessage{Text: "Exposed resource detected by the " + r.Checker + " check"},
+				DefaultConfiguration: sarifRuleLevel{Level: level(r.Severity)},
+				Properties: map[string]any{
+					"security-severity": securitySeverity(r.Severity),
+					"tags":              []string{"security"},
+				},
+			})
+		}
+
+		properties := map[string]any{"target": r.Target}
+		if r.Severity != "" {
+			properties["severity"] = r.Severity
+		}
+		if len(r.Metadata) > 0 {
+			properties["metadata"] = r.Metadata
+		}
+		if r.Evidence != "" {
+			properties["evidence"] = r.Evidence
+		}
+		if id := clusters[r.Target]; id != "" {
+			properties["cluster"] = id
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    r.Checker,
+			RuleIndex: idx,
+			Level:     level(r.Severity),
+			Message:   sarifMessage{Text: message(r)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: r.URL}},
+			}},
+			PartialFingerprints: map[string]string{"githunt/v1": fingerprint(r)},
+			Properties:          properties,
+		})
+	}
+
+	enc := json.NewEncoder(s.w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	})
+}
+
+// level maps a severity to a SARIF result level.
+func level(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "high":
+		return "error"
+	case "medium":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity maps a severity to the CVSS like score code scanning dashboards rank alerts by.
+func securitySeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "9.5"
+	case "high":
+		return "8.0"
+	case "medium":
+		return "5.5"
+	case "low":
+		return "3.0"
+	default:
+		return "0.0"
+	}
+}
+
+// message describes a finding in one line.
+func message(r results.Record) string {
+	msg := r.Checker + " on " + r.Target
+	if r.URL != "" {
+		msg += " (" + r.URL + ")"
+	}
+
+	for _, k := range metadataKeys(r.Metadata) {
+		msg += ", " + k + ": " + r.Metadata[k]
+	}
+
+	return msg
+}
+
+// fingerprint identifies a finding across scans.
+func fingerprint(r results.Record) string {
+	sum := sha256.Sum256([]byte(r.Checker + "\x00" + r.URL))
+
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,200 @@
+package results
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Fingerprint identifies the repository that a target exposes.
+type Fingerprint struct {
+	Remote string `json:"remote,omitempty"`
+	Head   string `json:"head,omitempty"`
+	Index  string `json:"index,omitempty"`
+}
+
+// keys returns the attributes that link targets, empty attributes link nothing.
+func (f Fingerprint) keys() []string {
+	var keys []string
+	if f.Remote != "" {
+		keys = append(keys, "remote:"+f.Remote)
+	}
+	if f.Head != "" {
+		keys = append(keys, "head:"+f.Head)
+	}
+	if f.Index != "" {
+		keys = append(keys, "index:"+f.Index)
+	}
+
+	return keys
+}
+
+// Cluster is a group of targets that expose the same repository. Targets are linked when they share the remote
+// URL, the HEAD commit or the index checksum.
+type Cluster struct {
+	ID      string   `json:"id"`
+	Targets []string `json:"targets"`
+	Remotes []string `json:"remotes,omitempty"`
+	Heads   []string `json:"heads,omitempty"`
+	Indexes []string `json:"indexes,omitempty"`
+}
+
+// Fingerprints collects the remote, head and index metadata of the findings of every vulnerable target.
+func Fingerprints(records []Record) map[string]Fingerprint {
+	fingerprints := make(map[string]Fingerprint)
+
+	for i := range records {
+		r := records[i]
+		if !r.Vulnerable || r.Target == "" {
+			continue
+		}
+
+		f := fingerprints[r.Target]
+		if remote := NormalizeRemote(r.Metadata["remote"]); remote != "" {
+			f.Remote = remote
+		}
+		if head := r.Metadata["head"]; head != "" {
+			f.Head = head
+		}
+		if index := r.Metadata["index"]; index != "" {
+			f.Index = index
+		}
+		fingerprints[r.Target] = f
+	}
+
+	return fingerprints
+}
+
+// Clusters groups the vulnerable targets of records by repository. Targets without any fingerprint attribute are
+// left out, clusters are ordered by size.
+func Clusters(records []Record) []Cluster {
+	fingerprints := Fingerprints(records)
+
+	targets := make([]string, 0, len(fingerprints))
+	for target, f := range fingerprints {
+		if len(f.keys()) > 0 {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+
+	// union find over the targets, each attribute joins the targets that share it
+	parent := make(map[string]string, len(targets))
+	var find func(string) string
+	find = func(t string) string {
+		if parent[t] != t {
+			parent[t] = find(parent[t])
+		}
+		return parent[t]
+	}
+
+	owner := make(map[string]string)
+	for _, t := range targets {
+		parent[t] = t
+		for _, key := range fingerprints[t].keys() {
+			if o, ok := owner[key]; ok {
+				parent[find(t)] = find(o)
+				continue
+			}
+			owner[key] = t
+		}
+	}
+
+	groups := make(map[string]*Cluster)
+	keys := make(map[string][]string)
+	for _, t := range targets {
+		root := find(t)
+		c, ok := groups[root]
+		if !ok {
+			c = &Cluster{}
+			groups[root] = c
+		}
+
+		f := fingerprints[t]
+		c.Targets = append(c.Targets, t)
+		c.Remotes = appendUnique(c.Remotes, f.Remote)
+		c.Heads = appendUnique(c.Heads, f.Head)
+		c.Indexes = appendUnique(c.Indexes, f.Index)
+		keys[root] = append(keys[root], f.keys()...)
+	}
+
+	clusters := make([]Cluster, 0, len(groups))
+	for root, c := range groups {
+		sort.Strings(keys[root])
+		sum := sha256.Sum256([]byte(keys[root][0]))
+		c.ID = hex.EncodeToString(sum[:])[:12]
+
+		sort.Strings(c.Remotes)
+		sort.Strings(c.Heads)
+		sort.Strings(c.Indexes)
+		clusters = append(clusters, *c)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Targets) != len(clusters[j].Targets) {
+			return len(clusters[i].Targets) > len(clusters[j].Targets)
+		}
+		return clusters[i].ID < clusters[j].ID
+	})
+
+	return clusters
+}
+
+// ClusterIDs maps the targets of clusters with more than one target to their cluster.
+func ClusterIDs(clusters []Cluster) map[string]string {
+	ids := make(map[string]string)
+
+	for _, c := range clusters {
+		if len(c.Targets) < 2 {
+			continue
+		}
+		for _, t := range c.Targets {
+			ids[t] = c.ID
+		}
+	}
+
+	return ids
+}
+
+// NormalizeRemote reduces the forms of a remote URL to host and path, credentials, the scheme, the user of scp like
+// URLs and the .git suffix are dropped so that https and ssh remotes of a repository are equal.
+func NormalizeRemote(remote string) string {
+	remote = strings.TrimSpace(remote)
+	if remote == "" {
+		return ""
+	}
+
+	var host, p string
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		host, p = u.Hostname(), u.Path
+	} else if at, rest, ok := strings.Cut(remote, ":"); ok && !strings.Contains(at, "/") {
+		// scp like syntax, user@host:path
+		_, host, _ = strings.Cut(at, "@")
+		if host == "" {
+			host = at
+		}
+		p = rest
+	} else {
+		p = remote
+	}
+
+	p = strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+
+	return strings.ToLower(strings.TrimSuffix(host+"/"+p, "/"))
+}
+
+func appendUnique(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+
+	return append(values, value)
+}
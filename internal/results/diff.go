@@ -0,0 +1,136 @@
+package results
+
+import (
+	"sort"
+)
+
+// Kinds of changes reported by Diff.
+const (
+	ChangeNew         = "new"
+	ChangeRemediated  = "remediated"
+	ChangeHeadChanged = "head-changed"
+	ChangeUnreachable = "unreachable"
+)
+
+// changeOrder sorts the changes of a diff.
+var changeOrder = map[string]int{
+	ChangeNew:         0,
+	ChangeRemediated:  1,
+	ChangeHeadChanged: 2,
+	ChangeUnreachable: 3,
+}
+
+// Change is a difference between two result sets.
+type Change struct {
+	Kind     string `json:"kind"`
+	Target   string `json:"target"`
+	Checker  string `json:"checker,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	URL      string `json:"url,omitempty"`
+	// Before and After hold the HEAD commits of head-changed and the error of unreachable changes.
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+type targetState struct {
+	findings map[string]Record
+	err      string
+	head     string
+}
+
+// Diff compares two result sets. Targets that are only in before are ignored since they were not rescanned.
+func Diff(before, after []Record) []Change {
+	old, current := states(before), states(after)
+
+	var changes []Change
+	for target, now := range current {
+		prev, scanned := old[target]
+		if !scanned {
+			prev = &targetState{}
+		}
+
+		// an unreachable target tells nothing about its findings
+		if now.err != "" && len(now.findings) == 0 {
+			if scanned && prev.err == "" {
+				changes = append(changes, Change{Kind: ChangeUnreachable, Target: target, After: now.err})
+			}
+			continue
+		}
+
+		for name, r := range now.findings {
+			if _, ok := prev.findings[name]; !ok {
+				changes = append(changes, Change{
+					Kind:     ChangeNew,
+					Target:   target,
+					Checker:  name,
+					Severity: r.Severity,
+					URL:      r.URL,
+				})
+			}
+		}
+
+		// the failed checkers are not known, a finding that is missing may just not have been checked
+		for name, r := range prev.findings {
+			if _, ok := now.findings[name]; !ok && now.err == "" {
+				changes = append(changes, Change{
+					Kind:     ChangeRemediated,
+					Target:   target,
+					Checker:  name,
+					Severity: r.Severity,
+					URL:      r.URL,
+				})
+			}
+		}
+
+		if prev.head != "" && now.head != "" && prev.head != now.head {
+			changes = append(changes, Change{
+				Kind:   ChangeHeadChanged,
+				Target: target,
+				Before: prev.head,
+				After:  now.head,
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Kind != b.Kind {
+			return changeOrder[a.Kind] < changeOrder[b.Kind]
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Checker < b.Checker
+	})
+
+	return changes
+}
+
+func states(records []Record) map[string]*targetState {
+	targets := make(map[string]*targetState)
+
+	for i := range records {
+		r := records[i]
+		if r.Target == "" {
+			continue
+		}
+
+		s, ok := targets[r.Target]
+		if !ok {
+			s = &targetState{findings: make(map[string]Record)}
+			targets[r.Target] = s
+		}
+
+		if r.Error != "" {
+			s.err = r.Error
+		}
+		if r.Vulnerable {
+			s.findings[r.Checker] = r
+			if head := r.Metadata["head"]; head != "" {
+				s.head = head
+			}
+		}
+	}
+
+	return targets
+}
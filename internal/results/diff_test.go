@@ -0,0 +1,65 @@
+package results_test
+
+import (
+	"testing"
+
+	"github.com/georlav/githunt/internal/results"
+)
+
+func TestDiff(t *testing.T) {
+	finding := func(target, checker, head string) results.Record {
+		r := results.Record{Target: target, Checker: checker, Vulnerable: true, URL: target + "/.git/config"}
+		if head != "" {
+			r.Metadata = map[string]string{"head": head}
+		}
+		return r
+	}
+
+	before := []results.Record{
+		finding("https://fixed.example.com", "git-config", ""),
+		finding("https://moved.example.com", "git-head", "aaaa"),
+		finding("https://down.example.com", "git-config", ""),
+		finding("https://partial.example.com", "git-config", ""),
+		finding("https://partial.example.com", "env-file", ""),
+		finding("https://failed.example.com", "git-config", ""),
+		finding("https://failed.example.com", "env-file", ""),
+		{Target: "https://clean.example.com"},
+		{Target: "https://flaky.example.com", Error: "timeout"},
+		{Target: "https://gone.example.com"},
+	}
+
+	after := []results.Record{
+		{Target: "https://fixed.example.com"},
+		finding("https://moved.example.com", "git-head", "bbbb"),
+		{Target: "https://down.example.com", Error: "connection refused"},
+		finding("https://partial.example.com", "git-config", ""),
+		// the env-file checker failed, its finding is not remediated
+		{Target: "https://failed.example.com", Checker: "git-config", Vulnerable: true, Error: "env-file: timeout"},
+		finding("https://clean.example.com", "git-config", ""),
+		finding("https://new.example.com", "git-config", ""),
+		{Target: "https://flaky.example.com", Error: "timeout"},
+		{Error: "parsing %zz"},
+	}
+
+	expected := []results.Change{
+		{Kind: results.ChangeNew, Target: "https://clean.example.com", Checker: "git-config"},
+		{Kind: results.ChangeNew, Target: "https://new.example.com", Checker: "git-config"},
+		{Kind: results.ChangeRemediated, Target: "https://fixed.example.com", Checker: "git-config"},
+		{Kind: results.ChangeRemediated, Target: "https://partial.example.com", Checker: "env-file"},
+		{Kind: results.ChangeHeadChanged, Target: "https://moved.example.com", Before: "aaaa", After: "bbbb"},
+		{Kind: results.ChangeUnreachable, Target: "https://down.example.com", After: "connection refused"},
+	}
+
+	changes := results.Diff(before, after)
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes got %+v", len(expected), changes)
+	}
+
+	for i := range expected {
+		c := changes[i]
+		c.URL = ""
+		if c != expected[i] {
+			t.Fatalf("Expected change %d to be %+v got %+v", i, expected[i], c)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/georlav/githunt/internal/worker"
+)
+
+// Record is a single stored scan result, targets without findings are stored with Vulnerable unset.
+type Record struct {
+	Target     string            `json:"target"`
+	URL        string            `json:"url,omitempty"`
+	Checker    string            `json:"checker,omitempty"`
+	Severity   string            `json:"severity,omitempty"`
+	Vulnerable bool              `json:"vulnerable"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Time       time.Time         `json:"time"`
+	// Evidence is the directory where the raw exchanges of the finding were saved.
+	Evidence string `json:"evidence,omitempty"`
+}
+
+// FromResult converts a worker result to records, one per finding.
+func FromResult(r worker.Result, now time.Time) []Record {
+	base := Record{Time: now.UTC()}
+	if r.URL != nil {
+		base.Target = r.URL.String()
+	}
+	if r.Error != nil {
+		base.Error = r.Error.Error()
+	}
+
+	if len(r.Findings) == 0 {
+		return []Record{base}
+	}
+
+	records := make([]Record, 0, len(r.Findings))
+	for _, f := range r.Findings {
+		rec := base
+		rec.URL = f.URL.String()
+		rec.Checker = f.Checker
+		rec.Severity = f.Severity
+		rec.Vulnerable = true
+		rec.Metadata = f.Metadata
+		records = append(records, rec)
+	}
+
+	return records
+}
+
+// Read decodes JSON lines records.
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("decoding record on line %d. Error: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading records. Error: %w", err)
+	}
+
+	return records, nil
+}
+
+// ReadFile decodes a JSON lines results file.
+func ReadFile(filename string) ([]Record, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening results file %s. Error: %w", filename, err)
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Targets returns the distinct targets of the records in order of appearance.
+func Targets(records []Record) []string {
+	seen := make(map[string]struct{})
+
+	var targets []string
+	for i := range records {
+		t := records[i].Target
+		if _, ok := seen[t]; ok || t == "" {
+			continue
+		}
+		seen[t] = struct{}{}
+		targets = append(targets, t)
+	}
+
+	return targets
+}
@@ -0,0 +1,284 @@
+// Package secrets finds credentials in file contents with regular expression and entropy rules.
+package secrets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed builtin/rules.yaml
+var builtinFS embed.FS
+
+const (
+	// maxFileSize is the size above which files are not scanned.
+	maxFileSize = 8 << 20
+	// maxPreview is the maximum length of a preview line.
+	maxPreview = 160
+)
+
+// Rule describes a kind of secret.
+type Rule struct {
+	ID          string `yaml:"id"`
+	Description string `yaml:"description"`
+	Severity    string `yaml:"severity"`
+	// Regex matches the secret, Group selects the capture group that holds it, 0 is the whole match.
+	Regex string `yaml:"regex"`
+	Group int    `yaml:"group"`
+	// Entropy is the minimum Shannon entropy in bits per byte of the secret.
+	Entropy float64 `yaml:"entropy"`
+	// Path restricts the rule to files whose path matches.
+	Path string `yaml:"path"`
+	// Keywords skip files that contain none of them, they are compared case-insensitively.
+	Keywords []string `yaml:"keywords"`
+
+	regex *regexp.Regexp
+	path  *regexp.Regexp
+}
+
+type ruleFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Match is a secret found in a file.
+type Match struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description,omitempty"`
+	Severity    string `json:"severity,omitempty"`
+	Line        int    `json:"line"`
+	// Preview is the line of the match with the secret redacted.
+	Preview string `json:"preview"`
+	// Fingerprint identifies the secret without revealing it.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Scanner applies a set of rules.
+type Scanner struct {
+	rules []*Rule
+}
+
+// NewScanner compiles the rules, a rule replaces an earlier rule with the same id.
+func NewScanner(rules ...*Rule) (*Scanner, error) {
+	var s Scanner
+
+	index := make(map[string]int)
+	for _, r := range rules {
+		if err := r.compile(); err != nil {
+			return nil, err
+		}
+
+		if i, ok := index[r.ID]; ok {
+			s.rules[i] = r
+			continue
+		}
+		index[r.ID] = len(s.rules)
+		s.rules = append(s.rules, r)
+	}
+
+	return &s, nil
+}
+
+// Rules returns the rules of the scanner.
+func (s *Scanner) Rules() []*Rule {
+	return s.rules
+}
+
+// Builtin returns the rules that ship with githunt.
+func Builtin() ([]*Rule, error) {
+	b, err := builtinFS.ReadFile("builtin/rules.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("reading builtin rules. Error: %w", err)
+	}
+
+	return Parse(b)
+}
+
+// Parse decodes a YAML rules file.
+func Parse(b []byte) ([]*Rule, error) {
+	var f ruleFile
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("parsing rules. Error: %w", err)
+	}
+
+	for _, r := range f.Rules {
+		if err := r.compile(); err != nil {
+			return nil, err
+		}
+	}
+
+	return f.Rules, nil
+}
+
+// Load reads a YAML rules file.
+func Load(filename string) ([]*Rule, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules file %s. Error: %w", filename, err)
+	}
+
+	rules, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s. Error: %w", filename, err)
+	}
+
+	return rules, nil
+}
+
+func (r *Rule) compile() error {
+	if r.ID == "" {
+		return errors.New("parsing rule. Error: missing id")
+	}
+	if r.regex != nil {
+		return nil
+	}
+
+	var err error
+	if r.regex, err = regexp.Compile(r.Regex); err != nil || r.Regex == "" {
+		return fmt.Errorf("parsing rule %s. Error: invalid regex %q", r.ID, r.Regex)
+	}
+	if r.Group < 0 || r.Group > r.regex.NumSubexp() {
+		return fmt.Errorf("parsing rule %s. Error: invalid group %d", r.ID, r.Group)
+	}
+
+	if r.Path != "" {
+		if r.path, err = regexp.Compile(r.Path); err != nil {
+			return fmt.Errorf("parsing rule %s. Error: invalid path %q", r.ID, r.Path)
+		}
+	}
+
+	return nil
+}
+
+// Scan returns the secrets found in data, path is the path of the file or empty when it is unknown. Binary and
+// large files are skipped.
+func (s *Scanner) Scan(path string, data []byte) []Match {
+	if len(data) > maxFileSize || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil
+	}
+
+	var (
+		matches []Match
+		lower   []byte
+	)
+
+	for _, r := range s.rules {
+		if r.path != nil && (path == "" || !r.path.MatchString(path)) {
+			continue
+		}
+
+		if len(r.Keywords) > 0 {
+			if lower == nil {
+				lower = bytes.ToLower(data)
+			}
+			if !containsAny(lower, r.Keywords) {
+				continue
+			}
+		}
+
+		for _, loc := range r.regex.FindAllSubmatchIndex(data, -1) {
+			start, end := loc[2*r.Group], loc[2*r.Group+1]
+			if start < 0 {
+				continue
+			}
+
+			secret := data[start:end]
+			if r.Entropy > 0 && Entropy(secret) < r.Entropy {
+				continue
+			}
+
+			sum := sha256.Sum256(append([]byte(r.ID+"\x00"), secret...))
+
+			matches = append(matches, Match{
+				Rule:        r.ID,
+				Description: r.Description,
+				Severity:    r.Severity,
+				Line:        bytes.Count(data[:start], []byte("\n")) + 1,
+				Preview:     preview(data, start, end),
+				Fingerprint: hex.EncodeToString(sum[:16]),
+			})
+		}
+	}
+
+	return matches
+}
+
+func containsAny(lower []byte, keywords []string) bool {
+	for _, k := range keywords {
+		if bytes.Contains(lower, []byte(strings.ToLower(k))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Entropy returns the Shannon entropy of b in bits per byte.
+func Entropy(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+
+	var e float64
+	for _, n := range counts {
+		if n == 0 {
+			continue
+		}
+		p := float64(n) / float64(len(b))
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
+
+// Redact keeps the first characters of a secret and masks the rest.
+func Redact(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+
+	return secret[:4] + strings.Repeat("*", min(len(secret)-4, 16))
+}
+
+// preview returns the line that holds data[start:end] with the secret redacted.
+func preview(data []byte, start, end int) string {
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	lineEnd := len(data)
+	if i := bytes.IndexByte(data[start:], '\n'); i >= 0 {
+		lineEnd = start + i
+	}
+	// secrets that span lines such as private keys are cut at the end of their first line
+	end = min(end, lineEnd)
+
+	before := string(data[lineStart:start])
+	after := string(data[end:lineEnd])
+	redacted := Redact(string(data[start:end]))
+
+	// keep the context around the secret when the line is long
+	if keep := (maxPreview - len(redacted)) / 2; len(before)+len(redacted)+len(after) > maxPreview && keep > 0 {
+		if len(before) > keep {
+			before = "..." + before[len(before)-keep:]
+		}
+		if len(after) > keep {
+			after = after[:keep] + "..."
+		}
+	}
+
+	return strings.ToValidUTF8(strings.TrimSpace(before+redacted+after), "?")
+}
@@ -0,0 +1,621 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/templates"
+	"github.com/georlav/githunt/internal/utils"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+// Job states.
+const (
+	StatusQueued   = "queued"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusCanceled = "canceled"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// JobRequest is the body of a job submission.
+type JobRequest struct {
+	Targets []string   `json:"targets"`
+	Options JobOptions `json:"options"`
+}
+
+// JobOptions select the checks of a job, empty values use the server defaults.
+type JobOptions struct {
+	// Path overrides the path of the builtin git config check.
+	Path string `json:"path,omitempty"`
+	// Checkers limits the job to the named checkers.
+	Checkers []string `json:"checkers,omitempty"`
+}
+
+// Progress counts the scanned targets of a job.
+type Progress struct {
+	Total      int `json:"total"`
+	Scanned    int `json:"scanned"`
+	Vulnerable int `json:"vulnerable"`
+	Errors     int `json:"errors"`
+}
+
+// Job is a queued or executed scan.
+type Job struct {
+	ID       string     `json:"id"`
+	Status   string     `json:"status"`
+	Options  JobOptions `json:"options"`
+	Progress Progress   `json:"progress"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Targets  []string   `json:"targets,omitempty"`
+}
+
+type job struct {
+	Job
+
+	registry *checker.Registry
+	pending  []string
+	done     map[string]struct{}
+	// results is opened on the first result and closed when the job finishes
+	results *os.File
+	// updated is closed and replaced whenever results are appended or the status changes
+	updated chan struct{}
+}
+
+// Manager queues jobs and runs them on a single shared worker pool.
+type Manager struct {
+	dir       string
+	client    *client.Client
+	templates []*templates.Template
+	workers   int
+
+	mu    sync.Mutex
+	jobs  map[string]*job
+	queue []*job
+	wake  chan struct{}
+}
+
+func NewManager(dir string, c *client.Client, tpls []*templates.Template, workers int) (*Manager, error) {
+	m := Manager{
+		dir:       dir,
+		client:    c,
+		templates: tpls,
+		workers:   workers,
+		jobs:      make(map[string]*job),
+		wake:      make(chan struct{}, 1),
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating state directory %s. Error: %w", dir, err)
+	}
+
+	if err := m.restore(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Run executes queued jobs until the context is done.
+func (m *Manager) Run(ctx context.Context) {
+	targets := make(chan worker.Target)
+	defaults, _ := checker.NewRegistry()
+
+	resultCH := worker.Work(ctx, targets, defaults, m.workers)
+
+	go m.dispatch(ctx, targets)
+
+	for r := range resultCH {
+		// results of an interrupted scan are dropped, the targets are scanned again after a restart
+		if ctx.Err() != nil {
+			continue
+		}
+		m.collect(r)
+	}
+}
+
+// Submit validates and queues a job.
+func (m *Manager) Submit(req JobRequest) (Job, error) {
+	if len(req.Targets) == 0 {
+		return Job{}, errors.New("targets are required")
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	j := &job{
+		Job: Job{
+			ID:       id,
+			Status:   StatusQueued,
+			Options:  req.Options,
+			Progress: Progress{Total: len(req.Targets)},
+			Created:  time.Now().UTC(),
+			Targets:  req.Targets,
+		},
+		pending: req.Targets,
+		done:    make(map[string]struct{}),
+		updated: make(chan struct{}),
+	}
+
+	if j.registry, err = m.registry(req.Options); err != nil {
+		return Job{}, err
+	}
+
+	// results can be streamed while the job is queued
+	if err := os.WriteFile(m.path(id, ".jsonl"), nil, 0o644); err != nil { //nolint:gosec
+		return Job{}, fmt.Errorf("creating results file for job %s. Error: %w", id, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.persist(j); err != nil {
+		return Job{}, err
+	}
+
+	m.jobs[id] = j
+	m.enqueue(j)
+
+	return j.view(true), nil
+}
+
+// Get returns a job including its targets.
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	return j.view(true), nil
+}
+
+// List returns every job without its targets, oldest first.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j.view(false))
+	}
+
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].Created.Before(jobs[b].Created)
+	})
+
+	return jobs
+}
+
+// Cancel stops feeding the targets of a job to the pool.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	if j.Status == StatusDone || j.Status == StatusCanceled {
+		return j.view(false), ErrJobFinished
+	}
+
+	m.finish(j, StatusCanceled)
+
+	return j.view(false), nil
+}
+
+// Results streams the stored results of a job as JSON lines, with follow it waits for new results
+// until the job finishes or the context is done.
+func (m *Manager) Results(ctx context.Context, id string, w io.Writer, follow bool) error {
+	m.mu.Lock()
+	_, ok := m.jobs[id]
+	m.mu.Unlock()
+
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	f, err := os.Open(m.path(id, ".jsonl"))
+	if err != nil {
+		return fmt.Errorf("opening results of job %s. Error: %w", id, err)
+	}
+	defer f.Close()
+
+	var (
+		flusher, _ = w.(interface{ Flush() })
+		r          = bufio.NewReader(f)
+		partial    []byte
+	)
+
+	for {
+		// capture the state before reading so that no update is missed
+		m.mu.Lock()
+		j := m.jobs[id]
+		finished := j.Status == StatusDone || j.Status == StatusCanceled
+		updated := j.updated
+		m.mu.Unlock()
+
+		// only complete lines are written, a record may be appended while reading
+		for {
+			line, err := r.ReadBytes('\n')
+			partial = append(partial, line...)
+			if err != nil {
+				break
+			}
+
+			if _, err := w.Write(partial); err != nil {
+				return err
+			}
+			partial = partial[:0]
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if !follow || finished {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-updated:
+		}
+	}
+}
+
+func (m *Manager) dispatch(ctx context.Context, targets chan<- worker.Target) {
+	defer close(targets)
+
+	for {
+		j := m.next()
+		if j == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-m.wake:
+				continue
+			}
+		}
+
+		for {
+			m.mu.Lock()
+			if j.Status != StatusRunning || len(j.pending) == 0 {
+				m.mu.Unlock()
+				break
+			}
+			target := j.pending[0]
+			j.pending = j.pending[1:]
+			m.mu.Unlock()
+
+			u, err := utils.ParseTarget(target)
+
+			select {
+			case <-ctx.Done():
+				return
+			case targets <- worker.Target{URL: u, Error: err, Job: j.ID, Registry: j.registry}:
+			}
+		}
+	}
+}
+
+// next marks the oldest queued job as running.
+func (m *Manager) next() *job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.queue) > 0 {
+		j := m.queue[0]
+		m.queue = m.queue[1:]
+
+		if j.Status != StatusQueued {
+			continue
+		}
+
+		now := time.Now().UTC()
+		j.Status = StatusRunning
+		j.Started = &now
+		_ = m.persist(j)
+		j.notify()
+
+		// a restored job may have nothing left to scan
+		if len(j.pending) == 0 {
+			m.finish(j, StatusDone)
+			continue
+		}
+
+		return j
+	}
+
+	return nil
+}
+
+func (m *Manager) collect(r worker.Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[r.Job]
+	if !ok {
+		return
+	}
+
+	_ = m.save(j, results.FromResult(r, time.Now()))
+
+	j.Progress.Scanned++
+	if r.Vulnerable {
+		j.Progress.Vulnerable++
+	}
+	if r.Error != nil {
+		j.Progress.Errors++
+	}
+
+	if j.Status == StatusRunning && j.Progress.Scanned >= j.Progress.Total {
+		m.finish(j, StatusDone)
+		return
+	}
+
+	j.notify()
+}
+
+// finish must be called with the lock held.
+func (m *Manager) finish(j *job, status string) {
+	now := time.Now().UTC()
+	j.Status = status
+	j.Finished = &now
+	j.pending = nil
+	_ = m.persist(j)
+	_ = j.closeResults()
+	j.notify()
+}
+
+// save appends records to the results file of a job, it must be called with the lock held. Results of targets
+// that were in flight when the job was canceled do not keep the file open.
+func (m *Manager) save(j *job, records []results.Record) error {
+	if j.results == nil {
+		f, err := os.OpenFile(m.path(j.ID, ".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("opening results of job %s. Error: %w", j.ID, err)
+		}
+		j.results = f
+	}
+
+	enc := json.NewEncoder(j.results)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("saving results of job %s. Error: %w", j.ID, err)
+		}
+	}
+
+	if j.Status != StatusRunning {
+		return j.closeResults()
+	}
+
+	return nil
+}
+
+// enqueue must be called with the lock held.
+func (m *Manager) enqueue(j *job) {
+	m.queue = append(m.queue, j)
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// registry builds the checkers of a job from the loaded templates.
+func (m *Manager) registry(opts JobOptions) (*checker.Registry, error) {
+	selected := make(map[string]bool, len(opts.Checkers))
+	for _, name := range opts.Checkers {
+		if templates.Find(m.templates, name) == nil {
+			return nil, fmt.Errorf("unknown checker %s", name)
+		}
+		selected[name] = true
+	}
+
+	var checkers []checker.Checker
+	for _, t := range m.templates {
+		if len(selected) > 0 && !selected[t.ID] {
+			continue
+		}
+
+		// copy the template so that per job paths do not leak into other jobs
+		tpl := *t
+		if opts.Path != "" && t.ID == "git-config" {
+			if !strings.HasPrefix(opts.Path, "/") {
+				return nil, fmt.Errorf("invalid path %s", opts.Path)
+			}
+			tpl.Requests = append([]templates.Request(nil), t.Requests...)
+			tpl.SetPaths(opts.Path)
+		}
+
+		checkers = append(checkers, templates.NewChecker(&tpl, m.client))
+	}
+
+	return checker.NewRegistry(checkers...)
+}
+
+// persist writes the job metadata, it must be called with the lock held.
+func (m *Manager) persist(j *job) error {
+	b, err := json.Marshal(j.Job)
+	if err != nil {
+		return fmt.Errorf("encoding job %s. Error: %w", j.ID, err)
+	}
+
+	tmp := m.path(j.ID, ".json.tmp")
+	if err := os.WriteFile(tmp, b, 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("saving job %s. Error: %w", j.ID, err)
+	}
+
+	if err := os.Rename(tmp, m.path(j.ID, ".json")); err != nil {
+		return fmt.Errorf("saving job %s. Error: %w", j.ID, err)
+	}
+
+	return nil
+}
+
+// restore loads persisted jobs, unfinished jobs are queued again with their remaining targets.
+func (m *Manager) restore() error {
+	files, err := filepath.Glob(filepath.Join(m.dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("listing jobs in %s. Error: %w", m.dir, err)
+	}
+
+	var restored []*job
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("reading job %s. Error: %w", f, err)
+		}
+
+		j := &job{
+			done:    make(map[string]struct{}),
+			updated: make(chan struct{}),
+		}
+		if err := json.Unmarshal(b, &j.Job); err != nil {
+			return fmt.Errorf("decoding job %s. Error: %w", f, err)
+		}
+
+		if err := m.reload(j); err != nil {
+			return err
+		}
+
+		m.jobs[j.ID] = j
+		if j.Status == StatusQueued || j.Status == StatusRunning {
+			j.Status = StatusQueued
+			restored = append(restored, j)
+		}
+	}
+
+	sort.Slice(restored, func(a, b int) bool {
+		return restored[a].Created.Before(restored[b].Created)
+	})
+	for _, j := range restored {
+		m.enqueue(j)
+	}
+
+	return nil
+}
+
+// reload recomputes the progress of a job from its results file, the file is opened again once the job runs.
+func (m *Manager) reload(j *job) error {
+	f, err := os.OpenFile(m.path(j.ID, ".jsonl"), os.O_CREATE|os.O_RDONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening results of job %s. Error: %w", j.ID, err)
+	}
+
+	records, err := results.Read(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("reading results of job %s. Error: %w", j.ID, err)
+	}
+
+	vulnerable := make(map[string]struct{})
+	failed := make(map[string]struct{})
+	for i := range records {
+		if records[i].Target == "" {
+			continue
+		}
+		j.done[records[i].Target] = struct{}{}
+		if records[i].Vulnerable {
+			vulnerable[records[i].Target] = struct{}{}
+		}
+		if records[i].Error != "" {
+			failed[records[i].Target] = struct{}{}
+		}
+	}
+
+	j.Progress = Progress{
+		Total:      len(j.Targets),
+		Scanned:    len(j.done),
+		Vulnerable: len(vulnerable),
+		Errors:     len(failed),
+	}
+
+	for _, target := range j.Targets {
+		u, err := utils.ParseTarget(target)
+		if err == nil {
+			if _, ok := j.done[u.String()]; ok {
+				continue
+			}
+		}
+		j.pending = append(j.pending, target)
+	}
+
+	if j.registry, err = m.registry(j.Options); err != nil {
+		return fmt.Errorf("restoring job %s. Error: %w", j.ID, err)
+	}
+
+	return nil
+}
+
+func (m *Manager) path(id, ext string) string {
+	return filepath.Join(m.dir, id+ext)
+}
+
+// closeResults closes the results file of a job, it must be called with the lock held.
+func (j *job) closeResults() error {
+	if j.results == nil {
+		return nil
+	}
+
+	err := j.results.Close()
+	j.results = nil
+
+	if err != nil {
+		return fmt.Errorf("closing results of job %s. Error: %w", j.ID, err)
+	}
+
+	return nil
+}
+
+// notify wakes up result streams, it must be called with the lock held.
+func (j *job) notify() {
+	close(j.updated)
+	j.updated = make(chan struct{})
+}
+
+func (j *job) view(withTargets bool) Job {
+	v := j.Job
+	if !withTargets {
+		v.Targets = nil
+	}
+
+	return v
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating job id. Error: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
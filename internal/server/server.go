@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const maxRequestSize = 32 << 20
+
+// Server exposes the job manager over a JSON REST API.
+//
+//	POST   /jobs               submit a job
+//	GET    /jobs               list jobs
+//	GET    /jobs/{id}          job status and progress
+//	DELETE /jobs/{id}          cancel a job
+//	GET    /jobs/{id}/results  results as JSON lines, ?follow=true streams until the job finishes
+type Server struct {
+	manager *Manager
+	token   string
+}
+
+// NewServer creates the API handler, requests must carry the token as a bearer token when it is set.
+func NewServer(m *Manager, token string) *Server {
+	return &Server{
+		manager: m,
+		token:   token,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "jobs" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.submit(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.manager.List())
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		s.get(w, parts[1])
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		s.cancel(w, parts[1])
+	case len(parts) == 3 && parts[2] == "results" && r.Method == http.MethodGet:
+		s.results(w, r, parts[1])
+	case len(parts) <= 3:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := s.manager.Submit(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusCreated, job)
+}
+
+func (s *Server) get(w http.ResponseWriter, id string) {
+	job, err := s.manager.Get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) cancel(w http.ResponseWriter, id string) {
+	job, err := s.manager.Cancel(id)
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrJobFinished):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func (s *Server) results(w http.ResponseWriter, r *http.Request, id string) {
+	if _, err := s.manager.Get(id); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	follow := r.URL.Query().Get("follow") == "true"
+	_ = s.manager.Results(r.Context(), id, w, follow)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,289 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/server"
+	"github.com/georlav/githunt/internal/templates"
+)
+
+func newAPI(t *testing.T, dir, token string) (*httptest.Server, context.CancelFunc) {
+	t.Helper()
+
+	tpls, err := templates.Builtin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := server.NewManager(dir, client.NewClient(client.SetTimeout(5*time.Second)), tpls, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go m.Run(ctx)
+
+	api := httptest.NewServer(server.NewServer(m, token))
+	t.Cleanup(func() {
+		api.Close()
+		cancel()
+	})
+
+	return api, cancel
+}
+
+func newTarget(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.git/config" {
+			_, _ = w.Write([]byte("[core]\n\trepositoryformatversion = 0\n"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func do(t *testing.T, method, u, body string, v any) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func wait(t *testing.T, api, id, status string) server.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var job server.Job
+		do(t, http.MethodGet, api+"/jobs/"+id, "", &job)
+		if job.Status == status {
+			return job
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Job %s is %s, expected %s", id, job.Status, status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestServer_Job(t *testing.T) {
+	vulnerable := newTarget(t)
+	safe := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(safe.Close)
+
+	api, _ := newAPI(t, t.TempDir(), "secret")
+
+	var job server.Job
+	body := `{"targets": ["` + vulnerable.URL + `", "` + safe.URL + `"]}`
+	if status := do(t, http.MethodPost, api.URL+"/jobs", body, &job); status != http.StatusCreated {
+		t.Fatalf("Expected status 201 got %d", status)
+	}
+
+	job = wait(t, api.URL, job.ID, server.StatusDone)
+	if job.Progress.Total != 2 || job.Progress.Scanned != 2 || job.Progress.Vulnerable != 1 {
+		t.Fatalf("Unexpected progress %+v", job.Progress)
+	}
+
+	resp, err := http.Get(api.URL + "/jobs/" + job.ID + "/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 without token got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, api.URL+"/jobs/"+job.ID+"/results?follow=true", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	records, err := results.Read(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, r := range records {
+		if r.Vulnerable && r.Target == vulnerable.URL && r.Checker == "git-config" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Expected a git-config finding for %s got %+v", vulnerable.URL, records)
+	}
+
+	var jobs []server.Job
+	do(t, http.MethodGet, api.URL+"/jobs", "", &jobs)
+	if len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Fatalf("Unexpected jobs %+v", jobs)
+	}
+
+	if status := do(t, http.MethodDelete, api.URL+"/jobs/"+job.ID, "", nil); status != http.StatusConflict {
+		t.Fatalf("Expected status 409 when canceling a finished job got %d", status)
+	}
+}
+
+func TestServer_Submit(t *testing.T) {
+	api, _ := newAPI(t, t.TempDir(), "secret")
+
+	testCases := []struct {
+		body   string
+		status int
+	}{
+		{`{"targets": []}`, http.StatusBadRequest},
+		{`{"targets": ["example.com"], "options": {"checkers": ["unknown"]}}`, http.StatusBadRequest},
+		{`{"targets": ["example.com"], "options": {"path": "no-slash"}}`, http.StatusBadRequest},
+		{`{"targets": ["example.com"], "unknown": true}`, http.StatusBadRequest},
+		{`{"targets": ["http://127.0.0.1:1"], "options": {"path": "/.git/HEAD", "checkers": ["git-config"]}}`, http.StatusCreated},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.body, func(t *testing.T) {
+			if status := do(t, http.MethodPost, api.URL+"/jobs", tc.body, nil); status != tc.status {
+				t.Fatalf("Expected status %d got %d", tc.status, status)
+			}
+		})
+	}
+
+	if status := do(t, http.MethodGet, api.URL+"/jobs/unknown", "", nil); status != http.StatusNotFound {
+		t.Fatalf("Expected status 404 got %d", status)
+	}
+}
+
+func TestServer_Restore(t *testing.T) {
+	dir := t.TempDir()
+	block := make(chan struct{})
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(func() {
+		close(block)
+		slow.Close()
+	})
+
+	first, stop := newAPI(t, dir, "secret")
+
+	var queued, canceled server.Job
+	do(t, http.MethodPost, first.URL+"/jobs", `{"targets": ["`+slow.URL+`"]}`, &queued)
+	do(t, http.MethodPost, first.URL+"/jobs", `{"targets": ["`+slow.URL+`"]}`, &canceled)
+
+	if status := do(t, http.MethodDelete, first.URL+"/jobs/"+canceled.ID, "", nil); status != http.StatusOK {
+		t.Fatalf("Expected status 200 when canceling got %d", status)
+	}
+
+	// a second daemon on the same state directory picks up the unfinished job
+	stop()
+	target := newTarget(t)
+	second, _ := newAPI(t, dir, "secret")
+
+	wait(t, second.URL, canceled.ID, server.StatusCanceled)
+	job := wait(t, second.URL, queued.ID, server.StatusRunning)
+	if job.Progress.Total != 1 {
+		t.Fatalf("Unexpected progress %+v", job.Progress)
+	}
+
+	var next server.Job
+	do(t, http.MethodPost, second.URL+"/jobs", `{"targets": ["`+target.URL+`"]}`, &next)
+
+	// follow streams the results until the job is done
+	req, _ := http.NewRequest(http.MethodGet, second.URL+"/jobs/"+next.ID+"/results?follow=true", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := 0
+	for s := bufio.NewScanner(resp.Body); s.Scan(); {
+		lines++
+	}
+	if lines == 0 {
+		t.Fatal("Expected streamed results")
+	}
+}
+
+// openFiles counts the files of dir that the test process holds open.
+func openFiles(t *testing.T, dir string) int {
+	t.Helper()
+
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("listing open files is not supported")
+	}
+
+	n := 0
+	for _, fd := range fds {
+		if p, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name())); err == nil && strings.HasPrefix(p, dir) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestServer_ResultFiles(t *testing.T) {
+	dir := t.TempDir()
+	target := newTarget(t)
+
+	first, stop := newAPI(t, dir, "secret")
+
+	var job server.Job
+	do(t, http.MethodPost, first.URL+"/jobs", `{"targets": ["`+target.URL+`"]}`, &job)
+	wait(t, first.URL, job.ID, server.StatusDone)
+
+	if n := openFiles(t, dir); n != 0 {
+		t.Fatalf("Expected the results of a finished job to be closed got %d open files", n)
+	}
+
+	// restored jobs that are finished do not open their results
+	stop()
+	second, _ := newAPI(t, dir, "secret")
+	wait(t, second.URL, job.ID, server.StatusDone)
+
+	if n := openFiles(t, dir); n != 0 {
+		t.Fatalf("Expected no open results after a restore got %d open files", n)
+	}
+}
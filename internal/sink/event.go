@@ -0,0 +1,81 @@
+package sink
+
+import (
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/store"
+)
+
+// FromChange converts a change of a host to an event, hosts seen for the first time without findings are not
+// reported.
+func FromChange(scan string, c store.Change) (Event, bool) {
+	e := Event{
+		Target:   c.Host.Target,
+		Previous: c.Previous,
+		Status:   c.Host.Status,
+		Scan:     scan,
+		Time:     c.Host.Since,
+	}
+
+	switch c.Host.Status {
+	case store.StatusVulnerable:
+		e.Kind = EventVulnerable
+		e.Findings = findings(c.Records)
+		e.Remote = Remote(e.Findings)
+	case store.StatusFixed:
+		e.Kind = EventFixed
+	case store.StatusUnreachable:
+		if c.Previous == "" {
+			return e, false
+		}
+		e.Kind = EventUnreachable
+	case store.StatusClean:
+		if c.Previous == "" {
+			return e, false
+		}
+		e.Kind = EventReachable
+	default:
+		return e, false
+	}
+
+	return e, true
+}
+
+// FromRecords converts the records of a vulnerable target to an event, it reports false for targets without
+// findings.
+func FromRecords(records []results.Record) (Event, bool) {
+	found := findings(records)
+	if len(found) == 0 {
+		return Event{}, false
+	}
+
+	return Event{
+		Kind:     EventVulnerable,
+		Target:   found[0].Target,
+		Status:   store.StatusVulnerable,
+		Remote:   Remote(found),
+		Findings: found,
+		Time:     found[0].Time,
+	}, true
+}
+
+// Remote returns the first remote url extracted from the metadata of the records.
+func Remote(records []results.Record) string {
+	for i := range records {
+		if remote := records[i].Metadata["remote"]; remote != "" {
+			return remote
+		}
+	}
+
+	return ""
+}
+
+func findings(records []results.Record) []results.Record {
+	var found []results.Record
+	for i := range records {
+		if records[i].Vulnerable {
+			found = append(found, records[i])
+		}
+	}
+
+	return found
+}
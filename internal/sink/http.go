@@ -0,0 +1,160 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Chat services with incoming webhooks.
+const (
+	Slack   = "slack"
+	Discord = "discord"
+	Teams   = "teams"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the webhook body as sha256=<hex>.
+const SignatureHeader = "X-Githunt-Signature-256"
+
+// discordLimit is the maximum length of a Discord message.
+const discordLimit = 2000
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Webhook posts events as JSON, {"events": [...]} unless a template renders the body.
+type Webhook struct {
+	url      string
+	secret   string
+	template *Template
+}
+
+// NewWebhook creates a generic JSON webhook, the body is signed when secret is set.
+func NewWebhook(u, secret string, tmpl *Template) *Webhook {
+	return &Webhook{url: u, secret: secret, template: tmpl}
+}
+
+func (w *Webhook) Name() string {
+	return redact(w.url)
+}
+
+func (w *Webhook) Send(ctx context.Context, events []Event) error {
+	var (
+		body []byte
+		err  error
+	)
+
+	if w.template != nil {
+		var s string
+		s, err = w.template.Render(events)
+		body = []byte(s)
+	} else {
+		body, err = json.Marshal(struct {
+			Events []Event `json:"events"`
+		}{events})
+	}
+	if err != nil {
+		return permanent(err)
+	}
+
+	header := http.Header{}
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return post(ctx, w.url, body, header)
+}
+
+// Chat posts a rendered message in the payload format of Slack, Discord or Teams.
+type Chat struct {
+	kind     string
+	url      string
+	template *Template
+}
+
+// NewChat creates a chat sink, kind is one of Slack, Discord or Teams.
+func NewChat(kind, u string, tmpl *Template) *Chat {
+	return &Chat{kind: kind, url: u, template: textTemplate(tmpl)}
+}
+
+func (c *Chat) Name() string {
+	return c.kind
+}
+
+func (c *Chat) Send(ctx context.Context, events []Event) error {
+	text, err := c.template.Render(events)
+	if err != nil {
+		return permanent(err)
+	}
+
+	var payload any
+	switch c.kind {
+	case Discord:
+		if r := []rune(text); len(r) > discordLimit {
+			text = string(r[:discordLimit-1]) + "…"
+		}
+		payload = map[string]string{"content": text}
+	case Teams:
+		payload = map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  fmt.Sprintf("githunt: %d event(s)", len(events)),
+			"text":     text,
+		}
+	default:
+		payload = map[string]string{"text": text}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return permanent(err)
+	}
+
+	return post(ctx, c.url, body, nil)
+}
+
+// post sends a JSON body, client errors other than 408 and 429 are not retried.
+func post(ctx context.Context, u string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return permanent(err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "githunt")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// the error of the client includes the url which may hold a token
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return fmt.Errorf("sending events. Error: %w", uerr.Err)
+		}
+		return fmt.Errorf("sending events. Error: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500:
+		return fmt.Errorf("sending events. Error: unexpected status %d", resp.StatusCode)
+	default:
+		return permanent(fmt.Errorf("sending events. Error: unexpected status %d", resp.StatusCode))
+	}
+}
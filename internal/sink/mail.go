@@ -0,0 +1,99 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Mail sends events by email, STARTTLS is used when the server offers it.
+type Mail struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	to       []string
+	subject  string
+	template *Template
+}
+
+// NewMail creates an email sink for the smtp://[user:password@]host:port url.
+func NewMail(u *url.URL, from string, to []string, subject string, tmpl *Template) (*Mail, error) {
+	if from == "" || len(to) == 0 {
+		return nil, errors.New("smtp sink requires the from and to options")
+	}
+
+	if subject == "" {
+		subject = "githunt: {{len .Events}} event(s)"
+	}
+
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "25"
+	}
+
+	m := Mail{
+		addr:     host + ":" + port,
+		from:     from,
+		to:       to,
+		subject:  subject,
+		template: textTemplate(tmpl),
+	}
+
+	if u.User != nil {
+		password, _ := u.User.Password()
+		m.auth = smtp.PlainAuth("", u.User.Username(), password, host)
+	}
+
+	return &m, nil
+}
+
+func (m *Mail) Name() string {
+	return "smtp://" + m.addr
+}
+
+func (m *Mail) Send(ctx context.Context, events []Event) error {
+	body, err := m.template.Render(events)
+	if err != nil {
+		return permanent(err)
+	}
+
+	subject, err := ParseTemplate(m.subject)
+	if err != nil {
+		return permanent(err)
+	}
+	title, err := subject.Render(events)
+	if err != nil {
+		return permanent(err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(m.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.TrimSpace(strings.ReplaceAll(title, "\n", " ")))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	// net/smtp has no context support, the send is abandoned when the context is done
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, m.auth, m.from, m.to, msg.Bytes())
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("sending email. Error: %w", err)
+		}
+		return nil
+	}
+}
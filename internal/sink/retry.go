@@ -0,0 +1,166 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// permanentError is not retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Retry resends failed batches with exponential backoff.
+type Retry struct {
+	sink     Sink
+	attempts int
+	backoff  time.Duration
+}
+
+// NewRetry retries a sink up to retries times, the wait starts at backoff and doubles on every attempt.
+func NewRetry(s Sink, retries int, backoff time.Duration) *Retry {
+	return &Retry{sink: s, attempts: retries + 1, backoff: backoff}
+}
+
+func (r *Retry) Name() string {
+	return r.sink.Name()
+}
+
+func (r *Retry) Send(ctx context.Context, events []Event) error {
+	var (
+		err  error
+		wait = r.backoff
+	)
+
+	for attempt := 1; attempt <= r.attempts; attempt++ {
+		if err = r.sink.Send(ctx, events); err == nil {
+			return nil
+		}
+
+		var perm *permanentError
+		if errors.As(err, &perm) || attempt == r.attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+
+	return err
+}
+
+func (r *Retry) Close(ctx context.Context) error {
+	if c, ok := r.sink.(Closer); ok {
+		return c.Close(ctx)
+	}
+
+	return nil
+}
+
+// Batch collects events and sends them together once size events are buffered or interval elapsed since the
+// first buffered event. Errors of sends in the background are reported by the next Send or Close.
+type Batch struct {
+	sink     Sink
+	size     int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []Event
+	timer   *time.Timer
+	err     error
+	sending sync.WaitGroup
+}
+
+// NewBatch creates a batching sink.
+func NewBatch(s Sink, size int, interval time.Duration) *Batch {
+	return &Batch{sink: s, size: size, interval: interval}
+}
+
+func (b *Batch) Name() string {
+	return b.sink.Name()
+}
+
+func (b *Batch) Send(ctx context.Context, events []Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.err
+	b.err = nil
+
+	b.pending = append(b.pending, events...)
+	if len(b.pending) >= b.size {
+		b.flush(ctx)
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.flush(context.WithoutCancel(ctx))
+		})
+	}
+
+	return err
+}
+
+// Close sends the buffered events and waits for the sends in progress.
+func (b *Batch) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.flush(ctx)
+	b.mu.Unlock()
+
+	b.sending.Wait()
+
+	b.mu.Lock()
+	err := b.err
+	b.err = nil
+	b.mu.Unlock()
+
+	if c, ok := b.sink.(Closer); ok {
+		err = errors.Join(err, c.Close(ctx))
+	}
+
+	return err
+}
+
+// flush sends the buffered events in the background, it must be called with the lock held.
+func (b *Batch) flush(ctx context.Context) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if len(b.pending) == 0 {
+		return
+	}
+
+	events := b.pending
+	b.pending = nil
+
+	b.sending.Add(1)
+	go func() {
+		defer b.sending.Done()
+
+		if err := b.sink.Send(ctx, events); err != nil {
+			b.mu.Lock()
+			b.err = errors.Join(b.err, err)
+			b.mu.Unlock()
+		}
+	}()
+}
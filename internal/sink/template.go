@@ -0,0 +1,67 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+// defaultTemplate renders the message of chat and email sinks.
+const defaultTemplate = `{{range .Events}}[githunt] {{.Target}} is {{.Status}}{{if .Previous}} (was {{.Previous}}){{end}}
+{{- if .Remote}}
+  remote: {{.Remote}}{{end}}
+{{- range .Findings}}
+  {{.Checker}} {{.Severity}} {{.URL}}{{end}}
+{{end}}`
+
+// Template renders the body of a message from a batch of events.
+type Template struct {
+	tmpl *template.Template
+}
+
+// TemplateData is passed to message templates.
+type TemplateData struct {
+	Events []Event
+}
+
+// ParseTemplate parses a text/template message body.
+func ParseTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing message template. Error: %w", err)
+	}
+
+	return &Template{tmpl: tmpl}, nil
+}
+
+// LoadTemplate parses a message template file.
+func LoadTemplate(path string) (*Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading message template %s. Error: %w", path, err)
+	}
+
+	return ParseTemplate(string(b))
+}
+
+// Render executes the template with the events.
+func (t *Template) Render(events []Event) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, TemplateData{Events: events}); err != nil {
+		return "", fmt.Errorf("rendering message template. Error: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// textTemplate returns the given template or the default one.
+func textTemplate(t *Template) *Template {
+	if t != nil {
+		return t
+	}
+
+	tmpl, _ := ParseTemplate(defaultTemplate)
+
+	return tmpl
+}
@@ -0,0 +1,491 @@
+// Package store keeps the results of every scan in an embedded bbolt database together with the state of each
+// host across scans.
+package store
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/georlav/githunt/internal/results"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Host states.
+const (
+	// StatusVulnerable hosts had at least one finding in their last scan.
+	StatusVulnerable = "vulnerable"
+	// StatusFixed hosts were vulnerable in the past and had no finding in their last scan.
+	StatusFixed = "fixed"
+	// StatusClean hosts were never found vulnerable.
+	StatusClean = "clean"
+	// StatusUnreachable hosts could not be checked in their last scan.
+	StatusUnreachable = "unreachable"
+)
+
+var (
+	ErrScanNotFound = errors.New("scan not found")
+	ErrNoScans      = errors.New("no scans stored")
+)
+
+var (
+	scansBucket = []byte("scans")
+	hostsBucket = []byte("hosts")
+	scanKey     = []byte("scan")
+	resultsKey  = []byte("results")
+)
+
+// flushSize is the number of records written in a single transaction.
+const flushSize = 500
+
+// Scan describes a stored scan.
+type Scan struct {
+	ID       string     `json:"id"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Targets  int        `json:"targets"`
+}
+
+// Host is the state of a target across scans.
+type Host struct {
+	Target string `json:"target"`
+	Status string `json:"status"`
+	// Since is the time of the scan that changed the host to its current status.
+	Since time.Time `json:"since"`
+	// ChangedIn is the ID of the scan that changed the host to its current status.
+	ChangedIn string `json:"changed_in"`
+	// FirstSeen and LastSeen are the first and last times the host was found vulnerable.
+	FirstSeen   *time.Time        `json:"first_seen,omitempty"`
+	LastSeen    *time.Time        `json:"last_seen,omitempty"`
+	LastScanned time.Time         `json:"last_scanned"`
+	LastScan    string            `json:"last_scan"`
+	Checkers    []string          `json:"checkers,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// Query selects hosts, zero fields match every host.
+type Query struct {
+	Status string
+	// Since and Until bound the time the hosts changed to their current status.
+	Since time.Time
+	Until time.Time
+	// ChangedIn selects the hosts that changed status in a scan.
+	ChangedIn string
+}
+
+// Store is a result database.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens or creates a result database.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening store %s. Error: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{scansBucket, hostsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing store %s. Error: %w", path, err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Begin starts recording a scan.
+func (s *Store) Begin(started time.Time) (*Session, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("generating scan id. Error: %w", err)
+	}
+
+	// ids sort by start time
+	scan := Scan{
+		ID:      started.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix),
+		Started: started.UTC(),
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(scansBucket).CreateBucket([]byte(scan.ID))
+		if err != nil {
+			return err
+		}
+		if _, err := b.CreateBucket(resultsKey); err != nil {
+			return err
+		}
+		return putJSON(b, scanKey, scan)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating scan %s. Error: %w", scan.ID, err)
+	}
+
+	return &Session{store: s, scan: scan}, nil
+}
+
+// Scans returns every stored scan, oldest first.
+func (s *Store) Scans() ([]Scan, error) {
+	var scans []Scan
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(scansBucket).ForEachBucket(func(k []byte) error {
+			var scan Scan
+			if err := getJSON(tx.Bucket(scansBucket).Bucket(k), scanKey, &scan); err != nil {
+				return err
+			}
+			scans = append(scans, scan)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing scans. Error: %w", err)
+	}
+
+	return scans, nil
+}
+
+// LastScan returns the most recent scan.
+func (s *Store) LastScan() (Scan, error) {
+	scans, err := s.Scans()
+	if err != nil {
+		return Scan{}, err
+	}
+
+	if len(scans) == 0 {
+		return Scan{}, ErrNoScans
+	}
+
+	return scans[len(scans)-1], nil
+}
+
+// Records returns the results of a scan.
+func (s *Store) Records(scanID string) ([]results.Record, error) {
+	var records []results.Record
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(scansBucket).Bucket([]byte(scanID))
+		if b == nil {
+			return ErrScanNotFound
+		}
+
+		return b.Bucket(resultsKey).ForEach(func(_, v []byte) error {
+			var target []results.Record
+			if err := json.Unmarshal(v, &target); err != nil {
+				return err
+			}
+			records = append(records, target...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading results of scan %s. Error: %w", scanID, err)
+	}
+
+	return records, nil
+}
+
+// Hosts returns the hosts matching the query ordered by target.
+func (s *Store) Hosts(q Query) ([]Host, error) {
+	var hosts []Host
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(hostsBucket).ForEach(func(_, v []byte) error {
+			var h Host
+			if err := json.Unmarshal(v, &h); err != nil {
+				return err
+			}
+			if q.match(h) {
+				hosts = append(hosts, h)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying hosts. Error: %w", err)
+	}
+
+	return hosts, nil
+}
+
+func (q Query) match(h Host) bool {
+	switch {
+	case q.Status != "" && h.Status != q.Status:
+		return false
+	case q.ChangedIn != "" && h.ChangedIn != q.ChangedIn:
+		return false
+	case !q.Since.IsZero() && h.Since.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !h.Since.Before(q.Until):
+		return false
+	}
+
+	return true
+}
+
+// Session records the results of a running scan.
+type Session struct {
+	store    *Store
+	scan     Scan
+	pending  []results.Record
+	onChange func(Change)
+	changes  []Change
+}
+
+// Change is reported when a host moves to another status.
+type Change struct {
+	// Previous is empty for hosts seen for the first time.
+	Previous string
+	Host     Host
+	Records  []results.Record
+}
+
+// ID returns the scan ID.
+func (s *Session) ID() string {
+	return s.scan.ID
+}
+
+// OnChange registers a function that is called for every host that changes status once the change is written.
+func (s *Session) OnChange(fn func(Change)) {
+	s.onChange = fn
+}
+
+// Add buffers the records of a target, they are written in batches. The records of a single call are
+// always written together.
+func (s *Session) Add(records ...results.Record) error {
+	if len(s.pending) >= flushSize {
+		if err := s.Flush(); err != nil {
+			return err
+		}
+	}
+
+	s.pending = append(s.pending, records...)
+
+	return nil
+}
+
+// Flush writes the buffered records and updates the state of their hosts.
+func (s *Session) Flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	// group the records by target keeping their order
+	var (
+		targets []string
+		grouped = make(map[string][]results.Record)
+		invalid []results.Record
+	)
+	for _, r := range s.pending {
+		// targets that could not be parsed have no key
+		if r.Target == "" {
+			invalid = append(invalid, r)
+			continue
+		}
+		if _, ok := grouped[r.Target]; !ok {
+			targets = append(targets, r.Target)
+		}
+		grouped[r.Target] = append(grouped[r.Target], r)
+	}
+
+	// the scan is counted on a copy so that a rolled back transaction does not change it
+	scan := s.scan
+
+	err := s.store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(scansBucket).Bucket([]byte(scan.ID))
+		if b == nil {
+			return ErrScanNotFound
+		}
+
+		for _, target := range targets {
+			// the records of a target may be split over several flushes
+			var records []results.Record
+			if v := b.Bucket(resultsKey).Get([]byte(target)); v != nil {
+				if err := json.Unmarshal(v, &records); err != nil {
+					return err
+				}
+			} else {
+				scan.Targets++
+			}
+			records = append(records, grouped[target]...)
+
+			if err := putJSON(b.Bucket(resultsKey), []byte(target), records); err != nil {
+				return err
+			}
+
+			if err := s.update(tx.Bucket(hostsBucket), target, records); err != nil {
+				return err
+			}
+		}
+
+		// every record without a target is a line of its own, they are kept in order under sequence numbers
+		for _, r := range invalid {
+			seq, err := b.Bucket(resultsKey).NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := putJSON(b.Bucket(resultsKey), invalidKey(seq), []results.Record{r}); err != nil {
+				return err
+			}
+			scan.Targets++
+		}
+
+		return putJSON(b, scanKey, scan)
+	})
+	changes := s.changes
+	s.changes = nil
+
+	if err != nil {
+		return fmt.Errorf("saving results of scan %s. Error: %w", s.scan.ID, err)
+	}
+
+	s.scan = scan
+	s.pending = s.pending[:0]
+
+	if s.onChange != nil {
+		for i := range changes {
+			s.onChange(changes[i])
+		}
+	}
+
+	return nil
+}
+
+// Close flushes the buffered records and marks the scan as finished.
+func (s *Session) Close() error {
+	now := time.Now().UTC()
+	s.scan.Finished = &now
+
+	if len(s.pending) > 0 {
+		return s.Flush()
+	}
+
+	err := s.store.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(scansBucket).Bucket([]byte(s.scan.ID)), scanKey, s.scan)
+	})
+	if err != nil {
+		return fmt.Errorf("finishing scan %s. Error: %w", s.scan.ID, err)
+	}
+
+	return nil
+}
+
+// update moves a host to the status of its latest records.
+func (s *Session) update(b *bolt.Bucket, target string, records []results.Record) error {
+	h := Host{Target: target}
+	if v := b.Get([]byte(target)); v != nil {
+		if err := json.Unmarshal(v, &h); err != nil {
+			return err
+		}
+	}
+
+	scanned := records[0].Time
+	for i := range records {
+		if records[i].Time.After(scanned) {
+			scanned = records[i].Time
+		}
+	}
+
+	// results of an older scan imported late do not overwrite the current state
+	if scanned.Before(h.LastScanned) {
+		return nil
+	}
+
+	var (
+		status   = StatusClean
+		metadata = make(map[string]string)
+	)
+
+	h.Checkers, h.Error = nil, ""
+	for i := range records {
+		r := records[i]
+		switch {
+		case r.Vulnerable:
+			status = StatusVulnerable
+			h.Checkers = append(h.Checkers, r.Checker)
+			for k, v := range r.Metadata {
+				metadata[k] = v
+			}
+		case r.Error != "":
+			h.Error = r.Error
+		}
+	}
+	sort.Strings(h.Checkers)
+
+	// fixed hosts keep the metadata of their last exposure
+	if status == StatusVulnerable {
+		h.Metadata = metadata
+	}
+
+	switch {
+	case status == StatusVulnerable:
+		if h.FirstSeen == nil {
+			h.FirstSeen = &scanned
+		}
+		h.LastSeen = &scanned
+	case h.Error != "":
+		status = StatusUnreachable
+	case h.FirstSeen != nil:
+		status = StatusFixed
+	}
+
+	previous := h.Status
+	if h.Status != status {
+		h.Status = status
+		h.Since = scanned
+		h.ChangedIn = s.scan.ID
+	}
+	h.LastScanned = scanned
+	h.LastScan = s.scan.ID
+
+	if previous != status {
+		s.changes = append(s.changes, Change{Previous: previous, Host: h, Records: records})
+	}
+
+	return putJSON(b, []byte(target), h)
+}
+
+// invalidKey returns the key of a record whose target could not be parsed, the leading zero byte keeps it apart
+// from the targets.
+func invalidKey(seq uint64) []byte {
+	key := make([]byte, 9)
+	binary.BigEndian.PutUint64(key[1:], seq)
+
+	return key
+}
+
+func putJSON(b *bolt.Bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(key, data)
+}
+
+func getJSON(b *bolt.Bucket, key []byte, v any) error {
+	data := b.Get(key)
+	if data == nil {
+		return fmt.Errorf("missing key %s", key)
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,186 @@
+package store_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/store"
+)
+
+func record(target string, vulnerable bool, err string, at time.Time) results.Record {
+	r := results.Record{Target: target, Vulnerable: vulnerable, Error: err, Time: at}
+	if vulnerable {
+		r.Checker = "git-config"
+		r.URL = target + "/.git/config"
+		r.Metadata = map[string]string{"remote": "git@example.com:" + target}
+	}
+
+	return r
+}
+
+func scan(t *testing.T, st *store.Store, at time.Time, records ...results.Record) string {
+	t.Helper()
+
+	session, err := st.Begin(at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range records {
+		if err := session.Add(records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := session.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return session.ID()
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "githunt.db")
+
+	st, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := st.LastScan(); !errors.Is(err, store.ErrNoScans) {
+		t.Fatalf("Expected ErrNoScans got %v", err)
+	}
+
+	week1 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	week2 := week1.AddDate(0, 0, 7)
+
+	first := scan(t, st, week1,
+		record("https://a.example.com", true, "", week1),
+		record("https://b.example.com", false, "", week1),
+		record("https://c.example.com", true, "", week1),
+		record("", false, "parsing %zz", week1),
+	)
+	second := scan(t, st, week2,
+		record("https://a.example.com", false, "", week2),
+		record("https://b.example.com", true, "", week2),
+		record("https://c.example.com", false, "timeout", week2),
+	)
+
+	// the database survives a restart
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if st, err = store.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		st.Close()
+	})
+
+	scans, err := st.Scans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 2 || scans[0].ID != first || scans[1].ID != second || scans[0].Targets != 4 || scans[1].Finished == nil {
+		t.Fatalf("Unexpected scans %+v", scans)
+	}
+
+	hosts, err := st.Hosts(store.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"https://a.example.com": store.StatusFixed,
+		"https://b.example.com": store.StatusVulnerable,
+		"https://c.example.com": store.StatusUnreachable,
+	}
+	if len(hosts) != len(expected) {
+		t.Fatalf("Expected %d hosts got %d", len(expected), len(hosts))
+	}
+	for _, h := range hosts {
+		if h.Status != expected[h.Target] {
+			t.Fatalf("Expected %s to be %s got %s", h.Target, expected[h.Target], h.Status)
+		}
+	}
+
+	a := hosts[0]
+	if !a.FirstSeen.Equal(week1) || !a.LastSeen.Equal(week1) || !a.Since.Equal(week2) || a.ChangedIn != second {
+		t.Fatalf("Unexpected history %+v", a)
+	}
+	if a.Metadata["remote"] != "git@example.com:https://a.example.com" {
+		t.Fatalf("Expected fixed hosts to keep their metadata got %v", a.Metadata)
+	}
+
+	testCases := []struct {
+		name    string
+		query   store.Query
+		targets int
+	}{
+		{"vulnerable", store.Query{Status: store.StatusVulnerable}, 1},
+		{"fixed since last scan", store.Query{Status: store.StatusFixed, ChangedIn: second}, 1},
+		{"changed in first scan", store.Query{ChangedIn: first}, 0},
+		{"since", store.Query{Since: week2}, 3},
+		{"until", store.Query{Until: week2}, 0},
+		{"vulnerable since", store.Query{Status: store.StatusVulnerable, Since: week2.Add(time.Hour)}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hosts, err := st.Hosts(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(hosts) != tc.targets {
+				t.Fatalf("Expected %d hosts got %+v", tc.targets, hosts)
+			}
+		})
+	}
+
+	records, err := st.Records(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 records got %d", len(records))
+	}
+
+	// targets that could not be parsed are kept with their error
+	if r := records[0]; r.Target != "" || r.Error != "parsing %zz" {
+		t.Fatalf("Expected the parse error to be stored got %+v", r)
+	}
+
+	if _, err := st.Records("unknown"); !errors.Is(err, store.ErrScanNotFound) {
+		t.Fatalf("Expected ErrScanNotFound got %v", err)
+	}
+}
+
+func TestStore_OlderScan(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "githunt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		st.Close()
+	})
+
+	now := time.Now().UTC()
+	scan(t, st, now, record("https://a.example.com", false, "", now))
+
+	// importing an older scan keeps the current state
+	old := now.AddDate(0, -1, 0)
+	scan(t, st, old, record("https://a.example.com", true, "", old))
+
+	hosts, err := st.Hosts(store.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hosts) != 1 || hosts[0].Status != store.StatusClean {
+		t.Fatalf("Unexpected hosts %+v", hosts)
+	}
+}
@@ -0,0 +1,35 @@
+package templates
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed builtin/*.yaml
+var builtinFS embed.FS
+
+// Builtin returns the templates that ship with githunt.
+func Builtin() ([]*Template, error) {
+	files, err := fs.Glob(builtinFS, "builtin/*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("listing builtin templates. Error: %w", err)
+	}
+
+	templates := make([]*Template, 0, len(files))
+	for _, f := range files {
+		b, err := builtinFS.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading builtin template %s. Error: %w", f, err)
+		}
+
+		t, err := Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("parsing builtin template %s. Error: %w", f, err)
+		}
+
+		templates = append(templates, t)
+	}
+
+	return templates, nil
+}
@@ -0,0 +1,93 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+)
+
+// Checker runs a template through the shared http client.
+type Checker struct {
+	template *Template
+	client   *client.Client
+}
+
+func NewChecker(t *Template, c *client.Client) *Checker {
+	return &Checker{
+		template: t,
+		client:   c,
+	}
+}
+
+// NewCheckers wraps every template in a checker.
+func NewCheckers(templates []*Template, c *client.Client) []checker.Checker {
+	checkers := make([]checker.Checker, 0, len(templates))
+	for i := range templates {
+		checkers = append(checkers, NewChecker(templates[i], c))
+	}
+
+	return checkers
+}
+
+func (c *Checker) Name() string {
+	return c.template.ID
+}
+
+func (c *Checker) Paths() []string {
+	var paths []string
+	for i := range c.template.Requests {
+		paths = append(paths, c.template.Requests[i].Paths...)
+	}
+
+	return paths
+}
+
+func (c *Checker) Check(ctx context.Context, target *url.URL) ([]checker.Finding, error) {
+	var (
+		findings []checker.Finding
+		errs     []error
+	)
+
+	for i := range c.template.Requests {
+		r := &c.template.Requests[i]
+
+		for _, method := range r.Methods {
+			for _, path := range r.Paths {
+				u := checker.JoinPath(target, path)
+
+				resp, err := c.client.Do(ctx, method, u, r.header)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s %s. Error: %w", method, path, err))
+					continue
+				}
+
+				if !r.Match(resp) {
+					continue
+				}
+
+				findings = append(findings, checker.Finding{
+					Checker:  c.template.ID,
+					Severity: c.template.Severity,
+					URL:      u,
+					Metadata: r.Extract(resp),
+					Evidence: evidence(resp),
+				})
+			}
+		}
+	}
+
+	return findings, errors.Join(errs...)
+}
+
+// evidence returns the captured exchange of a response.
+func evidence(resp *client.Response) []*client.Exchange {
+	if resp.Exchange == nil {
+		return nil
+	}
+
+	return []*client.Exchange{resp.Exchange}
+}
@@ -0,0 +1,117 @@
+package templates
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/georlav/githunt/internal/client"
+)
+
+// Match reports whether the response satisfies the request matchers.
+func (r *Request) Match(resp *client.Response) bool {
+	for i := range r.Matchers {
+		matched := r.Matchers[i].Match(resp)
+
+		switch {
+		case r.MatchersCondition == conditionOr && matched:
+			return true
+		case r.MatchersCondition == conditionAnd && !matched:
+			return false
+		}
+	}
+
+	return r.MatchersCondition == conditionAnd
+}
+
+// Extract returns the values of every extractor that matched the response.
+func (r *Request) Extract(resp *client.Response) map[string]string {
+	values := make(map[string]string)
+
+	for i := range r.Extractors {
+		if v, ok := r.Extractors[i].Extract(resp); ok {
+			values[r.Extractors[i].Name] = v
+		}
+	}
+
+	return values
+}
+
+// Match reports whether the response satisfies the matcher, negative matchers invert the result.
+func (m *Matcher) Match(resp *client.Response) bool {
+	return m.match(resp) != m.Negative
+}
+
+func (m *Matcher) match(resp *client.Response) bool {
+	part := responsePart(resp, m.Part)
+
+	switch m.Type {
+	case "status":
+		return matchAny(len(m.Status), m.Condition, func(i int) bool {
+			return resp.StatusCode == m.Status[i]
+		})
+	case "word":
+		if m.CaseInsensitive {
+			part = bytes.ToLower(part)
+		}
+		return matchAny(len(m.Words), m.Condition, func(i int) bool {
+			w := m.Words[i]
+			if m.CaseInsensitive {
+				w = strings.ToLower(w)
+			}
+			return bytes.Contains(part, []byte(w))
+		})
+	case "regex":
+		return matchAny(len(m.regexps), m.Condition, func(i int) bool {
+			return m.regexps[i].Match(part)
+		})
+	case "binary":
+		return matchAny(len(m.magic), m.Condition, func(i int) bool {
+			return bytes.HasPrefix(resp.Body, m.magic[i])
+		})
+	}
+
+	return false
+}
+
+// Extract returns the first value matched by the extractor.
+func (e *Extractor) Extract(resp *client.Response) (string, bool) {
+	part := responsePart(resp, e.Part)
+
+	for _, re := range e.regexps {
+		if m := re.FindSubmatch(part); m != nil {
+			return string(bytes.TrimSpace(m[e.Group])), true
+		}
+	}
+
+	return "", false
+}
+
+// matchAny evaluates n checks using an and/or condition.
+func matchAny(n int, condition string, check func(i int) bool) bool {
+	for i := 0; i < n; i++ {
+		matched := check(i)
+
+		switch {
+		case condition == conditionOr && matched:
+			return true
+		case condition == conditionAnd && !matched:
+			return false
+		}
+	}
+
+	return condition == conditionAnd
+}
+
+func responsePart(resp *client.Response, part string) []byte {
+	var b bytes.Buffer
+
+	if part == partHeader || part == partAll {
+		_ = resp.Header.Write(&b)
+	}
+
+	if part == partBody || part == partAll {
+		b.Write(resp.Body)
+	}
+
+	return b.Bytes()
+}
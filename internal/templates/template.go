@@ -0,0 +1,287 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template is a declarative check definition loaded from YAML.
+type Template struct {
+	ID       string    `yaml:"id"`
+	Name     string    `yaml:"name"`
+	Severity string    `yaml:"severity"`
+	Requests []Request `yaml:"requests"`
+}
+
+// Request describes the requests sent for a template and how their responses are matched.
+type Request struct {
+	Methods           []string          `yaml:"methods"`
+	Paths             []string          `yaml:"paths"`
+	Headers           map[string]string `yaml:"headers"`
+	MatchersCondition string            `yaml:"matchers-condition"`
+	Matchers          []Matcher         `yaml:"matchers"`
+	Extractors        []Extractor       `yaml:"extractors"`
+
+	header http.Header
+}
+
+// Matcher matches a single response part.
+type Matcher struct {
+	Type            string   `yaml:"type"`
+	Part            string   `yaml:"part"`
+	Condition       string   `yaml:"condition"`
+	Negative        bool     `yaml:"negative"`
+	CaseInsensitive bool     `yaml:"case-insensitive"`
+	Status          []int    `yaml:"status"`
+	Words           []string `yaml:"words"`
+	Regex           []string `yaml:"regex"`
+	Binary          []string `yaml:"binary"`
+
+	regexps []*regexp.Regexp
+	magic   [][]byte
+}
+
+// Extractor extracts named values from a matched response.
+type Extractor struct {
+	Type  string   `yaml:"type"`
+	Name  string   `yaml:"name"`
+	Part  string   `yaml:"part"`
+	Regex []string `yaml:"regex"`
+	Group int      `yaml:"group"`
+
+	regexps []*regexp.Regexp
+}
+
+const (
+	conditionAnd = "and"
+	conditionOr  = "or"
+
+	partBody   = "body"
+	partHeader = "header"
+	partAll    = "all"
+)
+
+// Parse decodes and validates a single template.
+func Parse(b []byte) (*Template, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	var t Template
+	if err := dec.Decode(&t); err != nil {
+		return nil, fmt.Errorf("decoding template. Error: %w", err)
+	}
+
+	if err := t.compile(); err != nil {
+		return nil, fmt.Errorf("validating template %s. Error: %w", t.ID, err)
+	}
+
+	return &t, nil
+}
+
+// Load reads templates from a YAML file or from every YAML file in a directory.
+func Load(path string) ([]*Template, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading templates %s. Error: %w", path, err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, fmt.Errorf("listing templates %s. Error: %w", path, err)
+			}
+			files = append(files, matches...)
+		}
+	}
+
+	templates := make([]*Template, 0, len(files))
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading template %s. Error: %w", f, err)
+		}
+
+		t, err := Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template %s. Error: %w", f, err)
+		}
+
+		templates = append(templates, t)
+	}
+
+	return templates, nil
+}
+
+// Find returns the template with the given id or nil.
+func Find(templates []*Template, id string) *Template {
+	for i := range templates {
+		if templates[i].ID == id {
+			return templates[i]
+		}
+	}
+
+	return nil
+}
+
+// SetPaths replaces the paths of every request in the template.
+func (t *Template) SetPaths(paths ...string) {
+	for i := range t.Requests {
+		t.Requests[i].Paths = paths
+	}
+}
+
+//nolint:gocognit,cyclop
+func (t *Template) compile() error {
+	if t.ID == "" {
+		return errors.New("missing id")
+	}
+
+	if len(t.Requests) == 0 {
+		return errors.New("missing requests")
+	}
+
+	for i := range t.Requests {
+		r := &t.Requests[i]
+
+		if len(r.Paths) == 0 {
+			return fmt.Errorf("request %d: missing paths", i)
+		}
+
+		if len(r.Methods) == 0 {
+			r.Methods = []string{http.MethodGet}
+		}
+
+		if r.MatchersCondition == "" {
+			r.MatchersCondition = conditionAnd
+		}
+		if r.MatchersCondition != conditionAnd && r.MatchersCondition != conditionOr {
+			return fmt.Errorf("request %d: invalid matchers-condition %q", i, r.MatchersCondition)
+		}
+
+		if len(r.Matchers) == 0 {
+			return fmt.Errorf("request %d: missing matchers", i)
+		}
+
+		r.header = make(http.Header, len(r.Headers))
+		for k, v := range r.Headers {
+			r.header.Set(k, v)
+		}
+
+		for j := range r.Matchers {
+			if err := r.Matchers[j].compile(); err != nil {
+				return fmt.Errorf("request %d matcher %d: %w", i, j, err)
+			}
+		}
+
+		for j := range r.Extractors {
+			if err := r.Extractors[j].compile(); err != nil {
+				return fmt.Errorf("request %d extractor %d: %w", i, j, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+//nolint:cyclop
+func (m *Matcher) compile() error {
+	if m.Part == "" {
+		m.Part = partBody
+	}
+	if m.Part != partBody && m.Part != partHeader && m.Part != partAll {
+		return fmt.Errorf("invalid part %q", m.Part)
+	}
+
+	if m.Condition == "" {
+		m.Condition = conditionOr
+	}
+	if m.Condition != conditionAnd && m.Condition != conditionOr {
+		return fmt.Errorf("invalid condition %q", m.Condition)
+	}
+
+	switch m.Type {
+	case "status":
+		if len(m.Status) == 0 {
+			return errors.New("missing status")
+		}
+	case "word":
+		if len(m.Words) == 0 {
+			return errors.New("missing words")
+		}
+	case "regex":
+		if len(m.Regex) == 0 {
+			return errors.New("missing regex")
+		}
+		for _, expr := range m.Regex {
+			if m.CaseInsensitive {
+				expr = "(?i)" + expr
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("compiling regex %q. Error: %w", expr, err)
+			}
+			m.regexps = append(m.regexps, re)
+		}
+	case "binary":
+		if len(m.Binary) == 0 {
+			return errors.New("missing binary")
+		}
+		for _, h := range m.Binary {
+			b, err := hex.DecodeString(strings.ReplaceAll(h, " ", ""))
+			if err != nil {
+				return fmt.Errorf("decoding binary %q. Error: %w", h, err)
+			}
+			m.magic = append(m.magic, b)
+		}
+	default:
+		return fmt.Errorf("invalid type %q", m.Type)
+	}
+
+	return nil
+}
+
+func (e *Extractor) compile() error {
+	if e.Name == "" {
+		return errors.New("missing name")
+	}
+
+	if e.Part == "" {
+		e.Part = partBody
+	}
+	if e.Part != partBody && e.Part != partHeader && e.Part != partAll {
+		return fmt.Errorf("invalid part %q", e.Part)
+	}
+
+	if e.Type != "regex" {
+		return fmt.Errorf("invalid type %q", e.Type)
+	}
+
+	if len(e.Regex) == 0 {
+		return errors.New("missing regex")
+	}
+
+	for _, expr := range e.Regex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("compiling regex %q. Error: %w", expr, err)
+		}
+		if e.Group < 0 || e.Group > re.NumSubexp() {
+			return fmt.Errorf("regex %q has no group %d", expr, e.Group)
+		}
+		e.regexps = append(e.regexps, re)
+	}
+
+	return nil
+}
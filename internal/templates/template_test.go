@@ -0,0 +1,164 @@
+package templates_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/templates"
+)
+
+func TestParse(t *testing.T) {
+	testsCases := []struct {
+		description string
+		input       string
+		valid       bool
+	}{
+		{
+			description: "Should parse a valid template",
+			input:       "id: a\nrequests:\n  - paths: [/a]\n    matchers:\n      - type: status\n        status: [200]\n",
+			valid:       true,
+		},
+		{
+			description: "Should reject unknown keys",
+			input:       "id: a\nfoo: bar\nrequests:\n  - paths: [/a]\n    matchers:\n      - type: status\n        status: [200]\n",
+		},
+		{
+			description: "Should reject invalid regex",
+			input:       "id: a\nrequests:\n  - paths: [/a]\n    matchers:\n      - type: regex\n        regex: ['(']\n",
+		},
+		{
+			description: "Should reject invalid binary",
+			input:       "id: a\nrequests:\n  - paths: [/a]\n    matchers:\n      - type: binary\n        binary: ['zz']\n",
+		},
+		{
+			description: "Should reject a negative extractor group",
+			input: "id: a\nrequests:\n  - paths: [/a]\n    matchers:\n      - type: status\n        status: [200]\n" +
+				"    extractors:\n      - name: x\n        type: regex\n        regex: ['(a)']\n        group: -1\n",
+		},
+		{
+			description: "Should reject missing matchers",
+			input:       "id: a\nrequests:\n  - paths: [/a]\n",
+		},
+	}
+
+	for i := range testsCases {
+		tc := testsCases[i]
+
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := templates.Parse([]byte(tc.input))
+			if tc.valid != (err == nil) {
+				t.Fatalf("Unexpected result, error: %v", err)
+			}
+		})
+	}
+}
+
+func TestChecker_Check(t *testing.T) {
+	gitConfig, err := os.ReadFile("testdata/config.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.git/config":
+			_, _ = w.Write(gitConfig)
+		case "/.git/index":
+			_, _ = w.Write([]byte("DIRC\x00\x00\x00\x02"))
+		case "/.env":
+			if r.Header.Get("X-Check") != "githunt" {
+				t.Error("Missing template header")
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("APP_ENV=prod\nAPP_KEY=secret\n"))
+		case "/.env.bak":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("APP_ENV=prod\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	t.Cleanup(func() {
+		ts.Close()
+	})
+
+	builtin, err := templates.Builtin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	custom, err := templates.Load("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.NewClient()
+
+	testsCases := []struct {
+		description string
+		template    *templates.Template
+		paths       []string
+		metadata    map[string]string
+	}{
+		{
+			description: "Should match builtin git config template",
+			template:    templates.Find(builtin, "git-config"),
+			paths:       []string{"/.git/config"},
+			metadata:    map[string]string{"remote": "https://example@bitbucket.org/example/example.git"},
+		},
+		{
+			description: "Should match env file and skip html response",
+			template:    templates.Find(custom, "dotenv"),
+			paths:       []string{"/.env"},
+			metadata:    map[string]string{"app_key": "secret"},
+		},
+		{
+			description: "Should match binary magic",
+			template:    templates.Find(custom, "git-index"),
+			paths:       []string{"/.git/index"},
+			metadata:    map[string]string{},
+		},
+	}
+
+	for i := range testsCases {
+		tc := testsCases[i]
+
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			findings, err := templates.NewChecker(tc.template, c).Check(context.Background(), target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(findings) != len(tc.paths) {
+				t.Fatalf("Expected %d findings got %d", len(tc.paths), len(findings))
+			}
+
+			for j := range findings {
+				if findings[j].URL.Path != tc.paths[j] {
+					t.Fatalf("Expected path %s got %s", tc.paths[j], findings[j].URL.Path)
+				}
+
+				for k, v := range tc.metadata {
+					if findings[j].Metadata[k] != v {
+						t.Fatalf("Expected %s=%s got %s", k, v, findings[j].Metadata[k])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/url"
+
+	"github.com/georlav/githunt/internal/worker"
+)
+
+// Shard selects the targets of the K-th of N instances, the zero value selects every target.
+type Shard struct {
+	K int
+	N int
+}
+
+// ParseShard parses a shard in the k/n form, k starts from 1.
+func ParseShard(s string) (Shard, error) {
+	if s == "" {
+		return Shard{}, nil
+	}
+
+	var shard Shard
+	if _, err := fmt.Sscanf(s, "%d/%d", &shard.K, &shard.N); err != nil {
+		return Shard{}, fmt.Errorf("invalid shard %s, expected k/n e.g. 3/10", s)
+	}
+
+	if shard.N < 1 || shard.K < 1 || shard.K > shard.N {
+		return Shard{}, fmt.Errorf("invalid shard %s, k must be between 1 and n", s)
+	}
+
+	return shard, nil
+}
+
+// Contains reports whether the hash of the target falls in the shard.
+func (s Shard) Contains(u *url.URL) bool {
+	if s.N <= 1 {
+		return true
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(u.String()))
+
+	return int(h.Sum32()%uint32(s.N)) == s.K-1
+}
+
+// ShardTargets keeps the targets of the shard, targets that failed to load are kept by the first shard only
+// so that they are reported once.
+func ShardTargets(ctx context.Context, targets <-chan worker.Target, shard Shard) <-chan worker.Target {
+	if shard.N <= 1 {
+		return targets
+	}
+
+	sharded := make(chan worker.Target)
+
+	go func() {
+		defer close(sharded)
+
+		for t := range targets {
+			if t.Error != nil && shard.K != 1 || t.Error == nil && !shard.Contains(t.URL) {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case sharded <- t:
+			}
+		}
+	}()
+
+	return sharded
+}
+
+// ShuffleTargets emits the targets in a random order derived from seed, so that sorted lists do not hit
+// the addresses of one provider at a time. Every target is loaded in memory before the first is emitted.
+func ShuffleTargets(ctx context.Context, targets <-chan worker.Target, seed int64) <-chan worker.Target {
+	shuffled := make(chan worker.Target)
+
+	go func() {
+		defer close(shuffled)
+
+		var list []worker.Target
+		for t := range targets {
+			list = append(list, t)
+		}
+
+		//nolint:gosec
+		rand.New(rand.NewSource(seed)).Shuffle(len(list), func(i, j int) {
+			list[i], list[j] = list[j], list[i]
+		})
+
+		for _, t := range list {
+			select {
+			case <-ctx.Done():
+				return
+			case shuffled <- t:
+			}
+		}
+	}()
+
+	return shuffled
+}
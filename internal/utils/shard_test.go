@@ -0,0 +1,110 @@
+package utils_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/georlav/githunt/internal/utils"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+func TestParseShard(t *testing.T) {
+	testCases := []struct {
+		input string
+		shard utils.Shard
+		valid bool
+	}{
+		{"", utils.Shard{}, true},
+		{"3/10", utils.Shard{K: 3, N: 10}, true},
+		{"1/1", utils.Shard{K: 1, N: 1}, true},
+		{"0/10", utils.Shard{}, false},
+		{"11/10", utils.Shard{}, false},
+		{"3", utils.Shard{}, false},
+		{"a/b", utils.Shard{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			shard, err := utils.ParseShard(tc.input)
+			if (err == nil) != tc.valid {
+				t.Fatalf("Unexpected error %v", err)
+			}
+
+			if shard != tc.shard {
+				t.Fatalf("Expected %+v got %+v", tc.shard, shard)
+			}
+		})
+	}
+}
+
+func targets(n int) []string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("target-%d.example.com", i)
+	}
+
+	return list
+}
+
+func drain(ch <-chan worker.Target) []string {
+	var list []string
+	for t := range ch {
+		list = append(list, t.URL.String())
+	}
+
+	return list
+}
+
+func TestShardTargets(t *testing.T) {
+	ctx := context.Background()
+	list := targets(1000)
+
+	seen := make(map[string]int)
+	for k := 1; k <= 7; k++ {
+		shard := utils.Shard{K: k, N: 7}
+		sharded := drain(utils.ShardTargets(ctx, utils.LoadTargetList(ctx, list), shard))
+
+		// a shard should get roughly its share of the targets
+		if len(sharded) < 100 || len(sharded) > 200 {
+			t.Fatalf("Unbalanced shard %d/7 with %d targets", k, len(sharded))
+		}
+
+		for _, target := range sharded {
+			seen[target]++
+		}
+	}
+
+	if len(seen) != len(list) {
+		t.Fatalf("Expected %d targets got %d", len(list), len(seen))
+	}
+	for target, count := range seen {
+		if count != 1 {
+			t.Fatalf("Target %s was selected by %d shards", target, count)
+		}
+	}
+}
+
+func TestShuffleTargets(t *testing.T) {
+	ctx := context.Background()
+	list := targets(100)
+
+	first := drain(utils.ShuffleTargets(ctx, utils.LoadTargetList(ctx, list), 42))
+	second := drain(utils.ShuffleTargets(ctx, utils.LoadTargetList(ctx, list), 42))
+	other := drain(utils.ShuffleTargets(ctx, utils.LoadTargetList(ctx, list), 7))
+
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Fatal("Expected the same order for the same seed")
+	}
+	if fmt.Sprint(first) == fmt.Sprint(other) {
+		t.Fatal("Expected a different order for a different seed")
+	}
+
+	seen := make(map[string]bool)
+	for _, target := range first {
+		seen[target] = true
+	}
+	if len(first) != len(list) || len(seen) != len(list) {
+		t.Fatalf("Expected a permutation of %d targets got %d", len(list), len(first))
+	}
+}
@@ -2,51 +2,17 @@ package utils
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"net/url"
 	"os"
 
-	"github.com/fatih/color"
 	"github.com/georlav/githunt/internal/worker"
 )
 
-func SaveResults(ctx context.Context, results <-chan string, output string) error {
-	if output != "" {
-		out, err := os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-		if err != nil {
-			return fmt.Errorf("opening file %s. Error: %w", output, err)
-		}
-
-		if err := out.Truncate(0); err != nil {
-			return fmt.Errorf("truncating file %s. Error: %w", output, err)
-		}
-
-		go func() {
-			defer out.Close()
-
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case r, ok := <-results:
-					if !ok {
-						return
-					}
-
-					if _, err := out.WriteString(r + "\n"); err != nil {
-						panic(fmt.Sprintf("Failed to save result %s. Error: %s\n", r, err))
-					}
-				}
-			}
-		}()
-	}
-
-	return nil
-}
-
 //nolint:gocognit
-func LoadTargetURLs(ctx context.Context, filename, target, urlPath string) (<-chan worker.Target, error) {
+func LoadTargetURLs(ctx context.Context, filename, target string) (<-chan worker.Target, error) {
 	targets := make(chan worker.Target)
 
 	// single target
@@ -58,7 +24,6 @@ func LoadTargetURLs(ctx context.Context, filename, target, urlPath string) (<-ch
 		if tURL.Scheme == "" {
 			tURL.Scheme = "https"
 		}
-		tURL.Path += urlPath
 
 		go func() {
 			targets <- worker.Target{URL: tURL}
@@ -85,6 +50,11 @@ func LoadTargetURLs(ctx context.Context, filename, target, urlPath string) (<-ch
 			default:
 				scanner := bufio.NewScanner(file)
 				for scanner.Scan() {
+					// skipped like CountTargets does, so that the progress total matches
+					if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+						continue
+					}
+
 					u, err := url.Parse(scanner.Text())
 					if err != nil {
 						targets <- worker.Target{
@@ -97,8 +67,6 @@ func LoadTargetURLs(ctx context.Context, filename, target, urlPath string) (<-ch
 						u.Scheme = "https"
 					}
 
-					u.Path += urlPath
-
 					targets <- worker.Target{URL: u}
 				}
 
@@ -114,34 +82,65 @@ func LoadTargetURLs(ctx context.Context, filename, target, urlPath string) (<-ch
 	return targets, nil
 }
 
-// help menu.
-func Usage(cpus int, version string) func() {
-	return func() {
-		usage := `
-  _   o  _|_  |_        ._   _|_ 
- (_|  |   |_  | |  |_|  | |   |_  %s 
-  _|
-Usage: githunt [options...] 
-
-Usage Examples:
-  githunt -url example.com
-  githunt -urls urls.txt -workers 100 -timeout 30s -output out.txt
-
-Options:
-  Target:
-    -url         check single url
-    -urls        file containing multiple urls (one per line)
-    -path        sets the path to .git config file (default: /.git/config)
-
-  Request:
-    -workers     sets the desirable number of http workers (default: 50)
-    -cpus        sets the maximum number of CPUs that can be utilized (default: %d)
-    -timeout     sets a time limit for requests, valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h". (default: 15s)
-  
-  General:
-    -output      save vulnerable targets to a file
-
-`
-		color.New(color.FgGreen, color.Bold).Printf(usage, version, cpus)
+// ParseTarget parses a target url, https is assumed when the scheme is missing.
+func ParseTarget(target string) (*url.URL, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s. Error: %w", target, err)
+	}
+
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+
+	return u, nil
+}
+
+// LoadTargetList streams a list of target urls.
+func LoadTargetList(ctx context.Context, list []string) <-chan worker.Target {
+	targets := make(chan worker.Target)
+
+	go func() {
+		defer close(targets)
+
+		for _, target := range list {
+			u, err := ParseTarget(target)
+			t := worker.Target{URL: u, Error: err}
+
+			select {
+			case <-ctx.Done():
+				return
+			case targets <- t:
+			}
+		}
+	}()
+
+	return targets
+}
+
+// CountTargets counts the non empty lines of a targets file that belong to the shard.
+func CountTargets(ctx context.Context, filename string, shard Shard) (int64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, fmt.Errorf("opening targets file %s. Error: %w", filename, err)
 	}
+	defer file.Close()
+
+	var count int64
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		if u, err := ParseTarget(scanner.Text()); err == nil && shard.Contains(u) || err != nil && shard.K <= 1 {
+			count++
+		}
+	}
+
+	return count, scanner.Err()
 }
@@ -0,0 +1,38 @@
+package utils_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/georlav/githunt/internal/utils"
+)
+
+func TestLoadTargetURLs(t *testing.T) {
+	ctx := context.Background()
+
+	filename := filepath.Join(t.TempDir(), "urls.txt")
+	if err := os.WriteFile(filename, []byte("a.example.com\n\n  \t\nhttp://b.example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := utils.LoadTargetURLs(ctx, filename, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// blank lines are neither loaded nor counted
+	list := drain(ch)
+	if len(list) != 2 || list[0] != "https://a.example.com" || list[1] != "http://b.example.com" {
+		t.Fatalf("Unexpected targets %v", list)
+	}
+
+	count, err := utils.CountTargets(ctx, filename, utils.Shard{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != int64(len(list)) {
+		t.Fatalf("Expected %d counted targets got %d", len(list), count)
+	}
+}
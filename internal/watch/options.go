@@ -0,0 +1,44 @@
+package watch
+
+import (
+	"github.com/georlav/githunt/internal/sink"
+)
+
+type Option func(*Watcher)
+
+// SetSchedule change the schedule of full scans.
+func SetSchedule(s Schedule) Option {
+	return func(args *Watcher) {
+		args.full = s
+	}
+}
+
+// SetRecheck change the schedule of the rechecks of vulnerable hosts.
+func SetRecheck(s Schedule) Option {
+	return func(args *Watcher) {
+		args.recheck = s
+	}
+}
+
+// SetSinks change the destinations of the events.
+func SetSinks(sinks ...sink.Sink) Option {
+	return func(args *Watcher) {
+		args.sinks = sinks
+	}
+}
+
+// SetWorkers change the size of the worker pool.
+func SetWorkers(workers int) Option {
+	return func(args *Watcher) {
+		if workers > 0 {
+			args.workers = workers
+		}
+	}
+}
+
+// SetLogger change the function progress messages are written to.
+func SetLogger(logf func(format string, args ...any)) Option {
+	return func(args *Watcher) {
+		args.logf = logf
+	}
+}
@@ -0,0 +1,339 @@
+// Package watch rescans targets on a schedule and reports the targets that change state.
+package watch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/results"
+	"github.com/georlav/githunt/internal/sink"
+	"github.com/georlav/githunt/internal/store"
+	"github.com/georlav/githunt/internal/utils"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+// Kinds of runs.
+const (
+	RunFull    = "full"
+	RunRecheck = "recheck"
+)
+
+// flushInterval bounds the delay of events while a run is in progress.
+const flushInterval = 5 * time.Second
+
+// Loader streams the targets of a full run.
+type Loader func(ctx context.Context) (<-chan worker.Target, error)
+
+// Watcher runs full scans of a target set and more frequent rechecks of the vulnerable hosts on a single
+// worker pool, recheck targets are scanned before the targets of a full scan.
+type Watcher struct {
+	store    *store.Store
+	registry *checker.Registry
+	load     Loader
+	full     Schedule
+	recheck  Schedule
+	sinks    []sink.Sink
+	workers  int
+	logf     func(format string, args ...any)
+}
+
+type run struct {
+	kind    string
+	session *store.Session
+	sent    int
+	scanned int
+	loaded  bool
+}
+
+// NewWatcher creates a watcher that records its runs in st.
+func NewWatcher(st *store.Store, registry *checker.Registry, load Loader, options ...Option) *Watcher {
+	w := Watcher{
+		store:    st,
+		registry: registry,
+		load:     load,
+		full:     every(24 * time.Hour),
+		recheck:  every(time.Hour),
+		workers:  50,
+		logf:     func(string, ...any) {},
+	}
+
+	for _, option := range options {
+		option(&w)
+	}
+
+	return &w
+}
+
+// Run starts with a full scan and then follows the schedules until the context is done.
+//
+//nolint:gocognit,cyclop
+func (w *Watcher) Run(ctx context.Context) error {
+	var (
+		high    = make(chan worker.Target)
+		low     = make(chan worker.Target)
+		targets = make(chan worker.Target)
+		loaded  = make(chan string)
+		events  = make(chan sink.Event, 100)
+
+		mu   sync.Mutex
+		runs = make(map[string]*run)
+	)
+
+	resultCH := worker.Work(ctx, targets, w.registry, w.workers)
+
+	// slow sinks do not hold up the scan
+	sent := w.send(ctx, events)
+	defer func() {
+		close(events)
+		<-sent
+	}()
+
+	// recheck targets go first
+	go func() {
+		defer close(targets)
+
+		for {
+			var t worker.Target
+			select {
+			case <-ctx.Done():
+				return
+			case t = <-high:
+			default:
+				select {
+				case <-ctx.Done():
+					return
+				case t = <-high:
+				case t = <-low:
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case targets <- t:
+			}
+		}
+	}()
+
+	start := func(kind string) error {
+		mu.Lock()
+		for _, r := range runs {
+			if r.kind == kind {
+				mu.Unlock()
+				w.logf("Skipping %s run, the previous one is still running\n", kind)
+				return nil
+			}
+		}
+		mu.Unlock()
+
+		src, err := w.targets(ctx, kind)
+		if err != nil || src == nil {
+			return err
+		}
+
+		session, err := w.store.Begin(time.Now())
+		if err != nil {
+			return err
+		}
+
+		r := &run{kind: kind, session: session}
+		session.OnChange(func(c store.Change) {
+			if e, ok := sink.FromChange(session.ID(), c); ok {
+				select {
+				case <-ctx.Done():
+				case events <- e:
+				}
+			}
+		})
+
+		mu.Lock()
+		runs[session.ID()] = r
+		mu.Unlock()
+
+		w.logf("Started %s run %s\n", kind, session.ID())
+
+		queue := low
+		if kind == RunRecheck {
+			queue = high
+		}
+
+		go func() {
+			for t := range src {
+				t.Job = session.ID()
+
+				select {
+				case <-ctx.Done():
+					return
+				case queue <- t:
+				}
+
+				mu.Lock()
+				r.sent++
+				mu.Unlock()
+			}
+
+			select {
+			case <-ctx.Done():
+			case loaded <- session.ID():
+			}
+		}()
+
+		return nil
+	}
+
+	// finish closes a run once every target was scanned, it must be called with the lock held
+	finish := func(id string) {
+		r := runs[id]
+		if !r.loaded || r.scanned < r.sent {
+			return
+		}
+
+		delete(runs, id)
+		if err := r.session.Close(); err != nil {
+			w.logf("%s\n", err)
+		}
+		w.logf("Finished %s run %s, %d target(s) scanned\n", r.kind, id, r.scanned)
+	}
+
+	if err := start(RunFull); err != nil {
+		return err
+	}
+
+	var (
+		nextFull    = newTimer(w.full)
+		nextRecheck = newTimer(w.recheck)
+		flush       = time.NewTicker(flushInterval)
+	)
+	defer nextFull.Stop()
+	defer nextRecheck.Stop()
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			defer mu.Unlock()
+
+			// keep what was scanned, the targets of interrupted runs are scanned again by the next run
+			for _, r := range runs {
+				_ = r.session.Close()
+			}
+			return nil
+		case <-nextFull.C:
+			if err := start(RunFull); err != nil {
+				w.logf("%s\n", err)
+			}
+			arm(nextFull, w.full)
+		case <-nextRecheck.C:
+			if err := start(RunRecheck); err != nil {
+				w.logf("%s\n", err)
+			}
+			arm(nextRecheck, w.recheck)
+		case id := <-loaded:
+			mu.Lock()
+			runs[id].loaded = true
+			finish(id)
+			mu.Unlock()
+		case <-flush.C:
+			mu.Lock()
+			for _, r := range runs {
+				if err := r.session.Flush(); err != nil {
+					w.logf("%s\n", err)
+				}
+			}
+			mu.Unlock()
+		case result, ok := <-resultCH:
+			if !ok {
+				return nil
+			}
+
+			mu.Lock()
+			r, found := runs[result.Job]
+			if found {
+				r.scanned++
+				if err := r.session.Add(results.FromResult(result, time.Now())...); err != nil {
+					w.logf("%s\n", err)
+				}
+				finish(result.Job)
+			}
+			mu.Unlock()
+		}
+	}
+}
+
+// newTimer returns a timer that fires on the next activation of s.
+func newTimer(s Schedule) *time.Timer {
+	t := time.NewTimer(time.Hour)
+	t.Stop()
+	arm(t, s)
+
+	return t
+}
+
+// arm resets an expired or stopped timer to the next activation of s, a schedule without a later activation
+// leaves the timer stopped instead of firing it at once.
+func arm(t *time.Timer, s Schedule) {
+	now := time.Now()
+	if next := s.Next(now); next.After(now) {
+		t.Reset(next.Sub(now))
+	}
+}
+
+// send delivers events to the sinks until events is closed, the sinks are then flushed and closed. The returned
+// channel is closed once every sink is closed.
+func (w *Watcher) send(ctx context.Context, events <-chan sink.Event) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		// batched events are delivered even when the watcher stops
+		defer func() {
+			closing, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+			defer cancel()
+
+			if err := sink.CloseAll(closing, w.sinks); err != nil {
+				w.logf("%s\n", err)
+			}
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+
+				if err := sink.SendAll(ctx, w.sinks, []sink.Event{e}); err != nil {
+					w.logf("%s\n", err)
+				}
+			}
+		}
+	}()
+
+	return done
+}
+
+// targets returns the targets of a run, rechecks scan the hosts that are vulnerable in the store. It returns
+// nil when there is nothing to recheck.
+func (w *Watcher) targets(ctx context.Context, kind string) (<-chan worker.Target, error) {
+	if kind == RunFull {
+		return w.load(ctx)
+	}
+
+	hosts, err := w.store.Hosts(store.Query{Status: store.StatusVulnerable})
+	if err != nil || len(hosts) == 0 {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(hosts))
+	for i := range hosts {
+		list = append(list, hosts[i].Target)
+	}
+
+	return utils.LoadTargetList(ctx, list), nil
+}
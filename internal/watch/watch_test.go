@@ -0,0 +1,242 @@
+package watch_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/sink"
+	"github.com/georlav/githunt/internal/store"
+	"github.com/georlav/githunt/internal/templates"
+	"github.com/georlav/githunt/internal/utils"
+	"github.com/georlav/githunt/internal/watch"
+	"github.com/georlav/githunt/internal/worker"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, 5, 1, 10, 17, 30, 0, time.UTC) // a wednesday
+
+	testCases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * 0", time.Date(2024, 5, 5, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * 7", time.Date(2024, 5, 5, 3, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * 1-5", time.Date(2024, 5, 1, 13, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 5", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", from.Add(90 * time.Minute)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			s, err := watch.ParseSchedule(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if next := s.Next(from); !next.Equal(tc.next) {
+				t.Fatalf("Expected %s got %s", tc.next, next)
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@often", "0 0 30 2 *", "0 0 31 4,6 *"} {
+		if _, err := watch.ParseSchedule(spec); err == nil {
+			t.Fatalf("Expected error for %q", spec)
+		}
+	}
+}
+
+type interval time.Duration
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []sink.Event
+}
+
+func (r *recorder) Name() string {
+	return "recorder"
+}
+
+func (r *recorder) Send(_ context.Context, events []sink.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, events...)
+
+	return nil
+}
+
+func (r *recorder) wait(t *testing.T, n int) []sink.Event {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		if len(r.events) >= n {
+			events := append([]sink.Event(nil), r.events...)
+			r.mu.Unlock()
+			return events
+		}
+		r.mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("Expected %d events got %+v", n, r.events)
+
+	return nil
+}
+
+func TestWatcher(t *testing.T) {
+	var exposed atomic.Bool
+	exposed.Store(true)
+
+	vulnerable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exposed.Load() {
+			_, _ = w.Write([]byte("[core]\n[remote \"origin\"]\n\turl = git@example.com:org/app.git\n"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(vulnerable.Close)
+
+	var cleanRequests atomic.Int32
+	clean := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cleanRequests.Add(1)
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(clean.Close)
+
+	st, err := store.Open(filepath.Join(t.TempDir(), "githunt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		st.Close()
+	})
+
+	tpls, err := templates.Builtin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := checker.NewRegistry(templates.NewCheckers(tpls, client.NewClient())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(ctx context.Context) (<-chan worker.Target, error) {
+		return utils.LoadTargetList(ctx, []string{vulnerable.URL, clean.URL}), nil
+	}
+
+	events := &recorder{}
+	w := watch.NewWatcher(st, registry, load,
+		watch.SetSchedule(interval(time.Hour)),
+		watch.SetRecheck(interval(100*time.Millisecond)),
+		watch.SetSinks(events),
+		watch.SetWorkers(2),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	first := events.wait(t, 1)[0]
+	if first.Kind != sink.EventVulnerable || first.Target != vulnerable.URL || first.Remote != "git@example.com:org/app.git" {
+		t.Fatalf("Unexpected event %+v", first)
+	}
+
+	// the next recheck finds the exposure fixed
+	exposed.Store(false)
+
+	fixed := events.wait(t, 2)[1]
+	if fixed.Kind != sink.EventFixed || fixed.Target != vulnerable.URL || fixed.Previous != store.StatusVulnerable {
+		t.Fatalf("Unexpected event %+v", fixed)
+	}
+
+	// rechecks run often but produce no events without changes
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(events.wait(t, 2)); n != 2 {
+		t.Fatalf("Expected 2 events got %d", n)
+	}
+
+	// clean hosts are only scanned by the full run
+	if n := cleanRequests.Load(); n != 1 {
+		t.Fatalf("Expected a single request to the clean target got %d", n)
+	}
+}
+
+// never is a schedule without activations.
+type never struct{}
+
+func (never) Next(time.Time) time.Time {
+	return time.Time{}
+}
+
+func TestWatcher_NoActivation(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	st, err := store.Open(filepath.Join(t.TempDir(), "githunt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		st.Close()
+	})
+
+	tpls, err := templates.Builtin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := checker.NewRegistry(templates.NewCheckers(tpls, client.NewClient())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(ctx context.Context) (<-chan worker.Target, error) {
+		return utils.LoadTargetList(ctx, []string{ts.URL}), nil
+	}
+
+	w := watch.NewWatcher(st, registry, load, watch.SetSchedule(never{}), watch.SetRecheck(never{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	t.Cleanup(cancel)
+
+	if err := w.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the first full run is started
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("Expected a single request got %d", n)
+	}
+}
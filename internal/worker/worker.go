@@ -5,24 +5,31 @@ import (
 	"net/url"
 	"sync"
 
-	"github.com/georlav/githunt/internal/client"
+	"github.com/georlav/githunt/internal/checker"
+	"github.com/georlav/githunt/internal/metrics"
 )
 
 type Target struct {
 	URL   *url.URL
 	Error error
+	// Job identifies the job a target belongs to when several jobs share the pool.
+	Job string
+	// Registry overrides the checkers of the pool for this target.
+	Registry *checker.Registry
 }
 
 type Result struct {
 	URL        *url.URL
 	Vulnerable bool
+	Findings   []checker.Finding
 	Error      error
+	Job        string
 }
 
 func Work(
 	ctx context.Context,
 	targets <-chan Target,
-	c *client.Client,
+	registry *checker.Registry,
 	workers int,
 ) <-chan Result {
 	resultCH := make(chan Result)
@@ -34,6 +41,9 @@ func Work(
 		go func() {
 			defer wg.Done()
 
+			metrics.Workers.Inc()
+			defer metrics.Workers.Dec()
+
 			for {
 				select {
 				case <-ctx.Done():
@@ -43,17 +53,37 @@ func Work(
 						return
 					}
 
+					metrics.TargetsScanned.Inc()
+
 					// handle invalid targets
 					if t.Error != nil {
-						resultCH <- Result{Error: t.Error}
+						resultCH <- Result{Error: t.Error, Job: t.Job}
 						continue
 					}
 
-					isVulnerable, err := c.CheckGit(ctx, t.URL)
+					// run every registered checker against the target in one pass
+					r := registry
+					if t.Registry != nil {
+						r = t.Registry
+					}
+
+					metrics.WorkersBusy.Inc()
+					findings, err := r.Check(ctx, t.URL)
+					metrics.WorkersBusy.Dec()
+
+					if len(findings) > 0 {
+						metrics.TargetsVulnerable.Inc()
+					}
+					for i := range findings {
+						metrics.Findings.Inc(findings[i].Checker)
+					}
+
 					resultCH <- Result{
 						URL:        t.URL,
-						Vulnerable: isVulnerable,
+						Vulnerable: len(findings) > 0,
+						Findings:   findings,
 						Error:      err,
+						Job:        t.Job,
 					}
 				}
 			}